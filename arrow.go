@@ -0,0 +1,332 @@
+package mapache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ArrowRecordBatchSize is the maximum number of rows of each Arrow record batch.
+const ArrowRecordBatchSize = 65536
+
+// Arrow IPC metadata constants, see the Arrow columnar format specification.
+const (
+	arrowMetadataV5      int16 = 4
+	arrowSchemaHeader    uint8 = 1
+	arrowRecordBatch     uint8 = 3
+	arrowTypeInt         uint8 = 2
+	arrowTypeFloat       uint8 = 3
+	arrowTypeUtf8        uint8 = 5
+	arrowTypeTimestamp   uint8 = 10
+	arrowDoublePrecision int16 = 2
+	arrowMicrosecond     int16 = 2
+)
+
+var (
+	arrowMagic = []byte("ARROW1")
+	// arrowEndOfStream marks the end of an Arrow IPC stream.
+	arrowEndOfStream = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00}
+)
+
+// WriteArrowFile writes the table to an Arrow IPC file (also known as Feather V2) at the given path.
+func WriteArrowFile(path string, table SignalTable) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteArrow(file, table); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteArrow writes the table in the Arrow IPC file format (also known as Feather V2), which can be read with
+// pyarrow.ipc.open_file, pandas.read_feather, or DuckDB. Rows are split into record batches of ArrowRecordBatchSize.
+func WriteArrow(w io.Writer, table SignalTable) error {
+	a := &offsetWriter{w: w}
+	a.write(append(append([]byte{}, arrowMagic...), 0, 0))
+	blocks, err := a.writeStream(table)
+	if err != nil {
+		return err
+	}
+	footer := fbFinish(fbTable{
+		arrowMetadataV5,
+		arrowSchema(table),
+		fbStructs{size: 24},
+		fbStructs{size: 24, data: blocks},
+	})
+	a.write(footer)
+	a.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	a.write(arrowMagic)
+	return a.err
+}
+
+// WriteArrowStream writes the table in the Arrow IPC streaming format, which can be read with
+// pyarrow.ipc.open_stream. Rows are split into record batches of ArrowRecordBatchSize.
+func WriteArrowStream(w io.Writer, table SignalTable) error {
+	a := &offsetWriter{w: w}
+	_, err := a.writeStream(table)
+	return err
+}
+
+// offsetWriter writes to an io.Writer, keeping track of the offset and the first error.
+type offsetWriter struct {
+	w      io.Writer
+	offset int64
+	err    error
+}
+
+func (a *offsetWriter) write(data []byte) {
+	if a.err != nil {
+		return
+	}
+	n, err := a.w.Write(data)
+	a.offset += int64(n)
+	a.err = err
+}
+
+// writeStream writes the schema and record batch messages of the table, followed by the end of stream marker.
+// It returns the footer blocks of the record batches.
+func (a *offsetWriter) writeStream(table SignalTable) ([]byte, error) {
+	a.writeMessage(fbTable{arrowMetadataV5, arrowSchemaHeader, arrowSchema(table), int64(0)}, nil)
+	blocks := []byte{}
+	for start := 0; start == 0 || start < table.Rows; start += ArrowRecordBatchSize {
+		end := min(start+ArrowRecordBatchSize, table.Rows)
+		header, body, err := arrowRecordBatchBody(table, start, end)
+		if err != nil {
+			return nil, err
+		}
+		offset := a.offset
+		metadataLength := a.writeMessage(fbTable{arrowMetadataV5, arrowRecordBatch, header, int64(len(body))}, body)
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(offset))
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(metadataLength))
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(len(body)))
+	}
+	a.write(arrowEndOfStream)
+	return blocks, a.err
+}
+
+// writeMessage writes an encapsulated message and returns the length of its prefixed metadata.
+func (a *offsetWriter) writeMessage(message fbTable, body []byte) int {
+	metadata := fbFinish(message)
+	a.write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+	a.write(binary.LittleEndian.AppendUint32(nil, uint32(len(metadata))))
+	a.write(metadata)
+	a.write(body)
+	return 8 + len(metadata)
+}
+
+// arrowSchema returns the Schema table of the table's columns.
+func arrowSchema(table SignalTable) fbTable {
+	fields := make([]fbTable, len(table.Columns))
+	for i, column := range table.Columns {
+		var typeID uint8
+		var columnType fbTable
+		switch column.Type {
+		case TimestampColumn:
+			typeID, columnType = arrowTypeTimestamp, fbTable{arrowMicrosecond, "UTC"}
+		case IntColumn:
+			typeID, columnType = arrowTypeInt, fbTable{int32(64), true}
+		case FloatColumn:
+			typeID, columnType = arrowTypeFloat, fbTable{arrowDoublePrecision}
+		case StringColumn:
+			typeID, columnType = arrowTypeUtf8, fbTable{}
+		}
+		fields[i] = fbTable{column.Name, column.Valid != nil, typeID, columnType, nil, []fbTable{}}
+	}
+	return fbTable{int16(0), fields}
+}
+
+// arrowRecordBatchBody returns the RecordBatch table and the body of the rows of the table from start to end.
+func arrowRecordBatchBody(table SignalTable, start int, end int) (fbTable, []byte, error) {
+	rows := end - start
+	nodes := []byte{}
+	buffers := []byte{}
+	body := []byte{}
+	addBuffer := func(data []byte) {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(body)))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(data)))
+		body = append(body, data...)
+		body = append(body, make([]byte, (8-len(body)%8)%8)...)
+	}
+	for _, column := range table.Columns {
+		nulls := 0
+		validity := []byte{}
+		if column.Valid != nil {
+			validity = make([]byte, (rows+7)/8)
+			for i, valid := range column.Valid[start:end] {
+				if valid {
+					validity[i/8] |= 1 << (i % 8)
+				} else {
+					nulls++
+				}
+			}
+		}
+		if nulls == 0 {
+			validity = nil
+		}
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(rows))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(nulls))
+		addBuffer(validity)
+		switch column.Type {
+		case TimestampColumn, IntColumn:
+			values := make([]byte, 0, rows*8)
+			for _, v := range column.Ints[start:end] {
+				values = binary.LittleEndian.AppendUint64(values, uint64(v))
+			}
+			addBuffer(values)
+		case FloatColumn:
+			values := make([]byte, 0, rows*8)
+			for _, v := range column.Floats[start:end] {
+				values = binary.LittleEndian.AppendUint64(values, math.Float64bits(v))
+			}
+			addBuffer(values)
+		case StringColumn:
+			offsets := make([]byte, 4, (rows+1)*4)
+			data := []byte{}
+			for _, v := range column.Strings[start:end] {
+				data = append(data, v...)
+				if len(data) > math.MaxInt32 {
+					return nil, nil, fmt.Errorf("column %s is too large for an arrow record batch", column.Name)
+				}
+				offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+			}
+			addBuffer(offsets)
+			addBuffer(data)
+		}
+	}
+	return fbTable{int64(rows), fbStructs{size: 16, data: nodes}, fbStructs{size: 16, data: buffers}}, body, nil
+}
+
+// fbTable is a flatbuffers table, with its fields indexed by their ID. A field is either nil when absent, a
+// scalar (bool, uint8, int16, int32, or int64), a string, a nested fbTable, a vector of tables ([]fbTable),
+// or a vector of structs (fbStructs). A union is stored as its uint8 type followed by its table.
+type fbTable []any
+
+// fbStructs is a vector of structs of the given size, aligned to 8 bytes.
+type fbStructs struct {
+	size int
+	data []byte
+}
+
+// fbBuilder writes flatbuffers front to back, with every object written before the objects it references.
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbFinish returns the flatbuffer of a root table, padded to 8 bytes.
+func fbFinish(root fbTable) []byte {
+	b := &fbBuilder{buf: make([]byte, 4)}
+	position := b.table(root)
+	binary.LittleEndian.PutUint32(b.buf, uint32(position))
+	b.align(8)
+	return b.buf
+}
+
+func (b *fbBuilder) align(n int) {
+	b.buf = append(b.buf, make([]byte, (n-len(b.buf)%n)%n)...)
+}
+
+// fbSize returns the inline size of a field, which is 4 bytes for the offset of a referenced object.
+func fbSize(v any) int {
+	switch v.(type) {
+	case bool, uint8:
+		return 1
+	case int16:
+		return 2
+	case int64:
+		return 8
+	}
+	return 4
+}
+
+// table writes the vtable and inline fields of a table followed by the objects it references,
+// and returns the position of the table.
+func (b *fbBuilder) table(t fbTable) int {
+	b.align(2)
+	vtable := len(b.buf)
+	start := vtable + 4 + 2*len(t)
+	start += (4 - start%4) % 4
+	offsets := make([]int, len(t))
+	end := start + 4
+	for i, v := range t {
+		if v == nil {
+			continue
+		}
+		size := fbSize(v)
+		end += (size - end%size) % size
+		offsets[i] = end - start
+		end += size
+	}
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(t)))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(end-start))
+	for _, offset := range offsets {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(offset))
+	}
+	b.buf = append(b.buf, make([]byte, end-len(b.buf))...)
+	binary.LittleEndian.PutUint32(b.buf[start:], uint32(start-vtable))
+	for i, v := range t {
+		field := b.buf[start+offsets[i]:]
+		switch v := v.(type) {
+		case bool:
+			if v {
+				field[0] = 1
+			}
+		case uint8:
+			field[0] = v
+		case int16:
+			binary.LittleEndian.PutUint16(field, uint16(v))
+		case int32:
+			binary.LittleEndian.PutUint32(field, uint32(v))
+		case int64:
+			binary.LittleEndian.PutUint64(field, uint64(v))
+		}
+	}
+	for i, v := range t {
+		switch v.(type) {
+		case string, fbTable, []fbTable, fbStructs:
+			// the object is written first, since writing it may grow the buffer
+			position := start + offsets[i]
+			object := b.object(v)
+			binary.LittleEndian.PutUint32(b.buf[position:], uint32(object-position))
+		}
+	}
+	return start
+}
+
+// object writes a referenced object and returns its position.
+func (b *fbBuilder) object(v any) int {
+	switch v := v.(type) {
+	case string:
+		b.align(4)
+		position := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(append(b.buf, v...), 0)
+		return position
+	case fbTable:
+		return b.table(v)
+	case []fbTable:
+		b.align(4)
+		position := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+		b.buf = append(b.buf, make([]byte, 4*len(v))...)
+		for i, t := range v {
+			element := position + 4 + 4*i
+			object := b.table(t)
+			binary.LittleEndian.PutUint32(b.buf[element:], uint32(object-element))
+		}
+		return position
+	case fbStructs:
+		// the length is placed right before an 8-byte boundary so that the structs are aligned
+		b.align(8)
+		b.buf = append(b.buf, 0, 0, 0, 0)
+		position := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v.data)/v.size))
+		b.buf = append(b.buf, v.data...)
+		return position
+	}
+	return 0
+}
@@ -0,0 +1,178 @@
+package mapache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fbField returns the position of a field of the table at the given position, or 0 if the field is absent.
+func fbField(buf []byte, table int, id int) int {
+	vtable := table - int(int32(binary.LittleEndian.Uint32(buf[table:])))
+	if 4+2*id >= int(binary.LittleEndian.Uint16(buf[vtable:])) {
+		return 0
+	}
+	offset := int(binary.LittleEndian.Uint16(buf[vtable+4+2*id:]))
+	if offset == 0 {
+		return 0
+	}
+	return table + offset
+}
+
+// fbRef returns the position of the object referenced at the given position.
+func fbRef(buf []byte, position int) int {
+	return position + int(binary.LittleEndian.Uint32(buf[position:]))
+}
+
+// fbString returns the string referenced at the given position.
+func fbString(buf []byte, position int) string {
+	s := fbRef(buf, position)
+	return string(buf[s+4 : s+4+int(binary.LittleEndian.Uint32(buf[s:]))])
+}
+
+func TestFlatbuffers(t *testing.T) {
+	structs := binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, 1), 2)
+	buf := fbFinish(fbTable{int16(-3), "mapache", nil, fbTable{int64(7), uint8(9)}, []fbTable{{true}, {false}}, fbStructs{size: 8, data: structs}})
+	if len(buf)%8 != 0 {
+		t.Errorf("Expected buffer padded to 8 bytes, got %d", len(buf))
+	}
+	root := fbRef(buf, 0)
+	if v := int16(binary.LittleEndian.Uint16(buf[fbField(buf, root, 0):])); v != -3 {
+		t.Errorf("Expected -3, got %d", v)
+	}
+	if s := fbString(buf, fbField(buf, root, 1)); s != "mapache" {
+		t.Errorf("Expected mapache, got %s", s)
+	}
+	if fbField(buf, root, 2) != 0 || fbField(buf, root, 9) != 0 {
+		t.Errorf("Expected absent fields")
+	}
+	nested := fbRef(buf, fbField(buf, root, 3))
+	if position := fbField(buf, nested, 0); position%8 != 0 || binary.LittleEndian.Uint64(buf[position:]) != 7 {
+		t.Errorf("Expected aligned 7 at %d", position)
+	}
+	if buf[fbField(buf, nested, 1)] != 9 {
+		t.Errorf("Expected 9, got %d", buf[fbField(buf, nested, 1)])
+	}
+	vector := fbRef(buf, fbField(buf, root, 4))
+	if binary.LittleEndian.Uint32(buf[vector:]) != 2 || buf[fbField(buf, fbRef(buf, vector+4), 0)] != 1 || buf[fbField(buf, fbRef(buf, vector+8), 0)] != 0 {
+		t.Errorf("Unexpected vector of tables at %d", vector)
+	}
+	vector = fbRef(buf, fbField(buf, root, 5))
+	if (vector+4)%8 != 0 || binary.LittleEndian.Uint32(buf[vector:]) != 2 || !bytes.Equal(buf[vector+4:vector+20], structs) {
+		t.Errorf("Unexpected vector of structs at %d", vector)
+	}
+}
+
+func TestWriteArrow(t *testing.T) {
+	table := NewSignalTable(testTableSignals(), WideLayout)
+	var buf bytes.Buffer
+	if err := WriteArrow(&buf, table); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[:8]) != "ARROW1\x00\x00" || string(data[len(data)-6:]) != "ARROW1" {
+		t.Fatalf("Expected ARROW1 magic, got %q and %q", data[:8], data[len(data)-6:])
+	}
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+	footer := data[len(data)-10-footerLength : len(data)-10]
+	root := fbRef(footer, 0)
+	schema := fbRef(footer, fbField(footer, root, 1))
+	fields := fbRef(footer, fbField(footer, schema, 1))
+	if count := binary.LittleEndian.Uint32(footer[fields:]); count != 4 {
+		t.Fatalf("Expected 4 fields, got %d", count)
+	}
+	for i, name := range []string{"timestamp", "vehicle_id", "acu_temp", "ecu_speed"} {
+		field := fbRef(footer, fields+4+4*i)
+		if got := fbString(footer, fbField(footer, field, 0)); got != name {
+			t.Errorf("Expected field %s, got %s", name, got)
+		}
+	}
+	if !bytes.Equal(data[len(data)-10-footerLength-8:len(data)-10-footerLength], arrowEndOfStream) {
+		t.Errorf("Expected end of stream marker before the footer")
+	}
+
+	blocks := fbRef(footer, fbField(footer, root, 3))
+	if count := binary.LittleEndian.Uint32(footer[blocks:]); count != 1 {
+		t.Fatalf("Expected 1 record batch, got %d", count)
+	}
+	offset := int(binary.LittleEndian.Uint64(footer[blocks+4:]))
+	metadataLength := int(binary.LittleEndian.Uint32(footer[blocks+12:]))
+	bodyLength := int(binary.LittleEndian.Uint64(footer[blocks+20:]))
+	if offset%8 != 0 || metadataLength%8 != 0 || bodyLength%8 != 0 || binary.LittleEndian.Uint32(data[offset:]) != 0xFFFFFFFF {
+		t.Fatalf("Unexpected record batch block %d %d %d", offset, metadataLength, bodyLength)
+	}
+	message := data[offset+8 : offset+metadataLength]
+	header := fbRef(message, fbField(message, fbRef(message, 0), 2))
+	if rows := binary.LittleEndian.Uint64(message[fbField(message, header, 0):]); rows != 3 {
+		t.Errorf("Expected 3 rows, got %d", rows)
+	}
+	nodes := fbRef(message, fbField(message, header, 1))
+	if nulls := binary.LittleEndian.Uint64(message[nodes+4+2*16+8:]); nulls != 2 {
+		t.Errorf("Expected 2 nulls in acu_temp, got %d", nulls)
+	}
+	// buffers: timestamp (validity, values), vehicle_id (validity, offsets, data), acu_temp (validity, values), ...
+	buffers := fbRef(message, fbField(message, header, 2))
+	body := data[offset+metadataLength : offset+metadataLength+bodyLength]
+	buffer := func(i int) []byte {
+		start := binary.LittleEndian.Uint64(message[buffers+4+16*i:])
+		return body[start : start+binary.LittleEndian.Uint64(message[buffers+12+16*i:])]
+	}
+	if validity := buffer(5); !bytes.Equal(validity, []byte{0b010}) {
+		t.Errorf("Expected validity 010, got %b", validity)
+	}
+	if v := math.Float64frombits(binary.LittleEndian.Uint64(buffer(6)[8:])); v != 30 {
+		t.Errorf("Expected 30, got %f", v)
+	}
+	if s := string(buffer(4)); s != "gr23gr24gr24" {
+		t.Errorf("Expected gr23gr24gr24, got %s", s)
+	}
+	if len(buffer(7)) != 0 {
+		t.Errorf("Expected no validity buffer for ecu_speed, got %d bytes", len(buffer(7)))
+	}
+
+	t.Run("Test golden file", func(t *testing.T) {
+		// testdata/signals.arrow was read back with the Apache Arrow Go IPC file reader
+		expected, err := os.ReadFile(filepath.Join("testdata", "signals.arrow"))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Expected output to match testdata/signals.arrow")
+		}
+	})
+	t.Run("Test batches", func(t *testing.T) {
+		signals := make([]Signal, ArrowRecordBatchSize+10)
+		var buf bytes.Buffer
+		if err := WriteArrow(&buf, NewSignalTable(signals, LongLayout)); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		data := buf.Bytes()
+		footer := data[len(data)-10-int(binary.LittleEndian.Uint32(data[len(data)-10:])) : len(data)-10]
+		blocks := fbRef(footer, fbField(footer, fbRef(footer, 0), 3))
+		if count := binary.LittleEndian.Uint32(footer[blocks:]); count != 2 {
+			t.Errorf("Expected 2 record batches, got %d", count)
+		}
+	})
+	t.Run("Test stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteArrowStream(&buf, table); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		stream := buf.Bytes()
+		if binary.LittleEndian.Uint32(stream) != 0xFFFFFFFF || !bytes.Equal(stream[len(stream)-8:], arrowEndOfStream) {
+			t.Errorf("Unexpected stream % X", stream[:8])
+		}
+		if !bytes.Equal(stream, data[8:8+len(stream)]) {
+			t.Errorf("Expected the stream to match the messages of the file")
+		}
+	})
+	t.Run("Test file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "signals.arrow")
+		if err := WriteArrowFile(path, table); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+	})
+}
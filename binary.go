@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"math/big"
 )
 
 // BigEndianUnsignedIntToBinaryString converts an unsigned integer to a binary
@@ -17,11 +19,7 @@ func BigEndianUnsignedIntToBinaryString(num int, numBytes int) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	var bs = ""
-	for i := 0; i < numBytes; i++ {
-		bs += fmt.Sprintf("%08b", b[i])
-	}
-	return bs, nil
+	return bytesToBinaryString(b), nil
 }
 
 // BigEndianUnsignedIntToBinary converts an unsigned integer to bytes in big endian
@@ -72,11 +70,7 @@ func BigEndianSignedIntToBinaryString(num int, numBytes int) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	var bs = ""
-	for i := 0; i < numBytes; i++ {
-		bs += fmt.Sprintf("%08b", b[i])
-	}
-	return bs, nil
+	return bytesToBinaryString(b), nil
 }
 
 // BigEndianSignedIntToBinary converts a signed integer to bytes in big endian format.
@@ -123,6 +117,8 @@ func BigEndianSignedIntToBinary(num int, numBytes int) ([]byte, error) {
 }
 
 // BigEndianBytesToUnsignedInt converts bytes to an unsigned integer in big endian format.
+// Values that do not fit in an int, such as 8 byte values above 2^63-1, will overflow.
+// Use BigEndianBytesToUint64 or BigEndianBytesToBigInt for those instead.
 //
 // The function returns the integer value of the bytes.
 func BigEndianBytesToUnsignedInt(bytes []byte) int {
@@ -168,11 +164,7 @@ func LittleEndianUnsignedIntToBinaryString(num int, numBytes int) (string, error
 	if err != nil {
 		return "", err
 	}
-	var bs = ""
-	for i := 0; i < numBytes; i++ {
-		bs += fmt.Sprintf("%08b", b[i])
-	}
-	return bs, nil
+	return bytesToBinaryString(b), nil
 }
 
 // LittleEndianUnsignedIntToBinary converts an unsigned integer to bytes in little endian
@@ -223,11 +215,7 @@ func LittleEndianSignedIntToBinaryString(num int, numBytes int) (string, error)
 	if err != nil {
 		return "", err
 	}
-	var bs = ""
-	for i := 0; i < numBytes; i++ {
-		bs += fmt.Sprintf("%08b", b[i])
-	}
-	return bs, nil
+	return bytesToBinaryString(b), nil
 }
 
 // LittleEndianSignedIntToBinary converts a signed integer to bytes in little endian format.
@@ -274,6 +262,8 @@ func LittleEndianSignedIntToBinary(num int, numBytes int) ([]byte, error) {
 }
 
 // LittleEndianBytesToUnsignedInt converts bytes to an unsigned integer in little endian format.
+// Values that do not fit in an int, such as 8 byte values above 2^63-1, will overflow.
+// Use LittleEndianBytesToUint64 or LittleEndianBytesToBigInt for those instead.
 //
 // The function returns the integer value of the bytes.
 func LittleEndianBytesToUnsignedInt(bytes []byte) int {
@@ -308,3 +298,379 @@ func LittleEndianBytesToSignedInt(bytes []byte) int {
 	}
 	return result
 }
+
+// LittleEndianBitsToUnsignedInt extracts an unsigned integer of bitLength bits from data
+// using Intel bit numbering. Bit n is bit n%8 of byte n/8, where bit 0 is the least
+// significant bit of a byte. The startBit is the position of the least significant bit
+// of the value, and the value extends towards higher bit positions. Bits that fall
+// outside of data are treated as 0.
+//
+// The function returns the unsigned integer value of the bits.
+func LittleEndianBitsToUnsignedInt(data []byte, startBit int, bitLength int) uint64 {
+	var result uint64
+	for i := 0; i < bitLength && i < 64; i++ {
+		result |= uint64(readBit(data, startBit+i)) << uint(i)
+	}
+	return result
+}
+
+// BigEndianBitsToUnsignedInt extracts an unsigned integer of bitLength bits from data
+// using Motorola bit numbering. Bits are numbered the same way as in
+// LittleEndianBitsToUnsignedInt, but the startBit is the position of the most significant
+// bit of the value, and the value continues towards bit 0 of the same byte before moving
+// on to bit 7 of the next byte. Bits that fall outside of data are treated as 0.
+//
+// The function returns the unsigned integer value of the bits.
+func BigEndianBitsToUnsignedInt(data []byte, startBit int, bitLength int) uint64 {
+	var result uint64
+	pos := startBit
+	for i := 0; i < bitLength && i < 64; i++ {
+		result = result<<1 | uint64(readBit(data, pos))
+		pos = nextMotorolaBit(pos)
+	}
+	return result
+}
+
+// LittleEndianUnsignedIntToBits packs the lowest bitLength bits of num into data using
+// Intel bit numbering, as described in LittleEndianBitsToUnsignedInt. Bits of data outside
+// of the field are left untouched. If the field does not fit in data, an error will be returned.
+func LittleEndianUnsignedIntToBits(data []byte, startBit int, bitLength int, num uint64) error {
+	if startBit < 0 || bitLength < 1 || bitLength > 64 {
+		return fmt.Errorf("invalid bit range %d:%d", startBit, bitLength)
+	} else if BitSpan(startBit, bitLength, false) > len(data) {
+		return fmt.Errorf("bit range %d:%d does not fit in %d bytes", startBit, bitLength, len(data))
+	}
+	for i := 0; i < bitLength; i++ {
+		writeBit(data, startBit+i, int(num>>uint(i))&1)
+	}
+	return nil
+}
+
+// BigEndianUnsignedIntToBits packs the lowest bitLength bits of num into data using
+// Motorola bit numbering, as described in BigEndianBitsToUnsignedInt. Bits of data outside
+// of the field are left untouched. If the field does not fit in data, an error will be returned.
+func BigEndianUnsignedIntToBits(data []byte, startBit int, bitLength int, num uint64) error {
+	if startBit < 0 || bitLength < 1 || bitLength > 64 {
+		return fmt.Errorf("invalid bit range %d:%d", startBit, bitLength)
+	} else if BitSpan(startBit, bitLength, true) > len(data) {
+		return fmt.Errorf("bit range %d:%d does not fit in %d bytes", startBit, bitLength, len(data))
+	}
+	pos := startBit
+	for i := bitLength - 1; i >= 0; i-- {
+		writeBit(data, pos, int(num>>uint(i))&1)
+		pos = nextMotorolaBit(pos)
+	}
+	return nil
+}
+
+// BitSpan returns the number of bytes needed to hold a field of bitLength bits starting at
+// startBit, using Motorola bit numbering if motorola is true and Intel bit numbering otherwise.
+func BitSpan(startBit int, bitLength int, motorola bool) int {
+	if bitLength < 1 {
+		return 0
+	}
+	if !motorola {
+		return (startBit+bitLength-1)/8 + 1
+	}
+	pos := startBit
+	for i := 1; i < bitLength; i++ {
+		pos = nextMotorolaBit(pos)
+	}
+	return pos/8 + 1
+}
+
+// nextMotorolaBit returns the position of the next less significant bit after pos
+// in Motorola bit numbering.
+func nextMotorolaBit(pos int) int {
+	if pos%8 == 0 {
+		return pos + 15
+	}
+	return pos - 1
+}
+
+func readBit(data []byte, pos int) int {
+	if pos < 0 || pos/8 >= len(data) {
+		return 0
+	}
+	return int(data[pos/8]>>uint(pos%8)) & 1
+}
+
+func writeBit(data []byte, pos int, bit int) {
+	if bit == 1 {
+		data[pos/8] |= 1 << uint(pos%8)
+	} else {
+		data[pos/8] &^= 1 << uint(pos%8)
+	}
+}
+
+// BigEndianFloatToBinary converts a floating point number to bytes in big endian IEEE-754 format.
+// The precision is selected by numBytes: 2 for half, 4 for single, and 8 for double precision.
+// Any other number of bytes will return an error.
+//
+// The function returns a slice of bytes representing the binary.
+func BigEndianFloatToBinary(num float64, numBytes int) ([]byte, error) {
+	bits, err := floatToBits(num, numBytes*8)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, 8)
+	binary.BigEndian.PutUint64(result, bits)
+	return result[8-numBytes:], nil
+}
+
+// LittleEndianFloatToBinary converts a floating point number to bytes in little endian IEEE-754 format.
+// The precision is selected by numBytes: 2 for half, 4 for single, and 8 for double precision.
+// Any other number of bytes will return an error.
+//
+// The function returns a slice of bytes representing the binary.
+func LittleEndianFloatToBinary(num float64, numBytes int) ([]byte, error) {
+	bits, err := floatToBits(num, numBytes*8)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]byte, 8)
+	binary.LittleEndian.PutUint64(result, bits)
+	return result[:numBytes], nil
+}
+
+// BigEndianBytesToFloat converts bytes in big endian IEEE-754 format to a floating point number.
+// The precision is selected by the number of bytes: 2 for half, 4 for single, and 8 for double precision.
+//
+// The function returns the floating point value of the bytes, or an error for any other length.
+func BigEndianBytesToFloat(bytes []byte) (float64, error) {
+	var bits uint64
+	for i := 0; i < len(bytes) && i < 8; i++ {
+		bits = bits<<8 | uint64(bytes[i])
+	}
+	return floatFromBits(bits, len(bytes)*8)
+}
+
+// LittleEndianBytesToFloat converts bytes in little endian IEEE-754 format to a floating point number.
+// The precision is selected by the number of bytes: 2 for half, 4 for single, and 8 for double precision.
+//
+// The function returns the floating point value of the bytes, or an error for any other length.
+func LittleEndianBytesToFloat(bytes []byte) (float64, error) {
+	var bits uint64
+	for i := len(bytes) - 1; i >= 0; i-- {
+		bits = bits<<8 | uint64(bytes[i])
+	}
+	return floatFromBits(bits, len(bytes)*8)
+}
+
+// Float16ToFloat32 converts the bits of an IEEE-754 half precision number to a float32.
+func Float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1F
+	mant := uint32(h & 0x3FF)
+	if exp == 0x1F {
+		return math.Float32frombits(sign | 0x7F800000 | mant<<13)
+	} else if exp == 0 {
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// subnormal, normalize the mantissa into a float32 exponent
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		return math.Float32frombits(sign | e<<23 | (mant&0x3FF)<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// Float32ToFloat16 converts a float32 to the bits of an IEEE-754 half precision number,
+// rounding to the nearest even value. Values too large for half precision become infinity.
+func Float32ToFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int(b>>23) & 0xFF
+	mant := b & 0x7FFFFF
+	if exp == 0xFF {
+		if mant != 0 {
+			return sign | 0x7E00
+		}
+		return sign | 0x7C00
+	}
+	e := exp - 127 + 15
+	if e >= 0x1F {
+		return sign | 0x7C00
+	} else if e <= 0 {
+		if e < -10 {
+			return sign
+		}
+		// subnormal, shift the implicit leading bit into the mantissa
+		mant |= 0x800000
+		shift := uint(14 - e)
+		h := uint16(mant >> shift)
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && h&1 == 1) {
+			h++
+		}
+		return sign | h
+	}
+	h := sign | uint16(e)<<10 | uint16(mant>>13)
+	rem := mant & 0x1FFF
+	if rem > 0x1000 || (rem == 0x1000 && h&1 == 1) {
+		h++
+	}
+	return h
+}
+
+// floatToBits returns the raw IEEE-754 bits of num at the given precision (16, 32, or 64 bits).
+func floatToBits(num float64, bitSize int) (uint64, error) {
+	switch bitSize {
+	case 16:
+		return uint64(Float32ToFloat16(float32(num))), nil
+	case 32:
+		return uint64(math.Float32bits(float32(num))), nil
+	case 64:
+		return math.Float64bits(num), nil
+	}
+	return 0, fmt.Errorf("cannot convert float to %d bits, must be 16, 32, or 64", bitSize)
+}
+
+// floatFromBits returns the floating point value of raw IEEE-754 bits at the given precision (16, 32, or 64 bits).
+func floatFromBits(bits uint64, bitSize int) (float64, error) {
+	switch bitSize {
+	case 16:
+		return float64(Float16ToFloat32(uint16(bits))), nil
+	case 32:
+		return float64(math.Float32frombits(uint32(bits))), nil
+	case 64:
+		return math.Float64frombits(bits), nil
+	}
+	return 0, fmt.Errorf("cannot convert %d bits to float, must be 16, 32, or 64", bitSize)
+}
+
+// BigEndianUint64ToBinary converts an unsigned 64-bit integer to bytes in big endian format.
+// The input num will be packed into a number of bytes specified by numBytes, padded with
+// leading zeros if numBytes is larger than 8. If num is too large to fit in numBytes bytes,
+// an error will be returned.
+//
+// The function returns a slice of bytes representing the binary.
+func BigEndianUint64ToBinary(num uint64, numBytes int) ([]byte, error) {
+	if numBytes < 1 {
+		return nil, fmt.Errorf("cannot convert to binary with less than 1 byte")
+	} else if numBytes < 8 && num >= 1<<uint(numBytes*8) {
+		return nil, fmt.Errorf("number is too large to fit in %d bytes", numBytes)
+	}
+	result := make([]byte, numBytes)
+	for i := 0; i < numBytes && i < 8; i++ {
+		result[numBytes-i-1] = byte(num >> uint(i*8))
+	}
+	return result, nil
+}
+
+// LittleEndianUint64ToBinary converts an unsigned 64-bit integer to bytes in little endian format.
+// The input num will be packed into a number of bytes specified by numBytes, padded with
+// trailing zeros if numBytes is larger than 8. If num is too large to fit in numBytes bytes,
+// an error will be returned.
+//
+// The function returns a slice of bytes representing the binary.
+func LittleEndianUint64ToBinary(num uint64, numBytes int) ([]byte, error) {
+	if numBytes < 1 {
+		return nil, fmt.Errorf("cannot convert to binary with less than 1 byte")
+	} else if numBytes < 8 && num >= 1<<uint(numBytes*8) {
+		return nil, fmt.Errorf("number is too large to fit in %d bytes", numBytes)
+	}
+	result := make([]byte, numBytes)
+	for i := 0; i < numBytes && i < 8; i++ {
+		result[i] = byte(num >> uint(i*8))
+	}
+	return result, nil
+}
+
+// BigEndianBytesToUint64 converts bytes to an unsigned 64-bit integer in big endian format.
+// More than 8 bytes are accepted as long as the extra leading bytes are zero.
+//
+// The function returns the integer value of the bytes, or an error if it does not fit in 64 bits.
+func BigEndianBytesToUint64(bytes []byte) (uint64, error) {
+	var result uint64
+	for i := 0; i < len(bytes); i++ {
+		if i < len(bytes)-8 && bytes[i] != 0 {
+			return 0, fmt.Errorf("number in %d bytes is too large to fit in 64 bits", len(bytes))
+		}
+		result = result<<8 | uint64(bytes[i])
+	}
+	return result, nil
+}
+
+// LittleEndianBytesToUint64 converts bytes to an unsigned 64-bit integer in little endian format.
+// More than 8 bytes are accepted as long as the extra trailing bytes are zero.
+//
+// The function returns the integer value of the bytes, or an error if it does not fit in 64 bits.
+func LittleEndianBytesToUint64(bytes []byte) (uint64, error) {
+	var result uint64
+	for i := len(bytes) - 1; i >= 0; i-- {
+		if i >= 8 && bytes[i] != 0 {
+			return 0, fmt.Errorf("number in %d bytes is too large to fit in 64 bits", len(bytes))
+		}
+		result = result<<8 | uint64(bytes[i])
+	}
+	return result, nil
+}
+
+// BigEndianBigIntToBinary converts a non-negative big integer to bytes in big endian format.
+// The input num will be packed into a number of bytes specified by numBytes. If num is negative
+// or too large to fit in numBytes bytes, an error will be returned.
+//
+// The function returns a slice of bytes representing the binary.
+func BigEndianBigIntToBinary(num *big.Int, numBytes int) ([]byte, error) {
+	if num.Sign() < 0 {
+		return nil, fmt.Errorf("cannot convert negative number to binary")
+	} else if numBytes < 1 {
+		return nil, fmt.Errorf("cannot convert to binary with less than 1 byte")
+	} else if num.BitLen() > numBytes*8 {
+		return nil, fmt.Errorf("number is too large to fit in %d bytes", numBytes)
+	}
+	return num.FillBytes(make([]byte, numBytes)), nil
+}
+
+// LittleEndianBigIntToBinary converts a non-negative big integer to bytes in little endian format.
+// The input num will be packed into a number of bytes specified by numBytes. If num is negative
+// or too large to fit in numBytes bytes, an error will be returned.
+//
+// The function returns a slice of bytes representing the binary.
+func LittleEndianBigIntToBinary(num *big.Int, numBytes int) ([]byte, error) {
+	result, err := BigEndianBigIntToBinary(num, numBytes)
+	if err != nil {
+		return nil, err
+	}
+	reverseBytes(result)
+	return result, nil
+}
+
+// BigEndianBytesToBigInt converts bytes of any length to a non-negative big integer in big endian format.
+//
+// The function returns the integer value of the bytes.
+func BigEndianBytesToBigInt(bytes []byte) *big.Int {
+	return new(big.Int).SetBytes(bytes)
+}
+
+// LittleEndianBytesToBigInt converts bytes of any length to a non-negative big integer in little endian format.
+//
+// The function returns the integer value of the bytes.
+func LittleEndianBytesToBigInt(bytes []byte) *big.Int {
+	reversed := append([]byte{}, bytes...)
+	reverseBytes(reversed)
+	return new(big.Int).SetBytes(reversed)
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
+
+// bytesToBinaryString formats bytes as a string of 0s and 1s, 8 characters per byte.
+func bytesToBinaryString(b []byte) string {
+	bs := make([]byte, 0, len(b)*8)
+	for _, v := range b {
+		for i := 7; i >= 0; i-- {
+			bs = append(bs, '0'+(v>>uint(i))&1)
+		}
+	}
+	return string(bs)
+}
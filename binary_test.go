@@ -3,6 +3,8 @@ package mapache
 import (
 	"bytes"
 	"encoding/binary"
+	"math"
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -908,3 +910,329 @@ func TestLittleEndianBytesToSignedInt(t *testing.T) {
 		}
 	})
 }
+
+func TestLittleEndianBitsToUnsignedInt(t *testing.T) {
+	data := []byte{0x12, 0x34}
+	testCases := []struct {
+		name      string
+		startBit  int
+		bitLength int
+		expected  uint64
+	}{
+		{"Low Nibble", 0, 4, 0x2},
+		{"High Nibble", 4, 4, 0x1},
+		{"12 Bits Across Bytes", 4, 12, 0x341},
+		{"Full 16 Bits", 0, 16, 0x3412},
+		{"Single Bit", 1, 1, 1},
+		{"Out Of Range", 12, 8, 0x3},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := LittleEndianBitsToUnsignedInt(data, tc.startBit, tc.bitLength)
+			if v != tc.expected {
+				t.Errorf("Expected 0x%X, got 0x%X", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestBigEndianBitsToUnsignedInt(t *testing.T) {
+	data := []byte{0x12, 0x34}
+	testCases := []struct {
+		name      string
+		startBit  int
+		bitLength int
+		expected  uint64
+	}{
+		{"High Nibble", 7, 4, 0x1},
+		{"Low Nibble", 3, 4, 0x2},
+		{"12 Bits Across Bytes", 7, 12, 0x123},
+		{"Full 16 Bits", 7, 16, 0x1234},
+		{"Nibble Across Bytes", 1, 4, 0x8},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := BigEndianBitsToUnsignedInt(data, tc.startBit, tc.bitLength)
+			if v != tc.expected {
+				t.Errorf("Expected 0x%X, got 0x%X", tc.expected, v)
+			}
+		})
+	}
+}
+
+func TestUnsignedIntToBits(t *testing.T) {
+	t.Run("Test Little Endian Round Trip", func(t *testing.T) {
+		data := make([]byte, 2)
+		err := LittleEndianUnsignedIntToBits(data, 4, 12, 0x341)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x10, 0x34}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("Expected %v, got %v", expected, data)
+		}
+	})
+	t.Run("Test Big Endian Round Trip", func(t *testing.T) {
+		data := make([]byte, 2)
+		err := BigEndianUnsignedIntToBits(data, 7, 12, 0x123)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x12, 0x30}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("Expected %v, got %v", expected, data)
+		}
+	})
+	t.Run("Test Preserves Other Bits", func(t *testing.T) {
+		data := []byte{0xFF}
+		err := LittleEndianUnsignedIntToBits(data, 2, 3, 0)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0xE3}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("Expected %v, got %v", expected, data)
+		}
+	})
+	t.Run("Test Does Not Fit", func(t *testing.T) {
+		err := BigEndianUnsignedIntToBits(make([]byte, 1), 7, 12, 0)
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestBitSpan(t *testing.T) {
+	if v := BitSpan(4, 12, false); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+	if v := BitSpan(7, 12, true); v != 2 {
+		t.Errorf("Expected 2, got %d", v)
+	}
+	if v := BitSpan(39, 4, true); v != 5 {
+		t.Errorf("Expected 5, got %d", v)
+	}
+	if v := BitSpan(0, 0, false); v != 0 {
+		t.Errorf("Expected 0, got %d", v)
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value float32
+		bits  uint16
+	}{
+		{"Test 0", 0, 0x0000},
+		{"Test 1", 1, 0x3C00},
+		{"Test -2", -2, 0xC000},
+		{"Test 0.5", 0.5, 0x3800},
+		{"Test Max", 65504, 0x7BFF},
+		{"Test Smallest Subnormal", float32(math.Pow(2, -24)), 0x0001},
+		{"Test Largest Subnormal", float32(math.Pow(2, -14) * 1023 / 1024), 0x03FF},
+		{"Test Infinity", float32(math.Inf(1)), 0x7C00},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if v := Float32ToFloat16(tc.value); v != tc.bits {
+				t.Errorf("Expected 0x%04X, got 0x%04X", tc.bits, v)
+			}
+			if v := Float16ToFloat32(tc.bits); v != tc.value {
+				t.Errorf("Expected %v, got %v", tc.value, v)
+			}
+		})
+	}
+	t.Run("Test Overflow", func(t *testing.T) {
+		if v := Float32ToFloat16(100000); v != 0x7C00 {
+			t.Errorf("Expected 0x7C00, got 0x%04X", v)
+		}
+	})
+	t.Run("Test Rounding", func(t *testing.T) {
+		if v := Float32ToFloat16(0.1); v != 0x2E66 {
+			t.Errorf("Expected 0x2E66, got 0x%04X", v)
+		}
+	})
+	t.Run("Test NaN", func(t *testing.T) {
+		if v := Float16ToFloat32(Float32ToFloat16(float32(math.NaN()))); !math.IsNaN(float64(v)) {
+			t.Errorf("Expected NaN, got %v", v)
+		}
+	})
+}
+
+func TestFloatToBinary(t *testing.T) {
+	t.Run("Test Big Endian Single", func(t *testing.T) {
+		v, err := BigEndianFloatToBinary(1.5, 4)
+		expected := []byte{0x3F, 0xC0, 0x00, 0x00}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+	})
+	t.Run("Test Little Endian Single", func(t *testing.T) {
+		v, err := LittleEndianFloatToBinary(1.5, 4)
+		expected := []byte{0x00, 0x00, 0xC0, 0x3F}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+	})
+	t.Run("Test Big Endian Half", func(t *testing.T) {
+		v, err := BigEndianFloatToBinary(-2, 2)
+		expected := []byte{0xC0, 0x00}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+	})
+	t.Run("Test Little Endian Double", func(t *testing.T) {
+		v, err := LittleEndianFloatToBinary(-118.5, 8)
+		expected := make([]byte, 8)
+		binary.LittleEndian.PutUint64(expected, math.Float64bits(-118.5))
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+	})
+	t.Run("Test Invalid Size", func(t *testing.T) {
+		_, err := BigEndianFloatToBinary(1.5, 3)
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestBytesToFloat(t *testing.T) {
+	t.Run("Test Big Endian Single", func(t *testing.T) {
+		v, err := BigEndianBytesToFloat([]byte{0x3F, 0xC0, 0x00, 0x00})
+		if err != nil || v != 1.5 {
+			t.Errorf("Expected 1.5, got %v (%v)", v, err)
+		}
+	})
+	t.Run("Test Little Endian Half", func(t *testing.T) {
+		v, err := LittleEndianBytesToFloat([]byte{0x00, 0xC0})
+		if err != nil || v != -2 {
+			t.Errorf("Expected -2, got %v (%v)", v, err)
+		}
+	})
+	t.Run("Test Big Endian Double", func(t *testing.T) {
+		input := make([]byte, 8)
+		binary.BigEndian.PutUint64(input, math.Float64bits(34.4133))
+		v, err := BigEndianBytesToFloat(input)
+		if err != nil || v != 34.4133 {
+			t.Errorf("Expected 34.4133, got %v (%v)", v, err)
+		}
+	})
+	t.Run("Test Invalid Size", func(t *testing.T) {
+		_, err := LittleEndianBytesToFloat([]byte{0x00})
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestUint64ToBinary(t *testing.T) {
+	t.Run("Test Big Endian Max", func(t *testing.T) {
+		v, err := BigEndianUint64ToBinary(math.MaxUint64, 8)
+		expected := []byte{255, 255, 255, 255, 255, 255, 255, 255}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+	})
+	t.Run("Test Little Endian Above Int64", func(t *testing.T) {
+		v, err := LittleEndianUint64ToBinary(1<<63+1, 8)
+		expected := []byte{1, 0, 0, 0, 0, 0, 0, 128}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+	})
+	t.Run("Test Big Endian Padding", func(t *testing.T) {
+		v, err := BigEndianUint64ToBinary(0x0102, 10)
+		expected := []byte{0, 0, 0, 0, 0, 0, 0, 0, 1, 2}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+	})
+	t.Run("Test Number Too Large", func(t *testing.T) {
+		_, err := BigEndianUint64ToBinary(1<<40, 5)
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+		_, err = LittleEndianUint64ToBinary(256, 1)
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+	t.Run("Test 0 Bytes", func(t *testing.T) {
+		_, err := LittleEndianUint64ToBinary(0, 0)
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestBytesToUint64(t *testing.T) {
+	t.Run("Test Big Endian Max", func(t *testing.T) {
+		v, err := BigEndianBytesToUint64([]byte{255, 255, 255, 255, 255, 255, 255, 255})
+		if err != nil || v != math.MaxUint64 {
+			t.Errorf("Expected %v, got %v (%v)", uint64(math.MaxUint64), v, err)
+		}
+	})
+	t.Run("Test Little Endian Above Int64", func(t *testing.T) {
+		v, err := LittleEndianBytesToUint64([]byte{1, 0, 0, 0, 0, 0, 0, 128})
+		if err != nil || v != 1<<63+1 {
+			t.Errorf("Expected %v, got %v (%v)", uint64(1<<63+1), v, err)
+		}
+	})
+	t.Run("Test Zero Padding", func(t *testing.T) {
+		v, err := BigEndianBytesToUint64([]byte{0, 0, 1, 0, 0, 0, 0, 0, 0, 2})
+		if err != nil || v != 1<<56+2 {
+			t.Errorf("Expected %v, got %v (%v)", uint64(1<<56+2), v, err)
+		}
+		v, err = LittleEndianBytesToUint64([]byte{2, 0, 0, 0, 0, 0, 0, 1, 0, 0})
+		if err != nil || v != 1<<56+2 {
+			t.Errorf("Expected %v, got %v (%v)", uint64(1<<56+2), v, err)
+		}
+	})
+	t.Run("Test Too Large", func(t *testing.T) {
+		_, err := BigEndianBytesToUint64([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0})
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+		_, err = LittleEndianBytesToUint64([]byte{0, 0, 0, 0, 0, 0, 0, 0, 1})
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
+
+func TestBigInt(t *testing.T) {
+	num, _ := new(big.Int).SetString("0102030405060708090A", 16)
+	t.Run("Test Big Endian", func(t *testing.T) {
+		v, err := BigEndianBigIntToBinary(num, 12)
+		expected := []byte{0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+		if BigEndianBytesToBigInt(v).Cmp(num) != 0 {
+			t.Errorf("Expected %v, got %v", num, BigEndianBytesToBigInt(v))
+		}
+	})
+	t.Run("Test Little Endian", func(t *testing.T) {
+		v, err := LittleEndianBigIntToBinary(num, 10)
+		expected := []byte{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, v, err)
+		}
+		if LittleEndianBytesToBigInt(v).Cmp(num) != 0 {
+			t.Errorf("Expected %v, got %v", num, LittleEndianBytesToBigInt(v))
+		}
+	})
+	t.Run("Test Number Too Large", func(t *testing.T) {
+		_, err := BigEndianBigIntToBinary(num, 9)
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+	t.Run("Test Negative Number", func(t *testing.T) {
+		_, err := LittleEndianBigIntToBinary(big.NewInt(-1), 9)
+		if err == nil {
+			t.Error("Expected error, got nil")
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package mapache
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// candumpErrorFlag marks error frames in the IDs of candump logs (CAN_ERR_FLAG).
+	candumpErrorFlag = 0x20000000
+	// candumpBitRateSwitch and candumpErrorStateIndicator are the CAN FD flags of candump logs (CANFD_BRS and CANFD_ESI).
+	candumpBitRateSwitch       = 0x1
+	candumpErrorStateIndicator = 0x2
+)
+
+// CandumpFrame is a timestamped raw frame read from a Linux candump log.
+type CandumpFrame struct {
+	// Time is the time at which the frame was received.
+	Time time.Time
+	// Interface is the name of the CAN interface that received the frame, such as "can0".
+	Interface string
+	Frame
+	// Remote frames request data from another node and carry no payload, so their DLC is the requested length.
+	Remote bool
+	// Error frames report bus errors, and their ID holds the error class instead of a message ID.
+	Error bool
+}
+
+// ParseCandumpLine parses a single line of a candump log, as written by candump -l, such as:
+//
+//	(1697040000.123456) can0 123#DEADBEEF
+//
+// Standard IDs are written with 3 hex digits and extended IDs with 8.
+// Remote frames are written as 123#R or 123#R<dlc>, and CAN FD frames as 123##<flags><data>.
+func ParseCandumpLine(line string) (CandumpFrame, error) {
+	parts := strings.Fields(line)
+	if len(parts) < 3 {
+		return CandumpFrame{}, fmt.Errorf("invalid candump line %q, expected (timestamp) interface frame", line)
+	}
+	timestamp, err := parseCandumpTime(parts[0])
+	if err != nil {
+		return CandumpFrame{}, err
+	}
+	frame, err := parseCandumpFrame(parts[2])
+	if err != nil {
+		return CandumpFrame{}, err
+	}
+	frame.Time = timestamp
+	frame.Interface = parts[1]
+	return frame, nil
+}
+
+// parseCandumpTime parses a candump timestamp in the form (seconds.fraction).
+func parseCandumpTime(s string) (time.Time, error) {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return time.Time{}, fmt.Errorf("invalid candump timestamp %q", s)
+	}
+	seconds, fraction, _ := strings.Cut(s[1:len(s)-1], ".")
+	if len(fraction) > 9 {
+		return time.Time{}, fmt.Errorf("invalid candump timestamp %q", s)
+	}
+	sec, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid candump timestamp %q", s)
+	}
+	nsec := int64(0)
+	if fraction != "" {
+		nsec, err = strconv.ParseInt(fraction+strings.Repeat("0", 9-len(fraction)), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid candump timestamp %q", s)
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// parseCandumpFrame parses a candump frame in the form <id>#<data>, <id>#R, or <id>##<flags><data>.
+func parseCandumpFrame(s string) (CandumpFrame, error) {
+	idString, payload, ok := strings.Cut(s, "#")
+	if !ok {
+		return CandumpFrame{}, fmt.Errorf("invalid candump frame %q, missing #", s)
+	}
+	id, err := strconv.ParseUint(idString, 16, 32)
+	if err != nil {
+		return CandumpFrame{}, fmt.Errorf("invalid candump id %q", idString)
+	}
+	frame := CandumpFrame{Frame: Frame{ID: int(id)}}
+	switch len(idString) {
+	case 3:
+		if frame.ID > 0x7FF {
+			return CandumpFrame{}, fmt.Errorf("invalid standard can id 0x%X", frame.ID)
+		}
+	case 8:
+		frame.Extended = true
+		frame.Error = frame.ID&candumpErrorFlag != 0
+		frame.ID &= 0x1FFFFFFF
+	default:
+		return CandumpFrame{}, fmt.Errorf("invalid candump id %q, expected 3 or 8 hex digits", idString)
+	}
+
+	if strings.HasPrefix(payload, "R") {
+		frame.Remote = true
+		if len(payload) > 1 {
+			frame.DLC, err = strconv.Atoi(payload[1:])
+			if err != nil || frame.DLC > 8 {
+				return CandumpFrame{}, fmt.Errorf("invalid candump remote dlc %q", payload[1:])
+			}
+		}
+		return frame, nil
+	} else if strings.HasPrefix(payload, "#") {
+		if len(payload) < 2 {
+			return CandumpFrame{}, fmt.Errorf("invalid candump fd frame %q, missing flags", s)
+		}
+		flags, err := strconv.ParseUint(payload[1:2], 16, 8)
+		if err != nil {
+			return CandumpFrame{}, fmt.Errorf("invalid candump fd flags %q", payload[1:2])
+		}
+		frame.FD = true
+		frame.BitRateSwitch = flags&candumpBitRateSwitch != 0
+		frame.ErrorStateIndicator = flags&candumpErrorStateIndicator != 0
+		payload = payload[2:]
+	}
+	frame.Data, err = hex.DecodeString(strings.ReplaceAll(payload, ".", ""))
+	if err != nil {
+		return CandumpFrame{}, fmt.Errorf("invalid candump data %q: %v", payload, err)
+	}
+	frame.DLC, err = LengthToDLC(len(frame.Data), frame.FD)
+	if err != nil {
+		return CandumpFrame{}, err
+	}
+	return frame, nil
+}
+
+// Signals decodes the frame into Signals using the given Message, with Timestamp and ProducedAt set to the time of the frame.
+// See Message.DecodeSignals for how the data length is handled.
+func (f CandumpFrame) Signals(message Message) ([]Signal, error) {
+	signals, err := message.DecodeSignals(f.Data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range signals {
+		signals[i].Timestamp = int(f.Time.UnixMicro())
+		signals[i].ProducedAt = f.Time
+	}
+	return signals, nil
+}
+
+// CandumpReader reads frames from a candump log one line at a time.
+type CandumpReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewCandumpReader creates a new CandumpReader that reads from r.
+func NewCandumpReader(r io.Reader) *CandumpReader {
+	return &CandumpReader{scanner: bufio.NewScanner(r)}
+}
+
+// Next returns the next frame of the log, skipping blank lines.
+// It returns io.EOF when there are no more frames, or an error with the line number if a line is invalid.
+func (r *CandumpReader) Next() (CandumpFrame, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		frame, err := ParseCandumpLine(line)
+		if err != nil {
+			return CandumpFrame{}, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return frame, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return CandumpFrame{}, err
+	}
+	return CandumpFrame{}, io.EOF
+}
+
+// ReadCandump reads every frame of a candump log.
+func ReadCandump(r io.Reader) ([]CandumpFrame, error) {
+	reader := NewCandumpReader(r)
+	frames := []CandumpFrame{}
+	for {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+}
+
+// DecodeCandump reads a candump log and decodes its frames into Signals, using the messages keyed by CAN ID and
+// frame format, so that standard and extended frames with the same numeric ID are decoded with their own message.
+// Frames with an ID that has no message, remote frames, and error frames are skipped.
+// It returns an error with the line number if a line is invalid or a frame cannot be decoded.
+func DecodeCandump(r io.Reader, messages map[CANKey]Message) ([]Signal, error) {
+	reader := NewCandumpReader(r)
+	signals := []Signal{}
+	for {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return signals, nil
+		} else if err != nil {
+			return nil, err
+		}
+		message, ok := messages[frame.Key()]
+		if !ok || frame.Remote || frame.Error {
+			continue
+		}
+		frameSignals, err := frame.Signals(message)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: failed to decode message 0x%X: %v", reader.line, frame.ID, err)
+		}
+		signals = append(signals, frameSignals...)
+	}
+}
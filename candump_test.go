@@ -0,0 +1,163 @@
+package mapache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCandump = `(1697040000.123456) can0 010#0382
+(1697040000.123500) can0 011#FF
+
+(1697040000.5) can1 12345678#DEADBEEF
+(1697040001.000001) can0 010#R
+(1697040001.000002) can0 20000004#0000000000000000
+(1697040001.250000) can0 010##1038200010203040506070809
+`
+
+func TestParseCandumpLine(t *testing.T) {
+	testCases := []struct {
+		line     string
+		expected CandumpFrame
+	}{
+		{"(1697040000.123456) can0 123#DEADBEEF", CandumpFrame{
+			Time: time.Unix(1697040000, 123456000), Interface: "can0",
+			Frame: Frame{ID: 0x123, DLC: 4, Data: []byte{0xDE, 0xAD, 0xBE, 0xEF}},
+		}},
+		{"(1697040000.5) vcan0 12345678#01.02", CandumpFrame{
+			Time: time.Unix(1697040000, 500000000), Interface: "vcan0",
+			Frame: Frame{ID: 0x12345678, Extended: true, DLC: 2, Data: []byte{0x01, 0x02}},
+		}},
+		{"(1697040000) can0 123#", CandumpFrame{
+			Time: time.Unix(1697040000, 0), Interface: "can0",
+			Frame: Frame{ID: 0x123, Data: []byte{}},
+		}},
+		{"(1697040000.000001) can0 7FF#R", CandumpFrame{
+			Time: time.Unix(1697040000, 1000), Interface: "can0",
+			Frame: Frame{ID: 0x7FF}, Remote: true,
+		}},
+		{"(1697040000.000001) can0 7FF#R4", CandumpFrame{
+			Time: time.Unix(1697040000, 1000), Interface: "can0",
+			Frame: Frame{ID: 0x7FF, DLC: 4}, Remote: true,
+		}},
+		{"(1697040000.000001) can0 20000004#0000", CandumpFrame{
+			Time: time.Unix(1697040000, 1000), Interface: "can0",
+			Frame: Frame{ID: 0x4, Extended: true, DLC: 2, Data: []byte{0x00, 0x00}}, Error: true,
+		}},
+		{"(1697040000.000001) can0 123##3" + strings.Repeat("AB", 12), CandumpFrame{
+			Time: time.Unix(1697040000, 1000), Interface: "can0",
+			Frame: Frame{ID: 0x123, DLC: 9, FD: true, BitRateSwitch: true, ErrorStateIndicator: true, Data: []byte{
+				0xAB, 0xAB, 0xAB, 0xAB, 0xAB, 0xAB, 0xAB, 0xAB, 0xAB, 0xAB, 0xAB, 0xAB,
+			}},
+		}},
+		{"(1697040000.000001) can0 123#11 R", CandumpFrame{
+			Time: time.Unix(1697040000, 1000), Interface: "can0",
+			Frame: Frame{ID: 0x123, DLC: 1, Data: []byte{0x11}},
+		}},
+	}
+	for _, tc := range testCases {
+		frame, err := ParseCandumpLine(tc.line)
+		if err != nil {
+			t.Errorf("Expected nil for %s, got %v", tc.line, err)
+		} else if !reflect.DeepEqual(frame, tc.expected) {
+			t.Errorf("Expected %+v, got %+v", tc.expected, frame)
+		}
+	}
+	invalid := []string{
+		"can0 123#DEADBEEF",
+		"1697040000.123456 can0 123#DEADBEEF",
+		"(1697040000.1234567890) can0 123#00",
+		"(abc) can0 123#00",
+		"(1697040000.123456) can0 123DEADBEEF",
+		"(1697040000.123456) can0 800#00",
+		"(1697040000.123456) can0 1234#00",
+		"(1697040000.123456) can0 XYZ#00",
+		"(1697040000.123456) can0 123#ABC",
+		"(1697040000.123456) can0 123#" + strings.Repeat("00", 9),
+		"(1697040000.123456) can0 123##",
+		"(1697040000.123456) can0 123##G00",
+		"(1697040000.123456) can0 123##0" + strings.Repeat("00", 65),
+		"(1697040000.123456) can0 123##0" + strings.Repeat("00", 13),
+		"(1697040000.123456) can0 123#R9",
+	}
+	for _, line := range invalid {
+		if _, err := ParseCandumpLine(line); err == nil {
+			t.Errorf("Expected error for %s, got nil", line)
+		}
+	}
+}
+
+func TestReadCandump(t *testing.T) {
+	t.Run("Test frames", func(t *testing.T) {
+		frames, err := ReadCandump(strings.NewReader(testCandump))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if len(frames) != 6 || frames[2].Interface != "can1" || !frames[3].Remote || !frames[4].Error || !frames[5].FD {
+			t.Errorf("Unexpected frames %+v", frames)
+		}
+	})
+	t.Run("Test invalid line", func(t *testing.T) {
+		_, err := ReadCandump(strings.NewReader("(1697040000.123456) can0 010#0382\n\ngarbage\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 3") {
+			t.Errorf("Expected error on line 3, got %v", err)
+		}
+	})
+}
+
+func TestDecodeCandump(t *testing.T) {
+	messages := map[CANKey]Message{
+		{ID: 0x10}: {
+			NewField("ecu_state", 1, Unsigned, BigEndian, nil),
+			NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC"),
+		},
+	}
+	t.Run("Test signals", func(t *testing.T) {
+		signals, err := DecodeCandump(strings.NewReader(testCandump), messages)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if len(signals) != 4 {
+			t.Fatalf("Expected 4 signals, got %+v", signals)
+		}
+		first := time.Unix(1697040000, 123456000)
+		if signals[0].Name != "ecu_state" || signals[0].Value != 3 || signals[1].Value != 32.5 {
+			t.Errorf("Unexpected signals %+v", signals)
+		}
+		if signals[0].Timestamp != 1697040000123456 || !signals[0].ProducedAt.Equal(first) {
+			t.Errorf("Unexpected time %d %v", signals[0].Timestamp, signals[0].ProducedAt)
+		}
+		if signals[2].Timestamp != 1697040001250000 || signals[3].RawValue != 0x82 {
+			t.Errorf("Unexpected fd signals %+v", signals[2:])
+		}
+	})
+	t.Run("Test short frame", func(t *testing.T) {
+		_, err := DecodeCandump(strings.NewReader("(1697040000.123456) can0 010#03\n"), messages)
+		if err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("Expected error on line 1, got %v", err)
+		}
+	})
+	t.Run("Test extended id", func(t *testing.T) {
+		log := "(1697040000.1) can0 00000010#0102\n(1697040000.2) can0 010#0304\n"
+		signals, err := DecodeCandump(strings.NewReader(log), messages)
+		if err != nil || len(signals) != 2 || signals[0].Value != 3 {
+			t.Errorf("Expected extended frame to be skipped, got %+v (%v)", signals, err)
+		}
+		extended := map[CANKey]Message{
+			{ID: 0x10}:                 messages[CANKey{ID: 0x10}],
+			{ID: 0x10, Extended: true}: {NewField("bms_current", 2, Unsigned, BigEndian, nil)},
+		}
+		signals, err = DecodeCandump(strings.NewReader(log), extended)
+		if err != nil || len(signals) != 3 || signals[0].Name != "bms_current" || signals[0].Value != 0x0102 || signals[1].Value != 3 {
+			t.Errorf("Unexpected signals %+v (%v)", signals, err)
+		}
+	})
+	t.Run("Test template untouched", func(t *testing.T) {
+		DecodeCandump(strings.NewReader(testCandump), messages)
+		template := messages[CANKey{ID: 0x10}][0]
+		if template.Bytes != nil || template.Value != 0 {
+			t.Errorf("Expected template to be untouched, got %+v", template)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+// Command mapache-gen generates typed Go structs for the messages of a DBC file or a YAML/JSON schema.
+//
+// It is meant to be used with go generate, for example:
+//
+//	//go:generate go run github.com/gaucho-racing/mapache-go/cmd/mapache-gen -dbc gr24.dbc -pkg gr24 -o messages.go
+//	//go:generate go run github.com/gaucho-racing/mapache-go/cmd/mapache-gen -schema gr24.yaml -pkg gr24 -o messages.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	mapache "github.com/gaucho-racing/mapache-go"
+)
+
+func main() {
+	dbcPath := flag.String("dbc", "", "path to the DBC file to generate messages from")
+	schemaPath := flag.String("schema", "", "path to the YAML or JSON schema to generate messages from")
+	pkg := flag.String("pkg", "", "name of the generated package (defaults to $GOPACKAGE)")
+	output := flag.String("o", "", "path of the generated file (defaults to stdout)")
+	flag.Parse()
+
+	if err := run(*dbcPath, *schemaPath, *pkg, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "mapache-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dbcPath string, schemaPath string, pkg string, output string) error {
+	if pkg == "" {
+		pkg = os.Getenv("GOPACKAGE")
+	}
+	if pkg == "" {
+		return fmt.Errorf("missing package name, use -pkg")
+	}
+	if (dbcPath == "") == (schemaPath == "") {
+		return fmt.Errorf("expected exactly one input, use -dbc or -schema")
+	}
+	messages, err := loadMessages(dbcPath, schemaPath)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		return mapache.GenerateGo(os.Stdout, pkg, messages)
+	}
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := mapache.GenerateGo(file, pkg, messages); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadMessages reads the messages to generate from either a DBC file or a schema.
+func loadMessages(dbcPath string, schemaPath string) ([]mapache.DBCMessage, error) {
+	messages := []mapache.DBCMessage{}
+	if dbcPath != "" {
+		dbc, err := mapache.ParseDBCFile(dbcPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range dbc.Messages {
+			messages = append(messages, message)
+		}
+		return messages, nil
+	}
+	schema, err := mapache.ParseSchemaFile(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, definition := range schema.Messages {
+		message, err := definition.Message()
+		if err != nil {
+			return nil, err
+		}
+		dbcMessage, err := mapache.NewDBCMessage(definition.ID, definition.Name, message)
+		if err != nil {
+			return nil, err
+		}
+		dbcMessage.Extended = definition.Extended
+		messages = append(messages, dbcMessage)
+	}
+	return messages, nil
+}
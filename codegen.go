@@ -0,0 +1,301 @@
+package mapache
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GenerateGo writes Go source code for the given package containing a typed struct for each message.
+// Each struct has one exported field per Field of the message, named after the Field in CamelCase:
+// flags fields become one bool per flag, scaled and Float fields become float64 physical values,
+// Unsigned fields wider than 8 bytes become *big.Int, and the remaining fields become int64 or uint64
+// raw values. The structs implement
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, and have a Signals method that exports them.
+// Custom ExportSignalFuncs cannot be generated, so fields using them are exported with the default behavior.
+// It returns an error if two messages or fields map to the same Go identifier, or if a field
+// cannot be held by a Go value without losing precision.
+func GenerateGo(w io.Writer, pkg string, messages []DBCMessage) error {
+	sorted := append([]DBCMessage{}, messages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].ID != sorted[j].ID {
+			return sorted[i].ID < sorted[j].ID
+		}
+		return !sorted[i].Extended && sorted[j].Extended
+	})
+
+	var body bytes.Buffer
+	typeNames := map[string]bool{}
+	usesBig := false
+	for _, message := range sorted {
+		typeName := goIdentifier(message.Name)
+		if typeNames[typeName] {
+			return fmt.Errorf("duplicate go type %s for message %s", typeName, message.Name)
+		}
+		typeNames[typeName] = true
+		if err := generateGoMessage(&body, typeName, message); err != nil {
+			return err
+		}
+		for _, field := range message.Message {
+			usesBig = usesBig || goFieldType(field) == "*big.Int"
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by mapache-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if len(sorted) > 0 {
+		buf.WriteString("import (\n\t\"fmt\"\n")
+		if usesBig {
+			buf.WriteString("\t\"math/big\"\n")
+		}
+		buf.WriteString("\n\tmapache \"github.com/gaucho-racing/mapache-go\"\n)\n")
+	}
+	buf.Write(body.Bytes())
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %v", err)
+	}
+	_, err = w.Write(source)
+	return err
+}
+
+// generateGoMessage writes the struct, template, and methods of a single message.
+func generateGoMessage(buf *bytes.Buffer, typeName string, message DBCMessage) error {
+	templateName := "message" + typeName
+	fieldNames := map[string]bool{}
+	goFields := make([][]string, len(message.Message))
+	for i, field := range message.Message {
+		if err := checkGoField(field); err != nil {
+			return fmt.Errorf("cannot generate field %s of message %s: %v", field.Name, message.Name, err)
+		}
+		names := []string{field.Name}
+		if len(field.Flags) > 0 {
+			names = field.Flags
+		}
+		for _, name := range names {
+			goName := goIdentifier(name)
+			if fieldNames[goName] {
+				return fmt.Errorf("duplicate go field %s in message %s", goName, message.Name)
+			}
+			fieldNames[goName] = true
+			goFields[i] = append(goFields[i], goName)
+		}
+	}
+
+	fmt.Fprintf(buf, "\n// %sID is the CAN ID of the %s message.\n", typeName, message.Name)
+	fmt.Fprintf(buf, "const %sID = 0x%X\n\n", typeName, message.ID)
+	fmt.Fprintf(buf, "// %s is the %s message.\n", typeName, message.Name)
+	fmt.Fprintf(buf, "type %s struct {\n", typeName)
+	for i, field := range message.Message {
+		for _, goName := range goFields[i] {
+			comment := ""
+			if field.Unit != "" {
+				comment = fmt.Sprintf(" // %s", field.Unit)
+			}
+			fmt.Fprintf(buf, "\t%s %s%s\n", goName, goFieldType(field), comment)
+		}
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "var %s = mapache.Message{\n", templateName)
+	for _, field := range message.Message {
+		fmt.Fprintf(buf, "\t%s,\n", goFieldLiteral(field))
+	}
+	buf.WriteString("}\n\n")
+
+	fmt.Fprintf(buf, "// UnmarshalBinary decodes the %s message from data.\n", message.Name)
+	fmt.Fprintf(buf, "func (m *%s) UnmarshalBinary(data []byte) error {\n", typeName)
+	fmt.Fprintf(buf, "\tmessage := %s.Copy()\n", templateName)
+	buf.WriteString("\tif len(data) < message.Size() {\n")
+	fmt.Fprintf(buf, "\t\treturn fmt.Errorf(\"invalid data length for %s, expected at least %%d bytes, got %%d\", message.Size(), len(data))\n", message.Name)
+	buf.WriteString("\t}\n")
+	buf.WriteString("\tif err := message.FillFromBytes(data[:message.Size()]); err != nil {\n\t\treturn err\n\t}\n")
+	for i, field := range message.Message {
+		for j, goName := range goFields[i] {
+			fmt.Fprintf(buf, "\tm.%s = %s\n", goName, goFieldDecoder(field, i, j))
+		}
+	}
+	buf.WriteString("\treturn nil\n}\n\n")
+
+	fmt.Fprintf(buf, "// MarshalBinary encodes the %s message into bytes.\n", message.Name)
+	fmt.Fprintf(buf, "func (m %s) MarshalBinary() ([]byte, error) {\n", typeName)
+	fmt.Fprintf(buf, "\tmessage := %s.Copy()\n", templateName)
+	if len(message.Message) > 0 {
+		buf.WriteString("\tvar err error\n")
+	}
+	for _, field := range message.Message {
+		if len(field.Flags) > 0 {
+			buf.WriteString("\tvar set []string\n")
+			break
+		}
+	}
+	for i, field := range message.Message {
+		buf.WriteString(goFieldEncoder(field, i, goFields[i]))
+	}
+	buf.WriteString("\treturn message.Bytes()\n}\n\n")
+
+	fmt.Fprintf(buf, "// Signals exports the %s message as a list of signals.\n", message.Name)
+	fmt.Fprintf(buf, "func (m %s) Signals() ([]mapache.Signal, error) {\n", typeName)
+	buf.WriteString("\tdata, err := m.MarshalBinary()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+	fmt.Fprintf(buf, "\treturn %s.DecodeSignals(data)\n}\n", templateName)
+	return nil
+}
+
+// checkGoField returns an error if the value of a field cannot be held by its generated Go type.
+// Only Unsigned raw values have a Go type wider than 64 bits, so other fields must fit in 8 bytes.
+func checkGoField(f Field) error {
+	if len(f.Flags) > 0 || f.IsBitField() || f.Size <= 8 {
+		return nil
+	} else if f.Sign == Signed {
+		return fmt.Errorf("signed fields wider than 8 bytes are not supported")
+	} else if f.Sign == Float {
+		return fmt.Errorf("float fields wider than 8 bytes are not supported")
+	} else if goFieldType(f) == "float64" {
+		return fmt.Errorf("scaled fields wider than 8 bytes are not supported")
+	}
+	return nil
+}
+
+// goFieldType returns the Go type used to hold the value of a field in a generated struct.
+func goFieldType(f Field) string {
+	if len(f.Flags) > 0 {
+		return "bool"
+	} else if f.Sign == Float || f.scale() != 1 || f.Offset != 0 {
+		return "float64"
+	} else if f.Sign == Signed {
+		return "int64"
+	} else if !f.IsBitField() && f.Size > 8 {
+		return "*big.Int"
+	}
+	return "uint64"
+}
+
+// goFieldDecoder returns the expression that reads a decoded field (or one of its flags) into a struct field.
+func goFieldDecoder(f Field, index int, flag int) string {
+	switch goFieldType(f) {
+	case "bool":
+		return fmt.Sprintf("message[%d].CheckFlag(%d) == 1", index, flag)
+	case "float64":
+		return fmt.Sprintf("message[%d].PhysicalValue()", index)
+	case "int64":
+		return fmt.Sprintf("int64(message[%d].Value)", index)
+	case "*big.Int":
+		return fmt.Sprintf("message[%d].BigIntValue()", index)
+	}
+	return fmt.Sprintf("message[%d].Uint64Value()", index)
+}
+
+// goFieldEncoder returns the statements that encode struct fields into the field of a message.
+func goFieldEncoder(f Field, index int, goNames []string) string {
+	var sb strings.Builder
+	encode := ""
+	switch goFieldType(f) {
+	case "bool":
+		sb.WriteString("\tset = set[:0]\n")
+		for j, goName := range goNames {
+			fmt.Fprintf(&sb, "\tif m.%s {\n\t\tset = append(set, %q)\n\t}\n", goName, f.Flags[j])
+		}
+		encode = fmt.Sprintf("message[%d].EncodeFlags(set...)", index)
+	case "float64":
+		encode = fmt.Sprintf("message[%d].EncodeValue(m.%s)", index, goNames[0])
+	case "int64":
+		fmt.Fprintf(&sb, "\tmessage[%d].Value = int(m.%s)\n", index, goNames[0])
+		encode = fmt.Sprintf("message[%d].Encode()", index)
+	case "*big.Int":
+		encode = fmt.Sprintf("message[%d].EncodeBigInt(m.%s)", index, goNames[0])
+	default:
+		encode = fmt.Sprintf("message[%d].EncodeUint64(m.%s)", index, goNames[0])
+	}
+	fmt.Fprintf(&sb, "\tif message[%d], err = %s; err != nil {\n\t\treturn nil, err\n\t}\n", index, encode)
+	return sb.String()
+}
+
+// goFieldLiteral returns a Go composite literal that recreates the field, without its ExportSignalFunc.
+func goFieldLiteral(f Field) string {
+	parts := []string{fmt.Sprintf("Name: %q", f.Name)}
+	if f.Size != 0 {
+		parts = append(parts, fmt.Sprintf("Size: %d", f.Size))
+	}
+	switch f.Sign {
+	case Signed:
+		parts = append(parts, "Sign: mapache.Signed")
+	case Unsigned:
+		parts = append(parts, "Sign: mapache.Unsigned")
+	case Float:
+		parts = append(parts, "Sign: mapache.Float")
+	}
+	if f.Endian == BigEndian {
+		parts = append(parts, "Endian: mapache.BigEndian")
+	} else {
+		parts = append(parts, "Endian: mapache.LittleEndian")
+	}
+	if f.IsBitField() {
+		parts = append(parts, fmt.Sprintf("StartBit: %d", f.StartBit), fmt.Sprintf("BitLength: %d", f.BitLength))
+	}
+	if f.Multiplexer {
+		parts = append(parts, "Multiplexer: true")
+	}
+	if f.Multiplexed {
+		parts = append(parts, "Multiplexed: true", fmt.Sprintf("MuxValue: %d", f.MuxValue))
+	}
+	numbers := []struct {
+		name  string
+		value float64
+	}{{"Factor", f.Factor}, {"Offset", f.Offset}, {"Min", f.Min}, {"Max", f.Max}}
+	for _, number := range numbers {
+		if number.value != 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", number.name, strconv.FormatFloat(number.value, 'g', -1, 64)))
+		}
+	}
+	if f.Unit != "" {
+		parts = append(parts, fmt.Sprintf("Unit: %q", f.Unit))
+	}
+	if len(f.ValueTable) > 0 {
+		values := make([]int, 0, len(f.ValueTable))
+		for value := range f.ValueTable {
+			values = append(values, value)
+		}
+		sort.Ints(values)
+		entries := []string{}
+		for _, value := range values {
+			entries = append(entries, fmt.Sprintf("%d: %q", value, f.ValueTable[value]))
+		}
+		parts = append(parts, fmt.Sprintf("ValueTable: mapache.ValueTable{%s}", strings.Join(entries, ", ")))
+	}
+	if len(f.Flags) > 0 {
+		flags := []string{}
+		for _, flag := range f.Flags {
+			flags = append(flags, strconv.Quote(flag))
+		}
+		parts = append(parts, fmt.Sprintf("Flags: []string{%s}", strings.Join(flags, ", ")))
+		if f.FlagOrder == LSBFirst {
+			parts = append(parts, "FlagOrder: mapache.LSBFirst")
+		}
+	}
+	return "mapache.Field{" + strings.Join(parts, ", ") + "}"
+}
+
+// goIdentifier converts a snake_case name into an exported CamelCase Go identifier,
+// keeping the case of each word (ecu_state becomes EcuState, ECU_Status becomes ECUStatus).
+func goIdentifier(name string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+	identifier := sb.String()
+	if identifier == "" || !unicode.IsLetter([]rune(identifier)[0]) {
+		identifier = "X" + identifier
+	}
+	return identifier
+}
@@ -0,0 +1,237 @@
+package mapache
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	dbc, err := ParseDBC(strings.NewReader(testDBC))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	messages := []DBCMessage{
+		dbc.Messages[CANKey{ID: 1}],
+		dbc.Messages[CANKey{ID: 0x1000, Extended: true}],
+		testDBCMessage(t, 0x20, "ECU_Flags", Message{
+			NewFlagsField("ecu_status_flags", 2, MSBFirst, []string{"ecu_status_acu", "ecu_status_dash"}),
+			NewField("ecu_odometer", 8, Unsigned, BigEndian, nil),
+			NewField("ecu_torque", 2, Signed, LittleEndian, nil),
+			NewField("ecu_lifetime_energy", 10, Unsigned, LittleEndian, nil),
+		}),
+	}
+	var sb strings.Builder
+	err = GenerateGo(&sb, "gr24", messages)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	source := sb.String()
+	t.Run("Test valid go", func(t *testing.T) {
+		_, err := parser.ParseFile(token.NewFileSet(), "messages.go", source, parser.AllErrors)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+	})
+	t.Run("Test types", func(t *testing.T) {
+		expected := []string{
+			"package gr24",
+			"const ECUStatusID = 0x1",
+			"type ECUStatus struct",
+			"EcuMaxCellTemp float64 // degC",
+			"EcuState       uint64",
+			"type ACUCellData struct",
+			"EcuStatusDash     bool",
+			"EcuTorque         int64",
+			"EcuLifetimeEnergy *big.Int",
+			"message[3].EncodeBigInt(m.EcuLifetimeEnergy)",
+			"func (m *ECUFlags) UnmarshalBinary(data []byte) error",
+			"func (m ECUFlags) MarshalBinary() ([]byte, error)",
+			"func (m ACUCellData) Signals() ([]mapache.Signal, error)",
+			`ValueTable: mapache.ValueTable{0: "GLV_OFF", 1: "GLV_ON", 3: "TS_ACTIVE"}`,
+			"Sign: mapache.Float",
+		}
+		for _, s := range expected {
+			if !strings.Contains(source, s) {
+				t.Errorf("Expected generated code to contain %q", s)
+			}
+		}
+	})
+	t.Run("Test compile and round trip", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("Skipping go toolchain test in short mode")
+		}
+		goTool, err := exec.LookPath("go")
+		if err != nil {
+			t.Skip("Skipping without a go toolchain")
+		}
+		root, _ := os.Getwd()
+		sum, err := os.ReadFile("go.sum")
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		dir := t.TempDir()
+		files := map[string]string{
+			"go.mod":           "module gentest\n\ngo 1.22\n\nrequire github.com/gaucho-racing/mapache-go v0.0.0\n\nreplace github.com/gaucho-racing/mapache-go => " + root + "\n",
+			"go.sum":           string(sum),
+			"messages.go":      source,
+			"messages_test.go": generatedRoundTripTest,
+		}
+		for name, contents := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+		}
+		cmd := exec.Command(goTool, "test", "./...")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("Expected generated package to pass its tests, got %v\n%s", err, output)
+		}
+	})
+	t.Run("Test unsupported wide fields", func(t *testing.T) {
+		for _, field := range []Field{
+			NewField("ecu_offset", 10, Signed, BigEndian, nil),
+			NewField("ecu_energy", 10, Unsigned, BigEndian, nil).WithScale(0.5, 0, "J"),
+			NewField("ecu_ratio", 16, Float, BigEndian, nil),
+		} {
+			err := GenerateGo(&strings.Builder{}, "gr24", []DBCMessage{testDBCMessage(t, 1, "ECU", Message{field})})
+			if err == nil {
+				t.Errorf("Expected error for %s, got nil", field.Name)
+			}
+		}
+	})
+	t.Run("Test ordered by id", func(t *testing.T) {
+		if strings.Index(source, "type ECUStatus") > strings.Index(source, "type ECUFlags") ||
+			strings.Index(source, "type ECUFlags") > strings.Index(source, "type ACUCellData") {
+			t.Errorf("Expected messages to be ordered by id")
+		}
+	})
+	t.Run("Test duplicate field", func(t *testing.T) {
+		err := GenerateGo(&strings.Builder{}, "gr24", []DBCMessage{testDBCMessage(t, 1, "ECU", Message{
+			NewField("ecu_state", 1, Unsigned, BigEndian, nil),
+			NewField("ecu__state", 1, Unsigned, BigEndian, nil),
+		})})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test duplicate message", func(t *testing.T) {
+		err := GenerateGo(&strings.Builder{}, "gr24", []DBCMessage{
+			testDBCMessage(t, 1, "ECU_Status", Message{}),
+			testDBCMessage(t, 2, "ECUStatus", Message{}),
+		})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test empty", func(t *testing.T) {
+		var sb strings.Builder
+		err := GenerateGo(&sb, "gr24", nil)
+		if err != nil || !strings.Contains(sb.String(), "package gr24") {
+			t.Errorf("Unexpected output %q (%v)", sb.String(), err)
+		}
+	})
+}
+
+func TestGoIdentifier(t *testing.T) {
+	testCases := map[string]string{
+		"ecu_state":  "EcuState",
+		"ECU_Status": "ECUStatus",
+		"acu-cell 1": "AcuCell1",
+		"1_cell":     "X1Cell",
+		"":           "X",
+	}
+	for input, expected := range testCases {
+		if v := goIdentifier(input); v != expected {
+			t.Errorf("Expected %s, got %s", expected, v)
+		}
+	}
+}
+
+// generatedRoundTripTest is compiled together with the generated messages, and checks that
+// MarshalBinary and UnmarshalBinary agree with decoding the same bytes through mapache.Message.
+const generatedRoundTripTest = `package gr24
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	mapache "github.com/gaucho-racing/mapache-go"
+)
+
+type generated interface {
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(data []byte) error
+	Signals() ([]mapache.Signal, error)
+}
+
+// roundTrip marshals in, unmarshals the bytes into out, and returns the bytes decoded by the template.
+func roundTrip(t *testing.T, template mapache.Message, in generated, out generated) mapache.Message {
+	data, err := in.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	message := template.Copy()
+	if err := message.FillFromBytes(data); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if err := out.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if again, err := out.MarshalBinary(); err != nil || !reflect.DeepEqual(again, data) {
+		t.Errorf("Expected %v, got %v (%v)", data, again, err)
+	}
+	signals, err := in.Signals()
+	expected, _ := template.DecodeSignals(data)
+	if err != nil || fmt.Sprint(signals) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v (%v)", expected, signals, err)
+	}
+	return message
+}
+
+func TestECUFlags(t *testing.T) {
+	energy, _ := new(big.Int).SetString("ffeeddccbbaa99887766", 16)
+	in := ECUFlags{EcuStatusDash: true, EcuOdometer: math.MaxUint64, EcuTorque: -12, EcuLifetimeEnergy: energy}
+	var out ECUFlags
+	message := roundTrip(t, messageECUFlags, &in, &out)
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Expected %+v, got %+v", in, out)
+	}
+	if message[0].CheckFlag(0) != 0 || message[0].CheckFlag(1) != 1 || message[1].Uint64Value() != math.MaxUint64 ||
+		message[2].Value != -12 || message[3].BigIntValue().Cmp(energy) != 0 {
+		t.Errorf("Unexpected decoded message %+v", message)
+	}
+	in.EcuLifetimeEnergy = new(big.Int).Lsh(big.NewInt(1), 80)
+	if _, err := in.MarshalBinary(); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+}
+
+func TestECUStatus(t *testing.T) {
+	in := ECUStatus{EcuState: 3, EcuPowerLevel: 9, EcuTorqueMap: 2, EcuMaxCellTemp: 30.25, EcuMotorTemp: 60.5}
+	var out ECUStatus
+	message := roundTrip(t, messageECUStatus, &in, &out)
+	if out.EcuState != 3 || out.EcuPowerLevel != 9 || out.EcuTorqueMap != 2 || out.EcuMaxCellTemp != 30.25 ||
+		out.EcuMotorTemp != message[4].PhysicalValue() || math.Abs(out.EcuMotorTemp-60.5) > 1e-9 {
+		t.Errorf("Unexpected decoded struct %+v", out)
+	}
+}
+
+func TestACUCellData(t *testing.T) {
+	in := ACUCellData{AcuCellVoltage: 3.7, AcuPackCurrent: -250}
+	var out ACUCellData
+	message := roundTrip(t, messageACUCellData, &in, &out)
+	if out.AcuCellVoltage != message[0].PhysicalValue() || math.Abs(out.AcuCellVoltage-3.7) > 1e-9 ||
+		out.AcuPackCurrent != -250 || message[1].PhysicalValue() != -250 {
+		t.Errorf("Unexpected decoded struct %+v from %+v", out, message)
+	}
+}
+`
@@ -0,0 +1,267 @@
+package mapache
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ChangeKind is a type to represent the kind of difference between two versions of a message layout.
+type ChangeKind int
+
+const (
+	// FieldAdded is a field that only exists in the next layout, which is compatible.
+	FieldAdded ChangeKind = iota
+	// FieldRemoved is a field that only exists in the previous layout, which is compatible.
+	FieldRemoved
+	// FieldMoved is a field whose start byte or start bit changed, which is breaking.
+	FieldMoved
+	// FieldResized is a field whose size in bits changed, which is breaking.
+	FieldResized
+	// EndianChanged is a field whose byte order changed, which is breaking.
+	EndianChanged
+	// SignChanged is a field whose SignMode changed, which is breaking.
+	SignChanged
+	// ScaleChanged is a field whose Factor or Offset changed, which is breaking.
+	ScaleChanged
+	// UnitChanged is a field whose Unit changed, which is compatible.
+	UnitChanged
+	// RangeChanged is a field whose Min or Max changed, which is compatible.
+	RangeChanged
+	// ValueTableChanged is a field whose ValueTable changed, which is compatible.
+	ValueTableChanged
+	// FlagsChanged is a field whose flags changed, which is breaking unless only trailing flags were added or dropped.
+	FlagsChanged
+	// MuxChanged is a field whose multiplexer role or MuxValue changed, which is breaking.
+	MuxChanged
+	// MessageResized is a message whose size changed, which is breaking if it grew and compatible if it shrank.
+	MessageResized
+)
+
+// Change is a single difference between two versions of a message layout.
+// Breaking changes are the ones that make frames encoded with the previous layout
+// decode to different values (or fail to decode) with the next layout.
+type Change struct {
+	// Field is the name of the changed field, or empty for changes to the whole message.
+	Field    string
+	Kind     ChangeKind
+	Breaking bool
+	// Detail is a human readable description of the change.
+	Detail string
+}
+
+// String returns the description of the change, marking breaking changes.
+func (c Change) String() string {
+	s := c.Detail
+	if c.Field != "" {
+		s = c.Field + ": " + s
+	}
+	if c.Breaking {
+		s += " (breaking)"
+	}
+	return s
+}
+
+// Compatibility is the result of comparing two versions of a message layout.
+type Compatibility struct {
+	Changes []Change
+}
+
+// Breaking returns true if any of the changes is breaking.
+func (c Compatibility) Breaking() bool {
+	for _, change := range c.Changes {
+		if change.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns one line per change, or "no changes" if the layouts are equivalent.
+func (c Compatibility) String() string {
+	if len(c.Changes) == 0 {
+		return "no changes"
+	}
+	lines := make([]string, len(c.Changes))
+	for i, change := range c.Changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CompareMessages diffs two versions of a Message and classifies each difference as compatible or breaking,
+// from the point of view of decoding frames recorded with the previous layout using the next one.
+// Fields are matched by name, so a renamed field shows up as removed and added.
+// Moving, resizing, or changing the endian, sign, scaling, flag positions, or multiplexing of a field is breaking,
+// as is growing the message, since frames recorded with the previous layout are then too short to decode.
+// Adding or removing fields within the previous size, and changing units, ranges, or value tables, is compatible.
+func CompareMessages(previous Message, next Message) Compatibility {
+	var c Compatibility
+	add := func(field string, kind ChangeKind, breaking bool, format string, args ...any) {
+		c.Changes = append(c.Changes, Change{Field: field, Kind: kind, Breaking: breaking, Detail: fmt.Sprintf(format, args...)})
+	}
+	previousOffsets := previous.offsets()
+	nextOffsets := next.offsets()
+	nextIndexes := map[string]int{}
+	for i, field := range next {
+		nextIndexes[field.Name] = i
+	}
+	previousNames := map[string]bool{}
+
+	for i, before := range previous {
+		previousNames[before.Name] = true
+		j, ok := nextIndexes[before.Name]
+		if !ok {
+			add(before.Name, FieldRemoved, false, "removed")
+			continue
+		}
+		after := next[j]
+		beforePosition, afterPosition := fieldPosition(before, previousOffsets[i]), fieldPosition(after, nextOffsets[j])
+		if beforePosition != afterPosition {
+			add(before.Name, FieldMoved, true, "moved from %s to %s", beforePosition, afterPosition)
+		}
+		if before.bitSize() != after.bitSize() {
+			add(before.Name, FieldResized, true, "resized from %d to %d bits", before.bitSize(), after.bitSize())
+		}
+		if before.Endian != after.Endian {
+			add(before.Name, EndianChanged, true, "endian changed from %s to %s", endianName(before.Endian), endianName(after.Endian))
+		}
+		if before.Sign != after.Sign {
+			add(before.Name, SignChanged, true, "sign changed from %s to %s", signName(before.Sign), signName(after.Sign))
+		}
+		if before.scale() != after.scale() || before.Offset != after.Offset {
+			add(before.Name, ScaleChanged, true, "scaling changed from factor %g offset %g to factor %g offset %g",
+				before.scale(), before.Offset, after.scale(), after.Offset)
+		}
+		if before.Unit != after.Unit {
+			add(before.Name, UnitChanged, false, "unit changed from %q to %q", before.Unit, after.Unit)
+		}
+		if before.Min != after.Min || before.Max != after.Max {
+			add(before.Name, RangeChanged, false, "range changed from [%g, %g] to [%g, %g]", before.Min, before.Max, after.Min, after.Max)
+		}
+		if !reflect.DeepEqual(before.ValueTable, after.ValueTable) && (len(before.ValueTable) > 0 || len(after.ValueTable) > 0) {
+			add(before.Name, ValueTableChanged, false, "value table changed")
+		}
+		if change, ok := compareFlags(before, after); ok {
+			c.Changes = append(c.Changes, change)
+		}
+		if before.Multiplexer != after.Multiplexer || before.Multiplexed != after.Multiplexed || (before.Multiplexed && before.MuxValue != after.MuxValue) {
+			add(before.Name, MuxChanged, true, "multiplexing changed from %s to %s", muxName(before), muxName(after))
+		}
+	}
+	for _, field := range next {
+		if !previousNames[field.Name] {
+			add(field.Name, FieldAdded, false, "added")
+		}
+	}
+	if next.Size() > previous.Size() {
+		add("", MessageResized, true, "message grew from %d to %d bytes", previous.Size(), next.Size())
+	} else if next.Size() < previous.Size() {
+		add("", MessageResized, false, "message shrank from %d to %d bytes", previous.Size(), next.Size())
+	}
+	return c
+}
+
+// fieldPosition describes where a field is placed within its message.
+func fieldPosition(f Field, offset int) string {
+	if f.IsBitField() {
+		return fmt.Sprintf("bit %d", f.StartBit)
+	}
+	return fmt.Sprintf("byte %d", offset)
+}
+
+// compareFlags compares the flags of two versions of a field.
+// Renaming, reordering, or renumbering existing flags is breaking, while adding or dropping trailing flags is compatible.
+func compareFlags(before Field, after Field) (Change, bool) {
+	if reflect.DeepEqual(before.Flags, after.Flags) && (len(before.Flags) == 0 || before.FlagOrder == after.FlagOrder) {
+		return Change{}, false
+	}
+	breaking := (len(before.Flags) == 0) != (len(after.Flags) == 0) || before.FlagOrder != after.FlagOrder
+	for i := 0; i < min(len(before.Flags), len(after.Flags)); i++ {
+		breaking = breaking || before.Flags[i] != after.Flags[i]
+	}
+	return Change{
+		Field:    before.Name,
+		Kind:     FlagsChanged,
+		Breaking: breaking,
+		Detail:   fmt.Sprintf("flags changed from [%s] to [%s]", strings.Join(before.Flags, " "), strings.Join(after.Flags, " ")),
+	}, true
+}
+
+// CheckVersions compares every message of the schema to the message with the same CANKey in the previous
+// schema, and returns an error listing the messages whose layout changed without a version bump.
+// Breaking changes require a higher version, while compatible changes only require the version not to decrease.
+// Messages that only exist in one of the schemas are ignored.
+func (s *Schema) CheckVersions(previous *Schema) error {
+	previousMessages := map[CANKey]MessageSchema{}
+	for _, message := range previous.Messages {
+		previousMessages[message.Key()] = message
+	}
+	problems := []string{}
+	for _, message := range s.Messages {
+		old, ok := previousMessages[message.Key()]
+		if !ok {
+			continue
+		}
+		c, err := old.Compare(message)
+		if err != nil {
+			return err
+		}
+		if message.Version < old.Version {
+			problems = append(problems, fmt.Sprintf("%s version decreased from %d to %d", message.Name, old.Version, message.Version))
+		} else if c.Breaking() && message.Version == old.Version {
+			problems = append(problems, fmt.Sprintf("%s has breaking changes without a version bump:\n%s", message.Name, c))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("incompatible schema: %s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// Compare diffs the definition with the next version of the same message. See CompareMessages.
+// It returns an error if either definition is invalid.
+func (m MessageSchema) Compare(next MessageSchema) (Compatibility, error) {
+	previousMessage, err := m.Message()
+	if err != nil {
+		return Compatibility{}, err
+	}
+	nextMessage, err := next.Message()
+	if err != nil {
+		return Compatibility{}, err
+	}
+	return CompareMessages(previousMessage, nextMessage), nil
+}
+
+// signName returns the schema name of a SignMode.
+func signName(sign SignMode) string {
+	for name, s := range schemaSignModes {
+		if s == sign {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", sign)
+}
+
+// endianName returns the schema name of an Endian.
+func endianName(endian Endian) string {
+	for name, e := range schemaEndians {
+		if e == endian {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", endian)
+}
+
+// muxName describes the multiplexing of a field.
+func muxName(f Field) string {
+	switch {
+	case f.Multiplexer && f.Multiplexed:
+		return fmt.Sprintf("multiplexer for mux value %d", f.MuxValue)
+	case f.Multiplexer:
+		return "multiplexer"
+	case f.Multiplexed:
+		return fmt.Sprintf("mux value %d", f.MuxValue)
+	}
+	return "none"
+}
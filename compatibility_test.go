@@ -0,0 +1,134 @@
+package mapache
+
+import (
+	"strings"
+	"testing"
+)
+
+func testCompatibilityMessage() Message {
+	return Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil).WithValueTable(ValueTable{0: "GLV_OFF"}),
+		NewFlagsField("ecu_status_flags", 1, MSBFirst, []string{"ecu_status_acu", "ecu_status_dash"}),
+		NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC"),
+		NewField("ecu_motor_temp", 2, Signed, LittleEndian, nil),
+		NewField("ecu_reserved", 2, Unsigned, BigEndian, nil),
+	}
+}
+
+func TestCompareMessages(t *testing.T) {
+	testCases := []struct {
+		name     string
+		change   func(m Message) Message
+		kinds    []ChangeKind
+		breaking bool
+	}{
+		{"no changes", func(m Message) Message { return m }, nil, false},
+		{"unit", func(m Message) Message { m[2].Unit = "C"; return m }, []ChangeKind{UnitChanged}, false},
+		{"range", func(m Message) Message { m[2] = m[2].WithRange(0, 60); return m }, []ChangeKind{RangeChanged}, false},
+		{"value table", func(m Message) Message { m[0].ValueTable = ValueTable{0: "GLV_OFF", 1: "GLV_ON"}; return m }, []ChangeKind{ValueTableChanged}, false},
+		{"scale", func(m Message) Message { m[2].Factor = 0.5; return m }, []ChangeKind{ScaleChanged}, true},
+		{"offset", func(m Message) Message { m[2].Offset = -40; return m }, []ChangeKind{ScaleChanged}, true},
+		{"endian", func(m Message) Message { m[3].Endian = BigEndian; return m }, []ChangeKind{EndianChanged}, true},
+		{"sign", func(m Message) Message { m[3].Sign = Unsigned; return m }, []ChangeKind{SignChanged}, true},
+		{"appended flag", func(m Message) Message {
+			m[1].Flags = []string{"ecu_status_acu", "ecu_status_dash", "ecu_status_inv"}
+			return m
+		}, []ChangeKind{FlagsChanged}, false},
+		{"reordered flags", func(m Message) Message { m[1].Flags = []string{"ecu_status_dash", "ecu_status_acu"}; return m }, []ChangeKind{FlagsChanged}, true},
+		{"flag order", func(m Message) Message { m[1].FlagOrder = LSBFirst; return m }, []ChangeKind{FlagsChanged}, true},
+		{"mux", func(m Message) Message { m[0] = m[0].AsMultiplexer(); return m }, []ChangeKind{MuxChanged}, true},
+		{"split reserved", func(m Message) Message {
+			return append(m[:4], NewField("ecu_torque_map", 1, Unsigned, BigEndian, nil), NewField("ecu_power_level", 1, Unsigned, BigEndian, nil))
+		}, []ChangeKind{FieldRemoved, FieldAdded, FieldAdded}, false},
+		{"removed trailing", func(m Message) Message { return m[:4] }, []ChangeKind{FieldRemoved, MessageResized}, false},
+		{"appended", func(m Message) Message {
+			return append(m, NewField("ecu_odometer", 4, Unsigned, BigEndian, nil))
+		}, []ChangeKind{FieldAdded, MessageResized}, true},
+		{"resized", func(m Message) Message { m[3].Size = 4; return m }, []ChangeKind{FieldResized, FieldMoved, MessageResized}, true},
+		{"removed middle", func(m Message) Message {
+			return append(append(Message{}, m[:2]...), m[3:]...)
+		}, []ChangeKind{FieldRemoved, FieldMoved, FieldMoved, MessageResized}, true},
+		{"bit field", func(m Message) Message {
+			m[4] = NewBitField("ecu_reserved", 47, 16, Unsigned, BigEndian, nil)
+			return m
+		}, []ChangeKind{FieldMoved}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := CompareMessages(testCompatibilityMessage(), tc.change(testCompatibilityMessage()))
+			kinds := []ChangeKind{}
+			for _, change := range c.Changes {
+				kinds = append(kinds, change.Kind)
+			}
+			if len(kinds) != len(tc.kinds) || c.Breaking() != tc.breaking {
+				t.Fatalf("Expected %v (breaking %t), got:\n%s", tc.kinds, tc.breaking, c)
+			}
+			for i := range kinds {
+				if kinds[i] != tc.kinds[i] {
+					t.Errorf("Expected %v (breaking %t), got:\n%s", tc.kinds, tc.breaking, c)
+				}
+			}
+		})
+	}
+}
+
+func TestCompatibilityString(t *testing.T) {
+	if s := (Compatibility{}).String(); s != "no changes" {
+		t.Errorf("Expected no changes, got %s", s)
+	}
+	previous := testCompatibilityMessage()
+	next := testCompatibilityMessage()
+	next[3].Endian = BigEndian
+	next[2].Unit = "C"
+	expected := "ecu_max_cell_temp: unit changed from \"degC\" to \"C\"\necu_motor_temp: endian changed from little to big (breaking)"
+	if s := CompareMessages(previous, next).String(); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+}
+
+func TestCheckVersions(t *testing.T) {
+	previous := &Schema{Messages: []MessageSchema{NewMessageSchema(0x10, "ECU_Status", testCompatibilityMessage())}}
+	previous.Messages[0].Version = 1
+	breaking := testCompatibilityMessage()
+	breaking[3].Sign = Unsigned
+	compatible := testCompatibilityMessage()
+	compatible[2].Unit = "C"
+	testCases := []struct {
+		name    string
+		message Message
+		version int
+		valid   bool
+	}{
+		{"unchanged", testCompatibilityMessage(), 1, true},
+		{"compatible", compatible, 1, true},
+		{"breaking without bump", breaking, 1, false},
+		{"breaking with bump", breaking, 2, true},
+		{"decreased", testCompatibilityMessage(), 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &Schema{Messages: []MessageSchema{
+				NewMessageSchema(0x10, "ECU_Status", tc.message),
+				NewMessageSchema(0x11, "BMS_Status", Message{NewField("bms_state", 1, Unsigned, BigEndian, nil)}),
+			}}
+			next.Messages[0].Version = tc.version
+			err := next.CheckVersions(previous)
+			if (err == nil) != tc.valid {
+				t.Errorf("Expected valid %t, got %v", tc.valid, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "ECU_Status") {
+				t.Errorf("Expected error to name the message, got %v", err)
+			}
+		})
+	}
+	t.Run("Test version in schema", func(t *testing.T) {
+		var sb strings.Builder
+		if err := WriteSchemaYAML(&sb, previous); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		parsed, err := ParseSchema(strings.NewReader(sb.String()))
+		if err != nil || parsed.Messages[0].Version != 1 {
+			t.Errorf("Expected version 1, got %+v (%v)", parsed, err)
+		}
+	})
+}
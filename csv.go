@@ -0,0 +1,397 @@
+package mapache
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVFill selects how samples missing from a row of a wide CSV are written.
+type CSVFill int
+
+const (
+	// BlankFill leaves missing samples blank.
+	BlankFill CSVFill = 0
+	// ForwardFill repeats the last sample of the signal for the same vehicle, or leaves it blank if there is none.
+	// Repeated samples are read back as new signals.
+	ForwardFill CSVFill = 1
+)
+
+// csvRawSuffix is appended to the name of a signal for its raw value column in the wide layout.
+const csvRawSuffix = ".raw"
+
+// csvWideColumns are the columns of the wide layout that are not signals.
+var csvWideColumns = []string{"timestamp", "vehicle_id", "produced_at"}
+
+// csvLongHeader is the header of the long layout.
+var csvLongHeader = []string{"timestamp", "vehicle_id", "name", "value", "raw_value", "produced_at"}
+
+// CSVWriter writes signals as CSV, one signal or row at a time.
+// The long layout has a row per signal with timestamp, vehicle_id, name, value, raw_value, and produced_at columns.
+// The wide layout has a row per vehicle and timestamp with timestamp, vehicle_id, and produced_at columns,
+// followed by a value and a raw value column (suffixed with ".raw") for each signal. Signals of a wide row share
+// its produced_at, so they must have the same ProducedAt, and signal names that would be read back as one of the
+// other columns (timestamp, vehicle_id, produced_at, or a name ending in ".raw") are rejected.
+// Timestamps are written in Unix microseconds, and ProducedAt in RFC 3339 with nanoseconds, or blank if it is zero.
+type CSVWriter struct {
+	// Fill selects how missing samples are written in the wide layout.
+	Fill CSVFill
+	// OmitRawValues leaves out the raw value columns of the wide layout.
+	OmitRawValues bool
+
+	csv     *csv.Writer
+	layout  SignalLayout
+	names   []string
+	columns map[string]bool
+	header  bool
+	// row holds the signals of the wide row being written, which is written once a signal of another row arrives
+	row           map[string]Signal
+	rowKey        wideRow
+	rowProducedAt time.Time
+	last          map[string]map[string]Signal
+}
+
+// NewCSVWriter creates a new CSVWriter with the given layout. The names are the signal columns of the wide layout,
+// in order, and are ignored by the long layout.
+func NewCSVWriter(w io.Writer, layout SignalLayout, names []string) *CSVWriter {
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		columns[name] = true
+	}
+	return &CSVWriter{
+		csv:     csv.NewWriter(w),
+		layout:  layout,
+		names:   names,
+		columns: columns,
+		row:     make(map[string]Signal),
+		last:    make(map[string]map[string]Signal),
+	}
+}
+
+// Write writes a signal. In the wide layout, signals of the same row have to be written one after another,
+// so signals should be sorted by timestamp, and the signal has to be one of the writer's columns with the same
+// ProducedAt as the other signals of its row.
+func (w *CSVWriter) Write(signal Signal) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if w.layout == LongLayout {
+		return w.csv.Write([]string{
+			strconv.Itoa(signal.Timestamp),
+			signal.VehicleID,
+			signal.Name,
+			formatCSVFloat(signal.Value),
+			strconv.Itoa(signal.RawValue),
+			formatCSVTime(signal.ProducedAt),
+		})
+	}
+	if !w.columns[signal.Name] {
+		return fmt.Errorf("signal %s is not a column of the csv", signal.Name)
+	}
+	key := wideRow{signal.Timestamp, signal.VehicleID}
+	if len(w.row) > 0 && key != w.rowKey {
+		if err := w.writeRow(); err != nil {
+			return err
+		}
+	} else if len(w.row) > 0 && !signal.ProducedAt.Equal(w.rowProducedAt) {
+		return fmt.Errorf("signal %s produced at %s in a row produced at %s", signal.Name, formatCSVTime(signal.ProducedAt), formatCSVTime(w.rowProducedAt))
+	}
+	w.rowKey = key
+	w.rowProducedAt = signal.ProducedAt
+	w.row[signal.Name] = signal
+	return nil
+}
+
+// Flush writes any pending row and flushes the underlying writer. It has to be called once every signal is written.
+func (w *CSVWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	if err := w.writeRow(); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+func (w *CSVWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	if w.layout == LongLayout {
+		w.header = true
+		return w.csv.Write(csvLongHeader)
+	}
+	for _, name := range w.names {
+		if err := checkCSVColumn(name); err != nil {
+			return err
+		}
+	}
+	w.header = true
+	header := append([]string{}, csvWideColumns...)
+	for _, name := range w.names {
+		header = append(header, name)
+		if !w.OmitRawValues {
+			header = append(header, name+csvRawSuffix)
+		}
+	}
+	return w.csv.Write(header)
+}
+
+// writeRow writes the pending row of the wide layout, if any.
+func (w *CSVWriter) writeRow() error {
+	if len(w.row) == 0 {
+		return nil
+	}
+	last, ok := w.last[w.rowKey.vehicleID]
+	if !ok {
+		last = map[string]Signal{}
+		w.last[w.rowKey.vehicleID] = last
+	}
+	record := []string{strconv.Itoa(w.rowKey.timestamp), w.rowKey.vehicleID, formatCSVTime(w.rowProducedAt)}
+	for _, name := range w.names {
+		signal, ok := w.row[name]
+		if ok {
+			last[name] = signal
+		} else if w.Fill == ForwardFill {
+			signal, ok = last[name]
+		}
+		if !ok {
+			record = append(record, "")
+			if !w.OmitRawValues {
+				record = append(record, "")
+			}
+			continue
+		}
+		record = append(record, formatCSVFloat(signal.Value))
+		if !w.OmitRawValues {
+			record = append(record, strconv.Itoa(signal.RawValue))
+		}
+	}
+	clear(w.row)
+	return w.csv.Write(record)
+}
+
+// checkCSVColumn returns an error if a signal name cannot be a column of the wide layout,
+// because CSVReader would read it back as one of the other columns.
+func checkCSVColumn(name string) error {
+	for _, column := range csvWideColumns {
+		if name == column {
+			return fmt.Errorf("signal %s cannot be a column of a wide csv", name)
+		}
+	}
+	if strings.HasSuffix(name, csvRawSuffix) {
+		return fmt.Errorf("signal %s cannot be a column of a wide csv, since it ends with %s", name, csvRawSuffix)
+	}
+	return nil
+}
+
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// WriteCSV writes the signals as CSV with the given layout. See CSVWriter.
+// For the wide layout, signal columns are sorted by name and rows by timestamp and vehicle, with blank missing samples.
+func WriteCSV(w io.Writer, signals []Signal, layout SignalLayout) error {
+	names := []string{}
+	if layout == WideLayout {
+		seen := map[string]bool{}
+		for _, signal := range signals {
+			if !seen[signal.Name] {
+				seen[signal.Name] = true
+				names = append(names, signal.Name)
+			}
+		}
+		sort.Strings(names)
+		signals = append([]Signal{}, signals...)
+		sort.SliceStable(signals, func(i, j int) bool {
+			if signals[i].Timestamp != signals[j].Timestamp {
+				return signals[i].Timestamp < signals[j].Timestamp
+			}
+			return signals[i].VehicleID < signals[j].VehicleID
+		})
+	}
+	writer := NewCSVWriter(w, layout, names)
+	for _, signal := range signals {
+		if err := writer.Write(signal); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// csvColumn is a signal column of a wide CSV, with the indexes of its value and raw value, or -1 if it has none.
+type csvColumn struct {
+	name  string
+	value int
+	raw   int
+}
+
+// CSVReader reads signals from CSV written by CSVWriter, one signal at a time.
+// The layout is detected from the header: it is long if it has name and value columns, and wide otherwise.
+// Columns are matched by name, so they may be in any order. In the wide layout, blank cells are skipped, and
+// signals without a raw value column have their value truncated to an integer as their RawValue.
+type CSVReader struct {
+	csv     *csv.Reader
+	layout  SignalLayout
+	header  map[string]int
+	columns []csvColumn
+	pending []Signal
+}
+
+// NewCSVReader creates a new CSVReader that reads from r.
+func NewCSVReader(r io.Reader) *CSVReader {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	return &CSVReader{csv: reader}
+}
+
+// Layout returns the layout of the CSV, reading its header if it has not been read yet.
+func (r *CSVReader) Layout() (SignalLayout, error) {
+	err := r.readHeader()
+	return r.layout, err
+}
+
+func (r *CSVReader) readHeader() error {
+	if r.header != nil {
+		return nil
+	}
+	record, err := r.csv.Read()
+	if err == io.EOF {
+		return fmt.Errorf("missing csv header")
+	} else if err != nil {
+		return err
+	}
+	r.header = map[string]int{}
+	for i, name := range record {
+		r.header[strings.TrimSpace(name)] = i
+	}
+	if _, ok := r.header["timestamp"]; !ok {
+		return fmt.Errorf("missing timestamp column in csv header")
+	}
+	_, hasName := r.header["name"]
+	_, hasValue := r.header["value"]
+	if hasName && hasValue {
+		r.layout = LongLayout
+		return nil
+	}
+	r.layout = WideLayout
+	for i, name := range record {
+		name = strings.TrimSpace(name)
+		if checkCSVColumn(name) != nil {
+			continue
+		}
+		column := csvColumn{name: name, value: i, raw: -1}
+		if raw, ok := r.header[name+csvRawSuffix]; ok {
+			column.raw = raw
+		}
+		r.columns = append(r.columns, column)
+	}
+	return nil
+}
+
+// Next returns the next signal of the CSV.
+// It returns io.EOF when there are no more signals, or an error with the line number if a record is invalid.
+func (r *CSVReader) Next() (Signal, error) {
+	if err := r.readHeader(); err != nil {
+		return Signal{}, err
+	}
+	for len(r.pending) == 0 {
+		record, err := r.csv.Read()
+		if err != nil {
+			return Signal{}, err
+		}
+		if err := r.parseRecord(record); err != nil {
+			line, _ := r.csv.FieldPos(0)
+			return Signal{}, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	signal := r.pending[0]
+	r.pending = r.pending[1:]
+	return signal, nil
+}
+
+// cell returns the trimmed cell of a column, or an empty string if the column is missing.
+func (r *CSVReader) cell(record []string, column string) string {
+	if i, ok := r.header[column]; ok {
+		return strings.TrimSpace(record[i])
+	}
+	return ""
+}
+
+// parseRecord parses the signals of a record into the pending signals.
+func (r *CSVReader) parseRecord(record []string) error {
+	base := Signal{VehicleID: r.cell(record, "vehicle_id")}
+	timestamp, err := strconv.Atoi(r.cell(record, "timestamp"))
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", r.cell(record, "timestamp"))
+	}
+	base.Timestamp = timestamp
+	if producedAt := r.cell(record, "produced_at"); producedAt != "" {
+		if base.ProducedAt, err = time.Parse(time.RFC3339Nano, producedAt); err != nil {
+			return fmt.Errorf("invalid produced_at %q", producedAt)
+		}
+	}
+
+	if r.layout == LongLayout {
+		signal := base
+		signal.Name = r.cell(record, "name")
+		if signal.Value, err = strconv.ParseFloat(r.cell(record, "value"), 64); err != nil {
+			return fmt.Errorf("invalid value %q", r.cell(record, "value"))
+		}
+		signal.RawValue = int(signal.Value)
+		if raw := r.cell(record, "raw_value"); raw != "" {
+			if signal.RawValue, err = strconv.Atoi(raw); err != nil {
+				return fmt.Errorf("invalid raw_value %q", raw)
+			}
+		}
+		r.pending = append(r.pending, signal)
+		return nil
+	}
+	for _, column := range r.columns {
+		value := strings.TrimSpace(record[column.value])
+		if value == "" {
+			continue
+		}
+		signal := base
+		signal.Name = column.name
+		if signal.Value, err = strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("invalid value %q for %s", value, column.name)
+		}
+		signal.RawValue = int(signal.Value)
+		if column.raw >= 0 && strings.TrimSpace(record[column.raw]) != "" {
+			raw := strings.TrimSpace(record[column.raw])
+			if signal.RawValue, err = strconv.Atoi(raw); err != nil {
+				return fmt.Errorf("invalid raw value %q for %s", raw, column.name)
+			}
+		}
+		r.pending = append(r.pending, signal)
+	}
+	return nil
+}
+
+// ReadCSV reads every signal of a CSV written by CSVWriter. See CSVReader.
+func ReadCSV(r io.Reader) ([]Signal, error) {
+	reader := NewCSVReader(r)
+	signals := []Signal{}
+	for {
+		signal, err := reader.Next()
+		if err == io.EOF {
+			return signals, nil
+		} else if err != nil {
+			return nil, err
+		}
+		signals = append(signals, signal)
+	}
+}
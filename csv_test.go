@@ -0,0 +1,177 @@
+package mapache
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCSVSignals() []Signal {
+	signals := []Signal{
+		{Timestamp: 1697040000000001, VehicleID: "gr24", Name: "ecu_speed", Value: 12.5, RawValue: 125},
+		{Timestamp: 1697040000000001, VehicleID: "gr24", Name: "acu_temp", Value: 30.1, RawValue: 301},
+		{Timestamp: 1697040000000001, VehicleID: "gr23", Name: "ecu_speed", Value: 5, RawValue: 50},
+		{Timestamp: 1697040000010002, VehicleID: "gr24", Name: "ecu_speed", Value: -0.1, RawValue: -1},
+		{Timestamp: 1697040000020003, VehicleID: "gr24", Name: "acu_temp", Value: 1.0 / 3, RawValue: 3},
+	}
+	for i := range signals {
+		signals[i].ProducedAt = time.UnixMicro(int64(signals[i].Timestamp)).Add(123 * time.Nanosecond)
+	}
+	return signals
+}
+
+// equalSignals compares signals, with ProducedAt compared as instants.
+func equalSignals(t *testing.T, expected []Signal, got []Signal) {
+	t.Helper()
+	if len(expected) != len(got) {
+		t.Fatalf("Expected %d signals, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		e, g := expected[i], got[i]
+		if !e.ProducedAt.Equal(g.ProducedAt) {
+			t.Errorf("Expected %v, got %v", e.ProducedAt, g.ProducedAt)
+		}
+		e.ProducedAt, g.ProducedAt = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(e, g) {
+			t.Errorf("Expected %+v, got %+v", e, g)
+		}
+	}
+}
+
+func TestCSVLong(t *testing.T) {
+	signals := testCSVSignals()
+	signals = append(signals, Signal{Timestamp: 5, Name: `name, "quoted"`, Value: 1e-9, RawValue: 7})
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, signals, LongLayout); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "timestamp,vehicle_id,name,value,raw_value,produced_at" ||
+		lines[1] != "1697040000000001,gr24,ecu_speed,12.5,125,2023-10-11T16:00:00.000001123Z" {
+		t.Errorf("Unexpected csv\n%s", buf.String())
+	}
+	read, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	equalSignals(t, signals, read)
+}
+
+func TestCSVWide(t *testing.T) {
+	signals := testCSVSignals()
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, signals, WideLayout); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	expected := strings.Join([]string{
+		"timestamp,vehicle_id,produced_at,acu_temp,acu_temp.raw,ecu_speed,ecu_speed.raw",
+		"1697040000000001,gr23,2023-10-11T16:00:00.000001123Z,,,5,50",
+		"1697040000000001,gr24,2023-10-11T16:00:00.000001123Z,30.1,301,12.5,125",
+		"1697040000010002,gr24,2023-10-11T16:00:00.010002123Z,,,-0.1,-1",
+		"1697040000020003,gr24,2023-10-11T16:00:00.020003123Z,0.3333333333333333,3,,",
+	}, "\n") + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, buf.String())
+	}
+	reader := NewCSVReader(strings.NewReader(expected))
+	if layout, err := reader.Layout(); err != nil || layout != WideLayout {
+		t.Errorf("Expected WideLayout, got %v (%v)", layout, err)
+	}
+	read, err := ReadCSV(strings.NewReader(expected))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	// rows are sorted by vehicle, and signals within a row by name
+	equalSignals(t, []Signal{signals[2], signals[1], signals[0], signals[3], signals[4]}, read)
+
+	t.Run("Test forward fill", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer := NewCSVWriter(&buf, WideLayout, []string{"ecu_speed", "acu_temp"})
+		writer.Fill = ForwardFill
+		writer.OmitRawValues = true
+		for _, signal := range []Signal{signals[0], signals[1], signals[2], signals[3], signals[4]} {
+			if err := writer.Write(signal); err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		expected := strings.Join([]string{
+			"timestamp,vehicle_id,produced_at,ecu_speed,acu_temp",
+			"1697040000000001,gr24,2023-10-11T16:00:00.000001123Z,12.5,30.1",
+			"1697040000000001,gr23,2023-10-11T16:00:00.000001123Z,5,",
+			"1697040000010002,gr24,2023-10-11T16:00:00.010002123Z,-0.1,30.1",
+			"1697040000020003,gr24,2023-10-11T16:00:00.020003123Z,-0.1,0.3333333333333333",
+		}, "\n") + "\n"
+		if buf.String() != expected {
+			t.Errorf("Expected\n%s\ngot\n%s", expected, buf.String())
+		}
+		read, err := ReadCSV(&buf)
+		if err != nil || len(read) != 7 || read[4].RawValue != 30 {
+			t.Errorf("Unexpected signals %+v (%v)", read, err)
+		}
+	})
+	t.Run("Test reserved column", func(t *testing.T) {
+		for _, name := range []string{"timestamp", "vehicle_id", "produced_at", "acu_temp.raw"} {
+			var buf bytes.Buffer
+			writer := NewCSVWriter(&buf, WideLayout, []string{"acu_temp", name})
+			if err := writer.Write(Signal{Timestamp: 1, VehicleID: "gr24", Name: name}); err == nil {
+				t.Errorf("Expected error for %s, got nil", name)
+			}
+			if err := writer.Flush(); err == nil || buf.Len() != 0 {
+				t.Errorf("Expected error without output for %s, got %v", name, err)
+			}
+		}
+	})
+	t.Run("Test different produced at", func(t *testing.T) {
+		writer := NewCSVWriter(&bytes.Buffer{}, WideLayout, []string{"acu_temp", "ecu_speed"})
+		if err := writer.Write(signals[0]); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		signal := signals[1]
+		signal.ProducedAt = signal.ProducedAt.Add(time.Millisecond)
+		if err := writer.Write(signal); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test unknown column", func(t *testing.T) {
+		writer := NewCSVWriter(&bytes.Buffer{}, WideLayout, []string{"acu_temp"})
+		if err := writer.Write(signals[0]); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestReadCSV(t *testing.T) {
+	t.Run("Test columns in any order", func(t *testing.T) {
+		input := "name,value,timestamp\necu_speed,1.5,10\n"
+		signals, err := ReadCSV(strings.NewReader(input))
+		expected := []Signal{{Timestamp: 10, Name: "ecu_speed", Value: 1.5, RawValue: 1}}
+		if err != nil || !reflect.DeepEqual(signals, expected) {
+			t.Errorf("Expected %+v, got %+v (%v)", expected, signals, err)
+		}
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		for _, input := range []string{
+			"",
+			"name,value\necu_speed,1\n",
+			"timestamp,name,value\nsoon,ecu_speed,1\n",
+			"timestamp,name,value\n1,ecu_speed,fast\n",
+			"timestamp,name,value,raw_value\n1,ecu_speed,1,1.5\n",
+			"timestamp,name,value,produced_at\n1,ecu_speed,1,yesterday\n",
+			"timestamp,ecu_speed\n1,2\n3,fast\n",
+			"timestamp,ecu_speed\n1,2,3\n",
+		} {
+			if _, err := ReadCSV(strings.NewReader(input)); err == nil {
+				t.Errorf("Expected error for %q, got nil", input)
+			}
+		}
+		_, err := ReadCSV(strings.NewReader("timestamp,ecu_speed\n1,2\n3,fast\n"))
+		if err == nil || !strings.Contains(err.Error(), "line 3") {
+			t.Errorf("Expected error on line 3, got %v", err)
+		}
+	})
+}
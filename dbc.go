@@ -0,0 +1,531 @@
+package mapache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DBC is a CAN database parsed from a Vector DBC file.
+// Each message in the database is converted into a Message of bit fields, so that
+// it can be used directly with FillFromBytes and ExportSignals.
+type DBC struct {
+	// Version is the version string declared by the file, if any.
+	Version string
+	// Nodes is the list of nodes (ECUs) declared on the bus.
+	Nodes []string
+	// Messages maps the CANKey of each message to its definition, so that a standard and an extended message
+	// may share the same numeric ID.
+	Messages map[CANKey]DBCMessage
+	// ValueTables are the named value tables declared with VAL_TABLE_.
+	ValueTables map[string]ValueTable
+}
+
+// DBCMessage is a single message definition from a DBC file.
+type DBCMessage struct {
+	// ID is the CAN ID of the message, without the extended frame flag.
+	ID int
+	// Extended is true if the message uses a 29-bit extended CAN ID.
+	Extended bool
+	// Name is the name of the message.
+	Name string
+	// Size is the number of data bytes in the message, as declared by the DBC file.
+	Size int
+	// Transmitter is the node that sends the message.
+	Transmitter string
+	// Message contains one bit field for each signal of the message, in the order they are declared.
+	// Value descriptions declared with VAL_ are stored in the ValueTable of each field.
+	Message Message
+}
+
+// dbcExtendedFlag is set on the CAN ID of extended frames in DBC files.
+const dbcExtendedFlag = 0x80000000
+
+// dbcIndependentSignalsID is the ID of the pseudo-message Vector tools use to hold unassigned signals.
+const dbcIndependentSignalsID = 0xC0000000
+
+var (
+	dbcMessageRegex = regexp.MustCompile(`^BO_\s+(\d+)\s+(\w+)\s*:\s*(\d+)\s+(\w+)`)
+	dbcSignalRegex  = regexp.MustCompile(`^SG_\s+(\w+)\s*(M|m\d+M?)?\s*:\s*(\d+)\s*\|\s*(\d+)\s*@\s*([01])\s*([+-])\s*\(\s*([^,\s]+)\s*,\s*([^)\s]+)\s*\)\s*\[\s*([^|\s]+)\s*\|\s*([^\]\s]+)\s*\]\s*"([^"]*)"`)
+	dbcValueRegex   = regexp.MustCompile(`(-?\d+)\s+"([^"]*)"`)
+)
+
+// ParseDBCFile opens the DBC file at the given path and parses it with ParseDBC.
+func ParseDBCFile(path string) (*DBC, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseDBC(file)
+}
+
+// ParseDBC parses a Vector DBC file into a DBC.
+// Messages, signals, value tables, value descriptions, and float signal types are supported.
+// Other sections, such as comments and attributes, are skipped.
+// It returns an error if a message or signal definition is malformed, if the signals of a message overlap
+// (see Message.Validate), or if two messages share a CANKey.
+func ParseDBC(r io.Reader) (*DBC, error) {
+	dbc := &DBC{
+		Messages:    map[CANKey]DBCMessage{},
+		ValueTables: map[string]ValueTable{},
+	}
+	// rawIDs maps the raw DBC ID (with the extended flag) to the key of Messages
+	rawIDs := map[int]CANKey{}
+	var current *DBCMessage
+	flush := func() {
+		if current != nil {
+			dbc.Messages[current.Key()] = *current
+			current = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	newSymbols := false
+	for scanner.Scan() {
+		lineNumber++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		keyword, _, _ := strings.Cut(line, " ")
+		keyword = strings.TrimSuffix(keyword, ":")
+
+		// the NS_ section is an indented list of keywords, which must not be parsed as statements
+		if newSymbols && (line == "" || raw[0] == ' ' || raw[0] == '\t') {
+			continue
+		}
+		newSymbols = keyword == "NS_"
+
+		// statements terminated by a semicolon may span multiple lines
+		switch keyword {
+		case "CM_", "BA_DEF_", "BA_DEF_DEF_", "BA_", "BA_DEF_REL_", "BA_DEF_DEF_REL_", "BA_REL_",
+			"VAL_", "VAL_TABLE_", "SIG_VALTYPE_", "SIG_GROUP_", "BO_TX_BU_", "EV_", "ENVVAR_DATA_", "SGTYPE_", "SIG_TYPE_REF_":
+			for !dbcStatementComplete(line) && scanner.Scan() {
+				lineNumber++
+				line += "\n" + scanner.Text()
+			}
+		}
+
+		switch keyword {
+		case "VERSION":
+			dbc.Version = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "VERSION")), `"`)
+		case "BU_":
+			_, nodes, _ := strings.Cut(line, ":")
+			dbc.Nodes = append(dbc.Nodes, strings.Fields(nodes)...)
+		case "BO_":
+			flush()
+			message, rawID, err := parseDBCMessage(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dbc message on line %d: %v", lineNumber, err)
+			}
+			if other, ok := dbc.Messages[message.Key()]; ok && rawID != dbcIndependentSignalsID {
+				return nil, fmt.Errorf("invalid dbc message on line %d: duplicate can id 0x%X for %s and %s", lineNumber, message.ID, other.Name, message.Name)
+			} else if rawID != dbcIndependentSignalsID {
+				current = &message
+				rawIDs[rawID] = message.Key()
+			}
+		case "SG_":
+			field, err := parseDBCSignal(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dbc signal on line %d: %v", lineNumber, err)
+			}
+			if current != nil {
+				current.Message = append(current.Message, field)
+			}
+		case "VAL_TABLE_":
+			flush()
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("invalid dbc value table on line %d", lineNumber)
+			}
+			dbc.ValueTables[fields[1]] = parseDBCValueTable(line)
+		case "VAL_":
+			flush()
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("invalid dbc value description on line %d", lineNumber)
+			}
+			rawID, err := strconv.Atoi(fields[1])
+			if err != nil {
+				// value descriptions for environment variables are not supported
+				continue
+			}
+			message, ok := dbc.lookupRaw(rawIDs, rawID)
+			if !ok {
+				continue
+			}
+			for i, field := range message.Message {
+				if field.Name == fields[2] {
+					message.Message[i] = field.WithValueTable(parseDBCValueTable(line))
+				}
+			}
+		case "SIG_VALTYPE_":
+			flush()
+			fields := strings.Fields(strings.NewReplacer(":", " ", ";", " ").Replace(line))
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("invalid dbc signal value type on line %d", lineNumber)
+			}
+			rawID, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid dbc signal value type on line %d: %v", lineNumber, err)
+			}
+			message, ok := dbc.lookupRaw(rawIDs, rawID)
+			if !ok {
+				continue
+			}
+			for i, field := range message.Message {
+				if field.Name == fields[2] && (fields[3] == "1" || fields[3] == "2") {
+					field.Sign = Float
+					message.Message[i] = field
+				}
+			}
+		default:
+			if keyword != "" {
+				flush()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	for _, key := range dbc.keys() {
+		if err := dbc.Messages[key].Message.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid layout of %s: %v", dbc.Messages[key].Name, err)
+		}
+	}
+	return dbc, nil
+}
+
+// lookupRaw returns the message with the given raw DBC ID, as written in the file.
+func (dbc *DBC) lookupRaw(rawIDs map[int]CANKey, rawID int) (DBCMessage, bool) {
+	key, ok := rawIDs[rawID]
+	if !ok {
+		return DBCMessage{}, false
+	}
+	message, ok := dbc.Messages[key]
+	return message, ok
+}
+
+// keys returns the keys of Messages in ascending order of CAN ID, with standard messages before extended ones.
+func (dbc *DBC) keys() []CANKey {
+	keys := make([]CANKey, 0, len(dbc.Messages))
+	for key := range dbc.Messages {
+		keys = append(keys, key)
+	}
+	sortCANKeys(keys)
+	return keys
+}
+
+// parseDBCMessage parses a BO_ line into a DBCMessage without any signals.
+// It also returns the raw ID as written in the file.
+func parseDBCMessage(line string) (DBCMessage, int, error) {
+	match := dbcMessageRegex.FindStringSubmatch(line)
+	if match == nil {
+		return DBCMessage{}, 0, fmt.Errorf("%q", line)
+	}
+	rawID, err := strconv.Atoi(match[1])
+	if err != nil {
+		return DBCMessage{}, 0, err
+	}
+	size, err := strconv.Atoi(match[3])
+	if err != nil {
+		return DBCMessage{}, 0, err
+	}
+	message := DBCMessage{
+		ID:          rawID &^ dbcExtendedFlag,
+		Extended:    rawID&dbcExtendedFlag != 0,
+		Name:        match[2],
+		Size:        size,
+		Transmitter: match[4],
+		Message:     Message{},
+	}
+	return message, rawID, nil
+}
+
+// parseDBCSignal parses an SG_ line into a bit field.
+func parseDBCSignal(line string) (Field, error) {
+	match := dbcSignalRegex.FindStringSubmatch(line)
+	if match == nil {
+		return Field{}, fmt.Errorf("%q", line)
+	}
+	startBit, err := strconv.Atoi(match[3])
+	if err != nil {
+		return Field{}, err
+	}
+	bitLength, err := strconv.Atoi(match[4])
+	if err != nil {
+		return Field{}, err
+	}
+	numbers := make([]float64, 4)
+	for i, s := range match[7:11] {
+		numbers[i], err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			return Field{}, err
+		}
+	}
+	endian := LittleEndian
+	if match[5] == "0" {
+		endian = BigEndian
+	}
+	sign := Unsigned
+	if match[6] == "-" {
+		sign = Signed
+	}
+	field := NewBitField(match[1], startBit, bitLength, sign, endian, nil).
+		WithScale(numbers[0], numbers[1], match[11]).
+		WithRange(numbers[2], numbers[3])
+	// extended multiplexing (m1M) is not supported, so such signals are only treated as multiplexed
+	if match[2] == "M" {
+		field = field.AsMultiplexer()
+	} else if match[2] != "" {
+		muxValue, err := strconv.Atoi(strings.TrimSuffix(match[2][1:], "M"))
+		if err != nil {
+			return Field{}, err
+		}
+		field = field.WithMuxValue(muxValue)
+	}
+	return field, nil
+}
+
+// parseDBCValueTable parses the value/description pairs of a VAL_ or VAL_TABLE_ statement.
+func parseDBCValueTable(line string) ValueTable {
+	table := ValueTable{}
+	for _, match := range dbcValueRegex.FindAllStringSubmatch(line, -1) {
+		value, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		table[value] = match[2]
+	}
+	return table
+}
+
+// dbcStatementComplete returns true if the statement ends with a semicolon outside of a quoted string.
+func dbcStatementComplete(statement string) bool {
+	quoted := false
+	complete := false
+	for i := 0; i < len(statement); i++ {
+		switch statement[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ';':
+			complete = !quoted
+		case ' ', '\t', '\r', '\n':
+		default:
+			if !quoted {
+				complete = false
+			}
+		}
+	}
+	return complete
+}
+
+// Decode decodes the data bytes of a frame into a list of Signals using a fresh copy of the message definition.
+// It returns an error if the data is shorter than the bytes covered by the signals of the message.
+func (d DBCMessage) Decode(data []byte) ([]Signal, error) {
+	signals, err := d.Message.DecodeSignals(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", d.Name, err)
+	}
+	return signals, nil
+}
+
+// dbcNameRegex matches valid DBC identifiers.
+var dbcNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// NewDBCMessage creates a new DBCMessage with the given ID, name, and message definition.
+// The size of the DBC message is taken from the size of the message definition.
+// It returns an error if the layout of the message is invalid (see Message.Validate).
+func NewDBCMessage(id int, name string, message Message) (DBCMessage, error) {
+	if err := message.Validate(); err != nil {
+		return DBCMessage{}, fmt.Errorf("invalid layout of %s: %v", name, err)
+	}
+	return DBCMessage{
+		ID:      id,
+		Name:    name,
+		Size:    message.Size(),
+		Message: message,
+	}, nil
+}
+
+// WriteDBCFile writes the DBC to a file at the given path with WriteDBC.
+func WriteDBCFile(path string, dbc *DBC) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteDBC(file, dbc); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteDBC serializes the DBC into the Vector DBC file format, such that it can be read back with ParseDBC.
+// Byte-aligned fields are written as signals covering the same bytes, and Float fields are written with the
+// matching SIG_VALTYPE_. Messages are written in order of their CAN ID. Custom ExportSignalFuncs cannot be
+// represented in a DBC file, so each field is written as a single signal.
+// It returns an error if a name is not a valid DBC identifier or a field cannot be represented as a DBC signal,
+// such as flags fields, fields longer than 64 bits, and value table labels containing quotes or newlines.
+func WriteDBC(w io.Writer, dbc *DBC) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "VERSION \"%s\"\n\n", dbc.Version)
+	sb.WriteString("NS_ :\n\tNS_DESC_\n\tCM_\n\tBA_DEF_\n\tBA_\n\tVAL_\n\tVAL_TABLE_\n\tSIG_VALTYPE_\n\n")
+	sb.WriteString("BS_:\n\n")
+	sb.WriteString("BU_:")
+	for _, node := range dbc.Nodes {
+		if !dbcNameRegex.MatchString(node) {
+			return fmt.Errorf("invalid dbc node name %q", node)
+		}
+		sb.WriteString(" " + node)
+	}
+	sb.WriteString("\n\n")
+
+	tableNames := make([]string, 0, len(dbc.ValueTables))
+	for name := range dbc.ValueTables {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+	for _, name := range tableNames {
+		if !dbcNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid dbc value table name %q", name)
+		}
+		table, err := formatDBCValueTable(dbc.ValueTables[name])
+		if err != nil {
+			return fmt.Errorf("invalid dbc value table %s: %v", name, err)
+		}
+		fmt.Fprintf(&sb, "VAL_TABLE_ %s%s ;\n", name, table)
+	}
+	if len(tableNames) > 0 {
+		sb.WriteString("\n")
+	}
+
+	var footer strings.Builder
+	for _, key := range dbc.keys() {
+		message := dbc.Messages[key]
+		if !dbcNameRegex.MatchString(message.Name) {
+			return fmt.Errorf("invalid dbc message name %q", message.Name)
+		} else if message.ID < 0 || (!message.Extended && message.ID > 0x7FF) || message.ID > 0x1FFFFFFF {
+			return fmt.Errorf("invalid can id 0x%X for %s", message.ID, message.Name)
+		}
+		rawID := message.ID
+		if message.Extended {
+			rawID |= dbcExtendedFlag
+		}
+		transmitter := message.Transmitter
+		if transmitter == "" {
+			transmitter = "Vector__XXX"
+		}
+		fmt.Fprintf(&sb, "BO_ %d %s: %d %s\n", rawID, message.Name, message.Size, transmitter)
+		for _, field := range dbcFields(message.Message) {
+			line, err := formatDBCSignal(field)
+			if err != nil {
+				return fmt.Errorf("invalid signal in %s: %v", message.Name, err)
+			}
+			sb.WriteString(line)
+			if field.Sign == Float {
+				valueType := 1
+				if field.BitLength == 64 {
+					valueType = 2
+				}
+				fmt.Fprintf(&footer, "SIG_VALTYPE_ %d %s : %d;\n", rawID, field.Name, valueType)
+			}
+			if len(field.ValueTable) > 0 {
+				table, err := formatDBCValueTable(field.ValueTable)
+				if err != nil {
+					return fmt.Errorf("invalid value table for %s in %s: %v", field.Name, message.Name, err)
+				}
+				fmt.Fprintf(&footer, "VAL_ %d %s%s ;\n", rawID, field.Name, table)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(footer.String())
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dbcFields returns the fields of a message with every byte-aligned field converted into
+// the equivalent bit field, so that it can be written as a DBC signal.
+func dbcFields(m Message) Message {
+	fields := Message{}
+	offsets := m.offsets()
+	for i, field := range m {
+		if !field.IsBitField() {
+			field.BitLength = field.Size * 8
+			field.StartBit = offsets[i] * 8
+			if field.Endian == BigEndian {
+				field.StartBit += 7
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// formatDBCSignal formats a bit field as an SG_ line.
+func formatDBCSignal(f Field) (string, error) {
+	if !dbcNameRegex.MatchString(f.Name) {
+		return "", fmt.Errorf("invalid dbc signal name %q", f.Name)
+	} else if strings.ContainsAny(f.Unit, "\"\n") {
+		return "", fmt.Errorf("invalid unit %q for %s", f.Unit, f.Name)
+	} else if len(f.Flags) > 0 {
+		return "", fmt.Errorf("flags field %s is not supported by dbc", f.Name)
+	} else if f.BitLength > 64 {
+		return "", fmt.Errorf("%d bit signal %s is not supported by dbc", f.BitLength, f.Name)
+	} else if f.Sign == Float && f.BitLength != 32 && f.BitLength != 64 {
+		return "", fmt.Errorf("%d bit float %s is not supported by dbc", f.BitLength, f.Name)
+	} else if f.Sign != Signed && f.Sign != Unsigned && f.Sign != Float {
+		return "", fmt.Errorf("invalid sign for %s", f.Name)
+	}
+	order := "1"
+	if f.Endian == BigEndian {
+		order = "0"
+	}
+	sign := "+"
+	if f.Sign != Unsigned {
+		sign = "-"
+	}
+	mux := ""
+	if f.Multiplexed {
+		mux = fmt.Sprintf(" m%d", f.MuxValue)
+	} else if f.Multiplexer {
+		mux = " M"
+	}
+	return fmt.Sprintf(" SG_ %s%s : %d|%d@%s%s (%s,%s) [%s|%s] \"%s\" Vector__XXX\n",
+		f.Name, mux, f.StartBit, f.BitLength, order, sign,
+		formatDBCNumber(f.scale()), formatDBCNumber(f.Offset),
+		formatDBCNumber(f.Min), formatDBCNumber(f.Max), f.Unit,
+	), nil
+}
+
+// formatDBCValueTable formats the value/description pairs of a value table in ascending order of value.
+// It returns an error if a description contains a quote or newline, which cannot be written in a DBC string.
+func formatDBCValueTable(table ValueTable) (string, error) {
+	values := make([]int, 0, len(table))
+	for value := range table {
+		values = append(values, value)
+	}
+	sort.Ints(values)
+	var sb strings.Builder
+	for _, value := range values {
+		if strings.ContainsAny(table[value], "\"\n") {
+			return "", fmt.Errorf("invalid description %q for value %d", table[value], value)
+		}
+		fmt.Fprintf(&sb, " %d \"%s\"", value, table[value])
+	}
+	return sb.String(), nil
+}
+
+func formatDBCNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
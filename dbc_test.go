@@ -0,0 +1,369 @@
+package mapache
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testDBC = `VERSION "gr24"
+
+NS_ :
+	NS_DESC_
+	CM_
+	BA_DEF_
+	BA_
+	VAL_
+	SIG_VALTYPE_
+
+BS_:
+
+BU_: ECU ACU DASH
+
+VAL_TABLE_ OnOff 0 "OFF" 1 "ON" ;
+
+BO_ 1 ECU_Status: 8 ECU
+ SG_ ecu_state : 0|8@1+ (1,0) [0|255] "" DASH
+ SG_ ecu_power_level : 39|4@0+ (1,0) [0|15] "" DASH
+ SG_ ecu_torque_map : 35|4@0+ (1,0) [0|15] "" DASH
+ SG_ ecu_max_cell_temp : 40|8@1+ (0.25,0) [0|63.75] "degC" DASH
+ SG_ ecu_motor_temp : 48|16@1- (0.1,-40) [-40|100] "degC" DASH,ACU
+
+BO_ 2147487744 ACU_Cell_Data: 8 ACU
+ SG_ acu_cell_voltage : 7|16@0+ (0.001,0) [0|5] "V" ECU
+ SG_ acu_pack_current : 16|32@1- (1,0) [0|0] "A" ECU
+
+BO_ 3221225472 VECTOR__INDEPENDENT_SIG_MSG: 0 Vector__XXX
+ SG_ unused : 0|8@1+ (1,0) [0|0] "" Vector__XXX
+
+CM_ BO_ 1 "ECU status message;
+spanning multiple lines";
+CM_ SG_ 1 ecu_state "State machine of the ECU";
+BA_DEF_ BO_ "GenMsgCycleTime" INT 0 65535;
+BA_ "GenMsgCycleTime" BO_ 1 100;
+VAL_ 1 ecu_state 0 "GLV_OFF" 1 "GLV_ON" 3 "TS_ACTIVE" ;
+SIG_VALTYPE_ 2147487744 acu_pack_current : 1;
+`
+
+// testDBCMessage creates a DBCMessage with NewDBCMessage and fails the test if its layout is invalid.
+func testDBCMessage(t *testing.T, id int, name string, message Message) DBCMessage {
+	t.Helper()
+	dbcMessage, err := NewDBCMessage(id, name, message)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	return dbcMessage
+}
+
+func TestParseDBC(t *testing.T) {
+	dbc, err := ParseDBC(strings.NewReader(testDBC))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	t.Run("Test header", func(t *testing.T) {
+		if dbc.Version != "gr24" {
+			t.Errorf("Expected version gr24, got %s", dbc.Version)
+		}
+		if len(dbc.Nodes) != 3 {
+			t.Errorf("Expected 3 nodes, got %d", len(dbc.Nodes))
+		}
+		if dbc.ValueTables["OnOff"][1] != "ON" {
+			t.Errorf("Expected ON, got %s", dbc.ValueTables["OnOff"][1])
+		}
+	})
+	t.Run("Test messages", func(t *testing.T) {
+		if len(dbc.Messages) != 2 {
+			t.Fatalf("Expected 2 messages, got %d", len(dbc.Messages))
+		}
+		status := dbc.Messages[CANKey{ID: 1}]
+		if status.Name != "ECU_Status" || status.Size != 8 || status.Transmitter != "ECU" || status.Extended {
+			t.Errorf("Unexpected message %+v", status)
+		}
+		if len(status.Message) != 5 {
+			t.Errorf("Expected 5 fields, got %d", len(status.Message))
+		}
+		if status.Message[0].ValueTable[3] != "TS_ACTIVE" {
+			t.Errorf("Expected TS_ACTIVE, got %s", status.Message[0].ValueTable[3])
+		}
+		cell, ok := dbc.Messages[CANKey{ID: 0x1000, Extended: true}]
+		if !ok || !cell.Extended {
+			t.Fatalf("Expected extended message 0x1000, got %+v", cell)
+		}
+		if cell.Message[0].Endian != BigEndian || cell.Message[1].Sign != Float {
+			t.Errorf("Unexpected fields %+v", cell.Message)
+		}
+	})
+	t.Run("Test fields", func(t *testing.T) {
+		field := dbc.Messages[CANKey{ID: 1}].Message[4]
+		if field.StartBit != 48 || field.BitLength != 16 || field.Sign != Signed || field.Endian != LittleEndian {
+			t.Errorf("Unexpected field %+v", field)
+		}
+		if field.Factor != 0.1 || field.Offset != -40 || field.Unit != "degC" || field.Min != -40 || field.Max != 100 {
+			t.Errorf("Unexpected scaling %+v", field)
+		}
+	})
+	t.Run("Test decode", func(t *testing.T) {
+		signals, err := dbc.Messages[CANKey{ID: 1}].Decode([]byte{0x03, 0x00, 0x00, 0x00, 0x31, 0x82, 0xE8, 0x03})
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		expected := []float64{3, 3, 1, 32.5, 60}
+		for i, signal := range signals {
+			if math.Abs(signal.Value-expected[i]) > 1e-9 {
+				t.Errorf("Expected %s %f, got %f", signal.Name, expected[i], signal.Value)
+			}
+		}
+	})
+	t.Run("Test decode float", func(t *testing.T) {
+		signals, err := dbc.Messages[CANKey{ID: 0x1000, Extended: true}].Decode([]byte{0x0F, 0xA0, 0x00, 0x00, 0xC0, 0x3F, 0x00, 0x00})
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if math.Abs(signals[0].Value-4) > 1e-9 {
+			t.Errorf("Expected 4, got %f", signals[0].Value)
+		}
+		if signals[1].Value != 1.5 {
+			t.Errorf("Expected 1.5, got %f", signals[1].Value)
+		}
+	})
+	t.Run("Test decode short data", func(t *testing.T) {
+		_, err := dbc.Messages[CANKey{ID: 1}].Decode([]byte{0x03})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestParseDBCInvalid(t *testing.T) {
+	t.Run("Invalid message", func(t *testing.T) {
+		_, err := ParseDBC(strings.NewReader("BO_ abc ECU_Status: 8 ECU\n"))
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Invalid signal", func(t *testing.T) {
+		_, err := ParseDBC(strings.NewReader("BO_ 1 ECU_Status: 8 ECU\n SG_ ecu_state : 0|8@2+ (1,0) [0|255] \"\" DASH\n"))
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Overlapping signals", func(t *testing.T) {
+		_, err := ParseDBC(strings.NewReader("BO_ 1 ECU_Status: 8 ECU\n SG_ ecu_state : 0|8@1+ (1,0) [0|255] \"\" DASH\n SG_ ecu_mode : 4|8@1+ (1,0) [0|255] \"\" DASH\n"))
+		if err == nil || !strings.Contains(err.Error(), "overlap") {
+			t.Errorf("Expected overlap error, got %v", err)
+		}
+	})
+	t.Run("Duplicate id", func(t *testing.T) {
+		_, err := ParseDBC(strings.NewReader("BO_ 256 ECU_Status: 8 ECU\nBO_ 256 ECU_Other: 8 ECU\n"))
+		if err == nil || !strings.Contains(err.Error(), "duplicate") {
+			t.Errorf("Expected duplicate error, got %v", err)
+		}
+	})
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := ParseDBCFile("does_not_exist.dbc")
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestDBCStandardAndExtended(t *testing.T) {
+	const input = `BO_ 256 ECU_Status: 1 ECU
+ SG_ ecu_state : 0|8@1+ (1,0) [0|255] "" DASH
+
+BO_ 2147483904 ECU_Extended: 4 ECU
+ SG_ ecu_speed : 0|32@1- (1,0) [0|0] "km/h" DASH
+
+VAL_ 256 ecu_state 0 "OFF" 1 "ON" ;
+SIG_VALTYPE_ 2147483904 ecu_speed : 1;
+`
+	dbc, err := ParseDBC(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	standard, extended := dbc.Messages[CANKey{ID: 0x100}], dbc.Messages[CANKey{ID: 0x100, Extended: true}]
+	if len(dbc.Messages) != 2 || standard.Name != "ECU_Status" || extended.Name != "ECU_Extended" || !extended.Extended {
+		t.Fatalf("Unexpected messages %+v", dbc.Messages)
+	}
+	if len(standard.Message[0].ValueTable) != 2 || standard.Message[0].Sign != Unsigned || extended.Message[0].Sign != Float {
+		t.Errorf("Expected VAL_ and SIG_VALTYPE_ to apply to their own messages, got %+v and %+v", standard, extended)
+	}
+	var sb strings.Builder
+	if err := WriteDBC(&sb, dbc); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	parsed, err := ParseDBC(strings.NewReader(sb.String()))
+	if err != nil || !reflect.DeepEqual(parsed, dbc) {
+		t.Errorf("Expected %+v, got %+v (%v)", dbc, parsed, err)
+	}
+	sb.Reset()
+	if err := WriteSchemaYAML(&sb, NewSchema(dbc)); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	schema, err := ParseSchema(strings.NewReader(sb.String()))
+	if err != nil || len(schema.Messages) != 2 || schema.Messages[0].Extended || !schema.Messages[1].Extended {
+		t.Errorf("Expected standard and extended schema messages, got %+v (%v)", schema, err)
+	}
+}
+
+func TestWriteDBC(t *testing.T) {
+	t.Run("Test round trip", func(t *testing.T) {
+		dbc, err := ParseDBC(strings.NewReader(testDBC))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		var sb strings.Builder
+		err = WriteDBC(&sb, dbc)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		parsed, err := ParseDBC(strings.NewReader(sb.String()))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(dbc, parsed) {
+			t.Errorf("Expected %+v, got %+v", dbc, parsed)
+		}
+	})
+	t.Run("Test go messages", func(t *testing.T) {
+		dbc := &DBC{
+			Nodes: []string{"ECU"},
+			Messages: map[CANKey]DBCMessage{
+				{ID: 0x10}: testDBCMessage(t, 0x10, "ECU_Temps", Message{
+					NewField("ecu_state", 1, Unsigned, BigEndian, nil),
+					NewField("ecu_motor_temp", 2, Signed, BigEndian, nil).WithScale(0.1, -40, "degC"),
+					NewField("ecu_inverter_temp", 2, Unsigned, LittleEndian, nil).WithScale(0.5, 0, "degC").WithRange(0, 150),
+					NewField("ecu_current", 4, Float, LittleEndian, nil).WithScale(1, 0, "A"),
+				}),
+			},
+		}
+		var sb strings.Builder
+		err := WriteDBC(&sb, dbc)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		parsed, err := ParseDBC(strings.NewReader(sb.String()))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		message := parsed.Messages[CANKey{ID: 0x10}]
+		if message.Size != 9 || len(message.Message) != 4 {
+			t.Fatalf("Unexpected message %+v", message)
+		}
+		data := []byte{0x03, 0x03, 0xE8, 0x2C, 0x01, 0x00, 0x00, 0xC0, 0x3F}
+		original := append(Message{}, dbc.Messages[CANKey{ID: 0x10}].Message...)
+		err = original.FillFromBytes(data)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		signals, err := message.Decode(data)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		for i, signal := range original.ExportSignals() {
+			if signal.Name != signals[i].Name || math.Abs(signal.Value-signals[i].Value) > 1e-9 {
+				t.Errorf("Expected %s %f, got %s %f", signal.Name, signal.Value, signals[i].Name, signals[i].Value)
+			}
+		}
+	})
+	t.Run("Test invalid name", func(t *testing.T) {
+		dbc := &DBC{Messages: map[CANKey]DBCMessage{{ID: 1}: testDBCMessage(t, 1, "ECU Status", Message{})}}
+		err := WriteDBC(&strings.Builder{}, dbc)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test half float", func(t *testing.T) {
+		dbc := &DBC{Messages: map[CANKey]DBCMessage{{ID: 1}: testDBCMessage(t, 1, "IMU", Message{NewField("imu_accel", 2, Float, BigEndian, nil)})}}
+		err := WriteDBC(&strings.Builder{}, dbc)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test flags field", func(t *testing.T) {
+		dbc := &DBC{Messages: map[CANKey]DBCMessage{{ID: 1}: testDBCMessage(t, 1, "ECU", Message{
+			NewFlagsField("ecu_status_flags", 1, MSBFirst, []string{"ecu_status_acu", "ecu_status_dash"}),
+		})}}
+		err := WriteDBC(&strings.Builder{}, dbc)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test quoted label", func(t *testing.T) {
+		table := ValueTable{0: "OFF", 1: `"ON"`}
+		dbc := &DBC{Messages: map[CANKey]DBCMessage{{ID: 1}: testDBCMessage(t, 1, "ECU", Message{
+			NewField("ecu_state", 1, Unsigned, BigEndian, nil).WithValueTable(table),
+		})}}
+		if err := WriteDBC(&strings.Builder{}, dbc); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		dbc = &DBC{ValueTables: map[string]ValueTable{"States": table}}
+		if err := WriteDBC(&strings.Builder{}, dbc); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test wider than 64 bits", func(t *testing.T) {
+		dbc := &DBC{Messages: map[CANKey]DBCMessage{{ID: 1}: testDBCMessage(t, 1, "ECU", Message{NewField("ecu_serial", 9, Unsigned, BigEndian, nil)})}}
+		err := WriteDBC(&strings.Builder{}, dbc)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test invalid standard id", func(t *testing.T) {
+		dbc := &DBC{Messages: map[CANKey]DBCMessage{{ID: 0x1000}: testDBCMessage(t, 0x1000, "ECU", Message{})}}
+		err := WriteDBC(&strings.Builder{}, dbc)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test write file", func(t *testing.T) {
+		path := t.TempDir() + "/test.dbc"
+		dbc, _ := ParseDBC(strings.NewReader(testDBC))
+		err := WriteDBCFile(path, dbc)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		parsed, err := ParseDBCFile(path)
+		if err != nil || len(parsed.Messages) != 2 {
+			t.Errorf("Expected 2 messages, got %v (%v)", parsed, err)
+		}
+	})
+}
+
+func TestDBCMultiplexing(t *testing.T) {
+	const muxDBC = `BO_ 200 BMS_Cells: 8 ACU
+ SG_ bms_cell_group M : 0|8@1+ (1,0) [0|255] "" ECU
+ SG_ bms_cell_0_voltage m0 : 8|16@1+ (0.001,0) [0|5] "V" ECU
+ SG_ bms_cell_1_voltage m1 : 8|16@1+ (0.001,0) [0|5] "V" ECU
+ SG_ bms_cell_2_voltage m1M : 24|16@1+ (0.001,0) [0|5] "V" ECU
+`
+	dbc, err := ParseDBC(strings.NewReader(muxDBC))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	message := dbc.Messages[CANKey{ID: 200}]
+	if !message.Message[0].Multiplexer || !message.Message[1].Multiplexed || message.Message[2].MuxValue != 1 {
+		t.Errorf("Unexpected fields %+v", message.Message)
+	}
+	signals, err := message.Decode([]byte{0x01, 0xA0, 0x0F, 0xA1, 0x0F, 0x00, 0x00, 0x00})
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if len(signals) != 3 || signals[1].Name != "bms_cell_1_voltage" || math.Abs(signals[1].Value-4) > 1e-9 {
+		t.Errorf("Unexpected signals %v", signals)
+	}
+
+	var sb strings.Builder
+	err = WriteDBC(&sb, dbc)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	parsed, err := ParseDBC(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if !reflect.DeepEqual(dbc, parsed) {
+		t.Errorf("Expected %+v, got %+v", dbc, parsed)
+	}
+}
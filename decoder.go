@@ -0,0 +1,86 @@
+package mapache
+
+import "fmt"
+
+// Decoder is a compiled form of a Message for high-rate decoding.
+// The layout of the message (field offsets, size, and multiplexer) is computed once when the
+// Decoder is created, and each frame is decoded straight into a caller-provided slice of Signals
+// without allocating, as long as the slice has enough capacity and no field uses a custom
+// ExportSignalFunc or is wider than 8 bytes.
+// A Decoder is safe for concurrent use, since decoding never modifies it.
+type Decoder struct {
+	size   int
+	mux    int
+	fields []decoderField
+}
+
+// decoderField is a field of a Decoder with its precomputed byte offset.
+type decoderField struct {
+	field  Field
+	offset int
+}
+
+// NewDecoder compiles the given Message into a Decoder.
+// The Message is copied, so later changes to it do not affect the Decoder.
+func NewDecoder(m Message) *Decoder {
+	offsets := m.offsets()
+	d := &Decoder{
+		size:   m.Size(),
+		mux:    -1,
+		fields: make([]decoderField, len(m)),
+	}
+	for i, field := range m.Copy() {
+		field.Bytes = nil
+		d.fields[i] = decoderField{field: field, offset: offsets[i]}
+		if d.mux < 0 && field.Multiplexer && !field.Multiplexed {
+			d.mux = i
+		}
+	}
+	return d
+}
+
+// Size returns the number of bytes covered by the compiled message.
+func (d *Decoder) Size() int {
+	return d.size
+}
+
+// Decode decodes data into Signals and appends them to signals, returning the extended slice.
+// Passing a reused buffer such as signals[:0] avoids allocating on every frame.
+// The signals are the same as those returned by Message.DecodeSignals, so data may be longer
+// than the message, in which case the trailing bytes are ignored.
+// It returns an error if the data is shorter than the size of the message.
+func (d *Decoder) Decode(data []byte, signals []Signal) ([]Signal, error) {
+	if len(data) < d.size {
+		return signals, fmt.Errorf("invalid data length, expected at least %d bytes, got %d", d.size, len(data))
+	}
+	mux := 0
+	if d.mux >= 0 {
+		mux = d.fields[d.mux].decode(data).Value
+	}
+	for i := range d.fields {
+		f := &d.fields[i]
+		if f.field.Multiplexed && (d.mux < 0 || f.field.MuxValue != mux) {
+			continue
+		}
+		field := f.decode(data)
+		if field.ExportSignalFunc != nil {
+			signals = append(signals, field.ExportSignalFunc(field)...)
+		} else if len(field.Flags) > 0 {
+			signals = appendFlagSignals(signals, field)
+		} else {
+			signals = appendDefaultSignals(signals, field)
+		}
+	}
+	return signals, nil
+}
+
+// decode returns a copy of the field decoded from data, which must cover the whole message.
+func (f *decoderField) decode(data []byte) Field {
+	field := f.field
+	if field.IsBitField() {
+		field.Bytes = data[:len(data):len(data)]
+	} else {
+		field.Bytes = data[f.offset : f.offset+field.Size : f.offset+field.Size]
+	}
+	return field.Decode()
+}
@@ -0,0 +1,170 @@
+package mapache
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testDecoderMessage() Message {
+	return Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil).WithValueTable(ValueTable{3: "TS_ACTIVE"}),
+		NewFlagsField("ecu_status_flags", 2, MSBFirst, []string{"ecu_status_acu", "ecu_status_inv_one", "ecu_status_inv_two"}),
+		NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC"),
+		NewField("ecu_motor_temp", 2, Signed, LittleEndian, nil).WithScale(0.1, -40, "degC"),
+		NewField("ecu_current", 4, Float, LittleEndian, nil),
+		NewBitField("ecu_power_level", 87, 4, Unsigned, BigEndian, nil),
+		NewBitField("ecu_torque_map", 83, 4, Unsigned, BigEndian, nil),
+	}
+}
+
+var testDecoderData = []byte{0x03, 0xA0, 0x00, 0x82, 0xE8, 0x03, 0x00, 0x00, 0xC0, 0x3F, 0x31}
+
+func TestDecoder(t *testing.T) {
+	message := testDecoderMessage()
+	decoder := NewDecoder(message)
+	t.Run("Test matches DecodeSignals", func(t *testing.T) {
+		expected, err := message.DecodeSignals(testDecoderData)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		signals, err := decoder.Decode(testDecoderData, nil)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(signals, expected) {
+			t.Errorf("Expected %v, got %v", expected, signals)
+		}
+	})
+	t.Run("Test size", func(t *testing.T) {
+		if decoder.Size() != message.Size() {
+			t.Errorf("Expected %d, got %d", message.Size(), decoder.Size())
+		}
+	})
+	t.Run("Test short data", func(t *testing.T) {
+		_, err := decoder.Decode(testDecoderData[:4], nil)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test reused buffer", func(t *testing.T) {
+		buffer := make([]Signal, 0, 16)
+		signals, _ := decoder.Decode(testDecoderData, buffer)
+		signals, _ = decoder.Decode(testDecoderData, signals[:0])
+		if len(signals) != 9 || &signals[0] != &buffer[:1][0] {
+			t.Errorf("Expected buffer to be reused, got %d signals", len(signals))
+		}
+	})
+	t.Run("Test zero allocations", func(t *testing.T) {
+		buffer := make([]Signal, 0, 16)
+		allocs := testing.AllocsPerRun(100, func() {
+			buffer, _ = decoder.Decode(testDecoderData, buffer[:0])
+		})
+		if allocs != 0 {
+			t.Errorf("Expected 0 allocations, got %f", allocs)
+		}
+	})
+	t.Run("Test multiplexed", func(t *testing.T) {
+		message := Message{
+			NewField("bms_cell_group", 1, Unsigned, BigEndian, nil).AsMultiplexer(),
+			NewField("bms_cell_0_voltage", 2, Unsigned, BigEndian, nil).WithMuxValue(0),
+			NewField("bms_cell_1_voltage", 2, Unsigned, BigEndian, nil).WithMuxValue(1),
+		}
+		decoder := NewDecoder(message)
+		data := []byte{0x01, 0x0F, 0xA0}
+		expected, _ := message.DecodeSignals(data)
+		signals, err := decoder.Decode(data, nil)
+		if err != nil || !reflect.DeepEqual(signals, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, signals, err)
+		}
+	})
+	t.Run("Test custom export func", func(t *testing.T) {
+		decoder := NewDecoder(Message{
+			NewField("ecu_maps", 1, Unsigned, BigEndian, func(f Field) []Signal {
+				return []Signal{{Name: "ecu_torque_map", Value: float64(f.Value & 0x0F), RawValue: f.Value & 0x0F}}
+			}),
+		})
+		signals, err := decoder.Decode([]byte{0x31}, nil)
+		if err != nil || len(signals) != 1 || signals[0].Value != 1 {
+			t.Errorf("Unexpected signals %v (%v)", signals, err)
+		}
+	})
+}
+
+func TestDecoderFieldKinds(t *testing.T) {
+	bitFlags := NewBitField("bit_flags", 12, 5, Unsigned, LittleEndian, nil)
+	bitFlags.Flags = []string{"bit_flag_a", "bit_flag_b", "bit_flag_c"}
+	testCases := []struct {
+		name  string
+		field Field
+	}{
+		{"unsigned", NewField("unsigned", 2, Unsigned, BigEndian, nil)},
+		{"signed", NewField("signed", 3, Signed, LittleEndian, nil).WithScale(0.5, -10, "A")},
+		{"uint64", NewField("uint64", 8, Unsigned, LittleEndian, nil)},
+		{"value table", NewField("value_table", 1, Unsigned, BigEndian, nil).WithValueTable(ValueTable{0xA5: "ON", 0x3C: "OFF"})},
+		{"half float", NewField("half_float", 2, Float, BigEndian, nil)},
+		{"single float", NewField("single_float", 4, Float, LittleEndian, nil).WithScale(2, 1, "V")},
+		{"double float", NewField("double_float", 8, Float, BigEndian, nil)},
+		{"intel bit field", NewBitField("intel_bits", 3, 11, Signed, LittleEndian, nil)},
+		{"motorola bit field", NewBitField("motorola_bits", 13, 9, Unsigned, BigEndian, nil).WithValueTable(ValueTable{0x1E: "ON"})},
+		{"float bit field", NewBitField("float_bits", 8, 32, Float, LittleEndian, nil)},
+		{"msb first flags", NewFlagsField("msb_flags", 2, MSBFirst, []string{"msb_a", "msb_b", "msb_c", "msb_d", "msb_e", "msb_f", "msb_g", "msb_h", "msb_i"})},
+		{"lsb first flags", NewFlagsField("lsb_flags", 2, LSBFirst, []string{"lsb_a", "lsb_b", "lsb_c", "lsb_d", "lsb_e", "lsb_f", "lsb_g", "lsb_h", "lsb_i"})},
+		{"wide flags", NewFlagsField("wide_flags", 9, MSBFirst, []string{"wide_a", "wide_b", "wide_c", "wide_d", "wide_e", "wide_f", "wide_g", "wide_h", "wide_i"})},
+		{"bit field flags", bitFlags},
+	}
+	data := [][]byte{
+		{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		{0xA5, 0x3C, 0x5A, 0xC3, 0x0F, 0xF0, 0x96, 0x69, 0x81},
+		{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		{0x3C, 0x1E, 0x00, 0x80, 0x7F, 0xC0, 0x3F, 0x01, 0x80},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			message := Message{tc.field}
+			decoder := NewDecoder(message)
+			for _, d := range data {
+				d = d[:message.Size()]
+				filled := message.Copy()
+				if err := filled.FillFromBytes(d); err != nil {
+					t.Fatalf("Expected nil, got %v", err)
+				}
+				expected := filled.ExportSignals()
+				signals, err := decoder.Decode(d, nil)
+				if err != nil {
+					t.Fatalf("Expected nil, got %v", err)
+				}
+				// NaN float values are compared by their raw bits
+				if !reflect.DeepEqual(fmt.Sprint(signals), fmt.Sprint(expected)) {
+					t.Errorf("Expected %v for % X, got %v", expected, d, signals)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMessageDecodeSignals(b *testing.B) {
+	message := testDecoderMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		message.DecodeSignals(testDecoderData)
+	}
+}
+
+func BenchmarkMessageFillFromBytes(b *testing.B) {
+	message := testDecoderMessage()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		message.FillFromBytes(testDecoderData)
+		message.ExportSignals()
+	}
+}
+
+func BenchmarkDecoderDecode(b *testing.B) {
+	decoder := NewDecoder(testDecoderMessage())
+	signals := make([]Signal, 0, 16)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		signals, _ = decoder.Decode(testDecoderData, signals[:0])
+	}
+}
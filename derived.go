@@ -0,0 +1,425 @@
+package mapache
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DerivedSignal is a virtual signal computed from an expression over other signals, such as total inverter power
+// or brake bias.
+//
+// Expressions support numbers, signal names, the operators + - * / and unary minus with the usual precedence,
+// parentheses, and the functions abs, sqrt, pow, min, and max. Signal names are made of letters, digits, and
+// underscores, and other names can be quoted with backticks, e.g. `acu.cell_temp`.
+type DerivedSignal struct {
+	Name       string
+	Expression string
+
+	root   *derivedNode
+	inputs []string
+}
+
+// derivedNode is a node of a parsed expression. Op is one of the binary operators, "neg" for unary minus,
+// "num" for a constant, "sig" for a signal, or "call" for a function.
+type derivedNode struct {
+	op    string
+	value float64
+	name  string
+	args  []*derivedNode
+}
+
+// derivedFunction is a function that can be called in an expression, taking args arguments or at least one
+// if args is 0.
+type derivedFunction struct {
+	args int
+	fn   func(args []float64) float64
+}
+
+var derivedFunctions = map[string]derivedFunction{
+	"abs":  {1, func(args []float64) float64 { return math.Abs(args[0]) }},
+	"sqrt": {1, func(args []float64) float64 { return math.Sqrt(args[0]) }},
+	"pow":  {2, func(args []float64) float64 { return math.Pow(args[0], args[1]) }},
+	"min": {0, func(args []float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result
+	}},
+	"max": {0, func(args []float64) float64 {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result
+	}},
+}
+
+// NewDerivedSignal parses the expression of a derived signal with the given name.
+// It returns an error if the expression is invalid.
+func NewDerivedSignal(name string, expression string) (DerivedSignal, error) {
+	p := &derivedParser{input: expression}
+	root, err := p.parse()
+	if err != nil {
+		return DerivedSignal{}, fmt.Errorf("invalid expression for %s: %w", name, err)
+	}
+	return DerivedSignal{
+		Name:       name,
+		Expression: expression,
+		root:       root,
+		inputs:     p.inputs,
+	}, nil
+}
+
+// Inputs returns the names of the signals used by the expression, in order of their first use.
+func (d DerivedSignal) Inputs() []string {
+	return append([]string(nil), d.inputs...)
+}
+
+// Evaluate computes the value of the derived signal from the values of its inputs.
+// It returns an error if an input is missing from values.
+func (d DerivedSignal) Evaluate(values map[string]float64) (float64, error) {
+	for _, input := range d.inputs {
+		if _, ok := values[input]; !ok {
+			return 0, fmt.Errorf("missing input %s of %s", input, d.Name)
+		}
+	}
+	return d.root.eval(func(name string) float64 { return values[name] }), nil
+}
+
+func (n *derivedNode) eval(value func(name string) float64) float64 {
+	switch n.op {
+	case "num":
+		return n.value
+	case "sig":
+		return value(n.name)
+	case "neg":
+		return -n.args[0].eval(value)
+	case "call":
+		args := make([]float64, len(n.args))
+		for i, arg := range n.args {
+			args[i] = arg.eval(value)
+		}
+		return derivedFunctions[n.name].fn(args)
+	}
+	a, b := n.args[0].eval(value), n.args[1].eval(value)
+	switch n.op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	default:
+		return a / b
+	}
+}
+
+// derivedParser is a recursive descent parser for the expressions of derived signals.
+type derivedParser struct {
+	input  string
+	pos    int
+	inputs []string
+}
+
+func (p *derivedParser) parse() (*derivedNode, error) {
+	node, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 0 {
+		return nil, p.unexpected()
+	}
+	return node, nil
+}
+
+// peek skips whitespace and returns the next character, or 0 at the end of the input.
+func (p *derivedParser) peek() byte {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *derivedParser) unexpected() error {
+	if p.peek() == 0 {
+		return fmt.Errorf("unexpected end of %q", p.input)
+	}
+	return fmt.Errorf("unexpected %q at position %d of %q", p.input[p.pos], p.pos, p.input)
+}
+
+func (p *derivedParser) sum() (*derivedNode, error) {
+	node, err := p.product()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := string(p.input[p.pos])
+		p.pos++
+		var right *derivedNode
+		if right, err = p.product(); err == nil {
+			node = &derivedNode{op: op, args: []*derivedNode{node, right}}
+		}
+	}
+	return node, err
+}
+
+func (p *derivedParser) product() (*derivedNode, error) {
+	node, err := p.unary()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := string(p.input[p.pos])
+		p.pos++
+		var right *derivedNode
+		if right, err = p.unary(); err == nil {
+			node = &derivedNode{op: op, args: []*derivedNode{node, right}}
+		}
+	}
+	return node, err
+}
+
+func (p *derivedParser) unary() (*derivedNode, error) {
+	if p.peek() != '-' {
+		return p.primary()
+	}
+	p.pos++
+	node, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &derivedNode{op: "neg", args: []*derivedNode{node}}, nil
+}
+
+func (p *derivedParser) primary() (*derivedNode, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		node, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.unexpected()
+		}
+		p.pos++
+		return node, nil
+	case c >= '0' && c <= '9' || c == '.':
+		return p.number()
+	case c == '`':
+		end := p.pos + 1
+		for end < len(p.input) && p.input[end] != '`' {
+			end++
+		}
+		if end >= len(p.input) || end == p.pos+1 {
+			return nil, fmt.Errorf("invalid quoted name at position %d of %q", p.pos, p.input)
+		}
+		name := p.input[p.pos+1 : end]
+		p.pos = end + 1
+		return p.signal(name), nil
+	case isDerivedNameChar(c) && !(c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.input) && isDerivedNameChar(p.input[p.pos]) {
+			p.pos++
+		}
+		name := p.input[start:p.pos]
+		if p.peek() == '(' {
+			return p.call(name)
+		}
+		return p.signal(name), nil
+	}
+	return nil, p.unexpected()
+}
+
+func (p *derivedParser) number() (*derivedNode, error) {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		exponentSign := (c == '+' || c == '-') && (p.input[p.pos-1] == 'e' || p.input[p.pos-1] == 'E')
+		if !(c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || exponentSign) {
+			break
+		}
+		p.pos++
+	}
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+	}
+	return &derivedNode{op: "num", value: value}, nil
+}
+
+func (p *derivedParser) call(name string) (*derivedNode, error) {
+	function, ok := derivedFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+	p.pos++
+	node := &derivedNode{op: "call", name: name}
+	for {
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		node.args = append(node.args, arg)
+		if p.peek() != ',' {
+			break
+		}
+		p.pos++
+	}
+	if p.peek() != ')' {
+		return nil, p.unexpected()
+	}
+	p.pos++
+	if function.args != 0 && len(node.args) != function.args {
+		return nil, fmt.Errorf("function %s expects %d arguments, got %d", name, function.args, len(node.args))
+	}
+	return node, nil
+}
+
+func (p *derivedParser) signal(name string) *derivedNode {
+	if !slices.Contains(p.inputs, name) {
+		p.inputs = append(p.inputs, name)
+	}
+	return &derivedNode{op: "sig", name: name}
+}
+
+func isDerivedNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// DerivedSignalEngine computes derived signals as signals stream in, keeping the latest value of each signal of
+// each vehicle. It is safe for concurrent use.
+type DerivedSignalEngine struct {
+	// MaxAge is the maximum age of an input, relative to the signal that triggered the evaluation, after which
+	// it is stale and the derived signal is not computed. A MaxAge of 0 means no limit.
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	derived []DerivedSignal
+	latest  map[signalSeries]Signal
+}
+
+// NewDerivedSignalEngine returns a new DerivedSignalEngine without any derived signals.
+func NewDerivedSignalEngine() *DerivedSignalEngine {
+	return &DerivedSignalEngine{
+		latest: make(map[signalSeries]Signal),
+	}
+}
+
+// Define adds a derived signal with the given name and expression. Derived signals may use other derived signals,
+// which must be defined before the signals that use them.
+// It returns an error if the expression is invalid, the name is already defined or used by an earlier
+// derived signal, or the expression uses the signal itself.
+func (e *DerivedSignalEngine) Define(name string, expression string) error {
+	d, err := NewDerivedSignal(name, expression)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, input := range d.inputs {
+		if input == name {
+			return fmt.Errorf("derived signal %s uses itself", name)
+		}
+	}
+	for _, other := range e.derived {
+		if other.Name == name {
+			return fmt.Errorf("derived signal %s is already defined", name)
+		}
+		for _, input := range other.inputs {
+			if input == name {
+				return fmt.Errorf("derived signal %s must be defined before %s, which uses it", name, other.Name)
+			}
+		}
+	}
+	e.derived = append(e.derived, d)
+	return nil
+}
+
+// Update records the signals and returns the derived signals computed from them, such as the output of
+// MessageRecord.Signals or a stamped Message.ExportSignals.
+// A derived signal is computed once per vehicle for each call in which any of its inputs changed, as long as all
+// of its inputs have been seen and none is older than MaxAge relative to the newest changed input. It takes the
+// Timestamp, VehicleID, and ProducedAt of that newest changed input, and its value truncated to an integer as its
+// RawValue. Derived signals are computed in the order they were defined, and results that are not finite, such as
+// from a division by zero, are skipped. A signal older than the one already recorded is ignored, and so is a
+// signal named like a derived signal, such as a derived signal fed back in, since only the engine computes it.
+func (e *DerivedSignalEngine) Update(signals ...Signal) []Signal {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// the changed signals, and the vehicles they belong to in order of first appearance
+	vehicles := []string{}
+	changed := map[signalSeries]bool{}
+	for _, signal := range signals {
+		series := signalSeries{signal.VehicleID, signal.Name}
+		if latest, ok := e.latest[series]; ok && signal.Timestamp < latest.Timestamp {
+			continue
+		} else if e.isDerived(signal.Name) {
+			continue
+		}
+		if !slices.Contains(vehicles, signal.VehicleID) {
+			vehicles = append(vehicles, signal.VehicleID)
+		}
+		e.latest[series] = signal
+		changed[series] = true
+	}
+
+	derived := []Signal{}
+	for _, vehicleID := range vehicles {
+		for _, d := range e.derived {
+			if signal, ok := e.evaluate(d, vehicleID, changed); ok {
+				e.latest[signalSeries{vehicleID, d.Name}] = signal
+				changed[signalSeries{vehicleID, d.Name}] = true
+				derived = append(derived, signal)
+			}
+		}
+	}
+	return derived
+}
+
+// isDerived returns true if a derived signal with the given name is defined.
+func (e *DerivedSignalEngine) isDerived(name string) bool {
+	return slices.ContainsFunc(e.derived, func(d DerivedSignal) bool { return d.Name == name })
+}
+
+// evaluate computes a derived signal of the vehicle, triggered by the newest of its changed inputs, and returns
+// whether it should be emitted.
+func (e *DerivedSignalEngine) evaluate(d DerivedSignal, vehicleID string, changed map[signalSeries]bool) (Signal, bool) {
+	var trigger *Signal
+	for _, input := range d.inputs {
+		series := signalSeries{vehicleID, input}
+		signal, ok := e.latest[series]
+		if !ok {
+			return Signal{}, false
+		}
+		if changed[series] && (trigger == nil || signal.Timestamp > trigger.Timestamp) {
+			trigger = &signal
+		}
+	}
+	if trigger == nil {
+		return Signal{}, false
+	}
+	maxAge := int(e.MaxAge.Microseconds())
+	for _, input := range d.inputs {
+		if maxAge > 0 && trigger.Timestamp-e.latest[signalSeries{vehicleID, input}].Timestamp > maxAge {
+			return Signal{}, false
+		}
+	}
+	value := d.root.eval(func(name string) float64 {
+		return e.latest[signalSeries{vehicleID, name}].Value
+	})
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Signal{}, false
+	}
+	return Signal{
+		Timestamp:  trigger.Timestamp,
+		VehicleID:  vehicleID,
+		Name:       d.Name,
+		Value:      value,
+		RawValue:   int(value),
+		ProducedAt: trigger.ProducedAt,
+	}, true
+}
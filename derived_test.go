@@ -0,0 +1,179 @@
+package mapache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewDerivedSignal(t *testing.T) {
+	testCases := []struct {
+		expression string
+		expected   float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 3 / 2", 2},
+		{"-2 * -3", 6},
+		{"--1.5e1", 15},
+		{"2.5e-1 + .75", 1},
+		{"abs(ecu_speed - 20)", 7.5},
+		{"sqrt(pow(3, 2) + pow(4, 2))", 5},
+		{"min(ecu_speed, 10, `acu.temp`) + max(ecu_speed, 30)", 40},
+		{"ecu_speed * `acu.temp`", 150},
+	}
+	values := map[string]float64{"ecu_speed": 12.5, "acu.temp": 12}
+	for _, tc := range testCases {
+		d, err := NewDerivedSignal("derived", tc.expression)
+		if err != nil {
+			t.Errorf("Expected nil for %q, got %v", tc.expression, err)
+			continue
+		}
+		if value, err := d.Evaluate(values); err != nil || value != tc.expected {
+			t.Errorf("Expected %q to be %v, got %v (%v)", tc.expression, tc.expected, value, err)
+		}
+	}
+
+	t.Run("Test inputs", func(t *testing.T) {
+		d, _ := NewDerivedSignal("slip", "(wheel_speed - ground_speed) / abs(ground_speed)")
+		if inputs := d.Inputs(); !reflect.DeepEqual(inputs, []string{"wheel_speed", "ground_speed"}) {
+			t.Errorf("Expected [wheel_speed ground_speed], got %v", inputs)
+		}
+		if _, err := d.Evaluate(map[string]float64{"wheel_speed": 1}); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		for _, expression := range []string{
+			"",
+			"1 +",
+			"(1 + 2",
+			"1 + 2)",
+			"ecu_speed acu_temp",
+			"1.2.3",
+			"log(ecu_speed)",
+			"pow(2)",
+			"max()",
+			"``",
+			"`ecu_speed",
+			"ecu_speed % 2",
+		} {
+			if _, err := NewDerivedSignal("derived", expression); err == nil {
+				t.Errorf("Expected error for %q, got nil", expression)
+			}
+		}
+	})
+}
+
+func TestDerivedSignalEngine(t *testing.T) {
+	engine := NewDerivedSignalEngine()
+	definitions := [][2]string{
+		{"inverter_power", "inv1_voltage * inv1_current + inv2_voltage * inv2_current"},
+		{"inverter_power_kw", "inverter_power / 1000"},
+		{"brake_bias", "brake_front / (brake_front + brake_rear) * 100"},
+	}
+	for _, definition := range definitions {
+		if err := engine.Define(definition[0], definition[1]); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+	}
+	producedAt := time.UnixMicro(1000)
+	stamp := func(timestamp int, vehicleID string, values map[string]float64) []Signal {
+		signals := []Signal{}
+		for name, value := range values {
+			signals = append(signals, Signal{Timestamp: timestamp, VehicleID: vehicleID, Name: name, Value: value, ProducedAt: producedAt})
+		}
+		return signals
+	}
+
+	// nothing is derived until all inputs have been seen
+	if derived := engine.Update(stamp(1000, "gr24", map[string]float64{"inv1_voltage": 400, "inv1_current": 10})...); len(derived) != 0 {
+		t.Errorf("Expected no derived signals, got %+v", derived)
+	}
+	derived := engine.Update(stamp(2000, "gr24", map[string]float64{"inv2_voltage": 410, "inv2_current": 20})...)
+	expected := []Signal{
+		{Timestamp: 2000, VehicleID: "gr24", Name: "inverter_power", Value: 12200, RawValue: 12200, ProducedAt: producedAt},
+		{Timestamp: 2000, VehicleID: "gr24", Name: "inverter_power_kw", Value: 12.2, RawValue: 12, ProducedAt: producedAt},
+	}
+	if !reflect.DeepEqual(derived, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, derived)
+	}
+
+	// derived signals of each vehicle only change with their own inputs
+	derived = engine.Update(append(
+		stamp(3000, "gr24", map[string]float64{"brake_front": 60, "brake_rear": 40}),
+		stamp(3500, "gr23", map[string]float64{"brake_front": 1, "brake_rear": 0})...,
+	)...)
+	if len(derived) != 2 || derived[0].VehicleID != "gr24" || derived[0].Value != 60 || derived[0].Timestamp != 3000 ||
+		derived[1].VehicleID != "gr23" || derived[1].Value != 100 || derived[1].Timestamp != 3500 {
+		t.Errorf("Unexpected derived signals %+v", derived)
+	}
+
+	t.Run("Test stale and out of order inputs", func(t *testing.T) {
+		engine.MaxAge = 2 * time.Millisecond
+		defer func() { engine.MaxAge = 0 }()
+		if derived := engine.Update(Signal{Timestamp: 500, VehicleID: "gr24", Name: "inv1_current", Value: 1}); len(derived) != 0 {
+			t.Errorf("Expected older signal to be ignored, got %+v", derived)
+		}
+		// inv1_voltage from 1 ms is too old at 4 ms
+		if derived := engine.Update(Signal{Timestamp: 4000, VehicleID: "gr24", Name: "inv2_current", Value: 30}); len(derived) != 0 {
+			t.Errorf("Expected stale inputs to be skipped, got %+v", derived)
+		}
+		derived := engine.Update(Signal{Timestamp: 4000, VehicleID: "gr24", Name: "inv1_voltage", Value: 400}, Signal{Timestamp: 4000, VehicleID: "gr24", Name: "inv1_current", Value: 10})
+		if len(derived) != 2 || derived[0].Value != 16300 || derived[1].Value != 16.3 {
+			t.Errorf("Unexpected derived signals %+v", derived)
+		}
+	})
+	t.Run("Test unrelated signals in a batch", func(t *testing.T) {
+		engine := NewDerivedSignalEngine()
+		engine.MaxAge = 50 * time.Millisecond
+		if err := engine.Define("double", "a * 2"); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		derived := engine.Update(
+			Signal{Timestamp: 1000, VehicleID: "gr24", Name: "a", Value: 3, ProducedAt: time.UnixMicro(1000)},
+			Signal{Timestamp: 900000, VehicleID: "gr24", Name: "b", Value: 1, ProducedAt: time.UnixMicro(900000)},
+			Signal{Timestamp: 500, VehicleID: "gr24", Name: "c", Value: 1},
+		)
+		expected := []Signal{{Timestamp: 1000, VehicleID: "gr24", Name: "double", Value: 6, RawValue: 6, ProducedAt: time.UnixMicro(1000)}}
+		if !reflect.DeepEqual(derived, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, derived)
+		}
+	})
+	t.Run("Test incoming signal named like a derived signal", func(t *testing.T) {
+		engine := NewDerivedSignalEngine()
+		for _, definition := range [][2]string{{"double", "a * 2"}, {"quadruple", "double * 2"}} {
+			if err := engine.Define(definition[0], definition[1]); err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+		}
+		if derived := engine.Update(Signal{Timestamp: 1000, VehicleID: "gr24", Name: "double", Value: 100}); len(derived) != 0 {
+			t.Errorf("Expected incoming derived signal to be ignored, got %+v", derived)
+		}
+		derived := engine.Update(
+			Signal{Timestamp: 2000, VehicleID: "gr24", Name: "a", Value: 3},
+			Signal{Timestamp: 3000, VehicleID: "gr24", Name: "double", Value: 100},
+		)
+		if len(derived) != 2 || derived[0].Value != 6 || derived[1].Value != 12 || derived[1].Timestamp != 2000 {
+			t.Errorf("Unexpected derived signals %+v", derived)
+		}
+	})
+	t.Run("Test non-finite results", func(t *testing.T) {
+		if derived := engine.Update(stamp(5000, "gr23", map[string]float64{"brake_front": 0, "brake_rear": 0})...); len(derived) != 0 {
+			t.Errorf("Expected no derived signals, got %+v", derived)
+		}
+	})
+	t.Run("Test invalid definitions", func(t *testing.T) {
+		for _, definition := range [][2]string{
+			{"inverter_power", "1"},
+			{"inv1_voltage", "400"},
+			{"loop", "loop + 1"},
+			{"invalid", "1 +"},
+		} {
+			if err := engine.Define(definition[0], definition[1]); err == nil {
+				t.Errorf("Expected error for %s, got nil", definition[0])
+			}
+		}
+	})
+}
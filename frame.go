@@ -0,0 +1,168 @@
+package mapache
+
+import (
+	"fmt"
+	"sort"
+)
+
+// fdLengths maps the DLCs above 8 to the payload lengths of CAN FD frames.
+var fdLengths = [16]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 12, 16, 20, 24, 32, 48, 64}
+
+// Frame is a raw classic CAN or CAN FD frame.
+// Classic frames carry up to 8 bytes, while FD frames carry up to 64 bytes in one of the lengths listed by DLCToLength.
+type Frame struct {
+	// ID is the CAN ID of the frame, up to 0x7FF for standard IDs or 0x1FFFFFFF for extended IDs.
+	ID       int
+	Extended bool
+	// DLC is the data length code of the frame, which selects the length of Data (see DLCToLength).
+	DLC int
+	// FD frames may carry more than 8 bytes. BitRateSwitch and ErrorStateIndicator are the BRS and ESI flags,
+	// which are only valid for FD frames.
+	FD                  bool
+	BitRateSwitch       bool
+	ErrorStateIndicator bool
+	Data                []byte
+}
+
+// CANKey identifies a CAN message by its ID and whether it uses an extended ID, since standard and extended
+// frames with the same numeric ID are different messages.
+type CANKey struct {
+	ID       int
+	Extended bool
+}
+
+// Key returns the CANKey of the frame.
+func (f Frame) Key() CANKey {
+	return CANKey{ID: f.ID, Extended: f.Extended}
+}
+
+// String formats the key as a hexadecimal CAN ID, marking extended IDs.
+func (k CANKey) String() string {
+	if k.Extended {
+		return fmt.Sprintf("0x%X (extended)", k.ID)
+	}
+	return fmt.Sprintf("0x%X", k.ID)
+}
+
+// sortCANKeys sorts keys in ascending order of ID, with standard IDs before extended IDs of the same number.
+func sortCANKeys(keys []CANKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ID != keys[j].ID {
+			return keys[i].ID < keys[j].ID
+		}
+		return !keys[i].Extended && keys[j].Extended
+	})
+}
+
+// DLCToLength returns the payload length of a frame with the given DLC.
+// For classic frames, DLCs from 9 to 15 are allowed but still carry 8 bytes.
+// For FD frames, DLCs from 9 to 15 carry 12, 16, 20, 24, 32, 48, and 64 bytes.
+func DLCToLength(dlc int, fd bool) (int, error) {
+	if dlc < 0 || dlc > 15 {
+		return 0, fmt.Errorf("invalid dlc %d, expected 0 to 15", dlc)
+	} else if !fd {
+		return min(dlc, 8), nil
+	}
+	return fdLengths[dlc], nil
+}
+
+// LengthToDLC returns the DLC of a frame with the given payload length.
+// For FD frames, the length must be one of the lengths listed by DLCToLength.
+func LengthToDLC(length int, fd bool) (int, error) {
+	if !fd {
+		if length < 0 || length > 8 {
+			return 0, fmt.Errorf("invalid classic can length %d, expected at most 8 bytes", length)
+		}
+		return length, nil
+	}
+	for dlc, fdLength := range fdLengths {
+		if length == fdLength {
+			return dlc, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid can fd length %d", length)
+}
+
+// NewFrame creates a new Frame with the given data and the matching DLC.
+// FD data is padded with zeros up to the next valid FD length.
+// It returns an error if the ID is out of range or the data is too long.
+func NewFrame(id int, extended bool, fd bool, data []byte) (Frame, error) {
+	length := len(data)
+	if fd {
+		for _, fdLength := range fdLengths {
+			if fdLength >= len(data) {
+				length = fdLength
+				break
+			}
+		}
+	}
+	dlc, err := LengthToDLC(length, fd)
+	if err != nil {
+		return Frame{}, err
+	}
+	frame := Frame{
+		ID:       id,
+		Extended: extended,
+		DLC:      dlc,
+		FD:       fd,
+		Data:     make([]byte, length),
+	}
+	copy(frame.Data, data)
+	return frame, frame.Validate()
+}
+
+// Validate checks that the ID of the frame is in range, that the flags are valid,
+// and that the length of Data matches the DLC.
+func (f Frame) Validate() error {
+	if f.ID < 0 || (!f.Extended && f.ID > 0x7FF) || f.ID > 0x1FFFFFFF {
+		return fmt.Errorf("invalid can id 0x%X", f.ID)
+	} else if !f.FD && (f.BitRateSwitch || f.ErrorStateIndicator) {
+		return fmt.Errorf("invalid flags for can id 0x%X, BRS and ESI are only valid for fd frames", f.ID)
+	}
+	length, err := DLCToLength(f.DLC, f.FD)
+	if err != nil {
+		return err
+	} else if len(f.Data) != length {
+		return fmt.Errorf("invalid data length for can id 0x%X, expected %d bytes for dlc %d, got %d", f.ID, length, f.DLC, len(f.Data))
+	}
+	return nil
+}
+
+// FillFromFrame validates the frame and fills the fields of the message from its data.
+// The frame may be longer than the message, for example when it is padded to a valid FD length,
+// in which case the trailing bytes are ignored.
+func (m Message) FillFromFrame(f Frame) error {
+	if err := f.Validate(); err != nil {
+		return err
+	} else if len(f.Data) < m.Size() {
+		return fmt.Errorf("invalid data length for can id 0x%X, expected at least %d bytes, got %d", f.ID, m.Size(), len(f.Data))
+	}
+	return m.FillFromBytes(f.Data[:m.Size()])
+}
+
+// Frame encodes the message into a frame with the given ID. See NewFrame and Message.Bytes.
+func (m Message) Frame(id int, extended bool, fd bool) (Frame, error) {
+	data, err := m.Bytes()
+	if err != nil {
+		return Frame{}, err
+	}
+	return NewFrame(id, extended, fd, data)
+}
+
+// Key returns the CANKey of the DBC message.
+func (d DBCMessage) Key() CANKey {
+	return CANKey{ID: d.ID, Extended: d.Extended}
+}
+
+// Frame encodes the message into a frame with the ID and declared Size of the DBC message, padding the data with
+// zeros up to the Size. Messages declared larger than 8 bytes are encoded as FD frames.
+// It returns an error if the fields of the message do not fit in the declared Size.
+func (d DBCMessage) Frame() (Frame, error) {
+	data, err := d.Message.Bytes()
+	if err != nil {
+		return Frame{}, err
+	} else if len(data) > d.Size {
+		return Frame{}, fmt.Errorf("invalid size for can id 0x%X, message of %d bytes exceeds declared size of %d", d.ID, len(data), d.Size)
+	}
+	return NewFrame(d.ID, d.Extended, d.Size > 8, append(data, make([]byte, d.Size-len(data))...))
+}
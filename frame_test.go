@@ -0,0 +1,192 @@
+package mapache
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDLCToLength(t *testing.T) {
+	classic := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 8, 8, 8, 8, 8, 8, 8}
+	fd := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 12, 16, 20, 24, 32, 48, 64}
+	for dlc := 0; dlc < 16; dlc++ {
+		if length, err := DLCToLength(dlc, false); err != nil || length != classic[dlc] {
+			t.Errorf("Expected %d for classic dlc %d, got %d (%v)", classic[dlc], dlc, length, err)
+		}
+		if length, err := DLCToLength(dlc, true); err != nil || length != fd[dlc] {
+			t.Errorf("Expected %d for fd dlc %d, got %d (%v)", fd[dlc], dlc, length, err)
+		}
+	}
+	for _, dlc := range []int{-1, 16} {
+		if _, err := DLCToLength(dlc, true); err == nil {
+			t.Errorf("Expected error for dlc %d, got nil", dlc)
+		}
+	}
+}
+
+func TestLengthToDLC(t *testing.T) {
+	testCases := []struct {
+		length int
+		fd     bool
+		dlc    int
+		valid  bool
+	}{
+		{0, false, 0, true},
+		{8, false, 8, true},
+		{9, false, 0, false},
+		{-1, false, 0, false},
+		{8, true, 8, true},
+		{12, true, 9, true},
+		{64, true, 15, true},
+		{10, true, 0, false},
+		{65, true, 0, false},
+	}
+	for _, tc := range testCases {
+		dlc, err := LengthToDLC(tc.length, tc.fd)
+		if (err == nil) != tc.valid || dlc != tc.dlc {
+			t.Errorf("Expected dlc %d (valid %t) for length %d, got %d (%v)", tc.dlc, tc.valid, tc.length, dlc, err)
+		}
+	}
+}
+
+func TestFrameValidate(t *testing.T) {
+	testCases := []struct {
+		name  string
+		frame Frame
+		valid bool
+	}{
+		{"classic", Frame{ID: 0x123, DLC: 2, Data: []byte{1, 2}}, true},
+		{"classic dlc above 8", Frame{ID: 0x123, DLC: 15, Data: make([]byte, 8)}, true},
+		{"fd", Frame{ID: 0x123, DLC: 13, FD: true, BitRateSwitch: true, Data: make([]byte, 32)}, true},
+		{"extended", Frame{ID: 0x1FFFFFFF, Extended: true}, true},
+		{"standard id too large", Frame{ID: 0x800}, false},
+		{"extended id too large", Frame{ID: 0x20000000, Extended: true}, false},
+		{"negative id", Frame{ID: -1}, false},
+		{"length mismatch", Frame{ID: 0x123, DLC: 3, Data: []byte{1, 2}}, false},
+		{"fd length mismatch", Frame{ID: 0x123, DLC: 9, FD: true, Data: make([]byte, 10)}, false},
+		{"invalid dlc", Frame{ID: 0x123, DLC: 16, FD: true, Data: make([]byte, 64)}, false},
+		{"brs without fd", Frame{ID: 0x123, BitRateSwitch: true}, false},
+		{"esi without fd", Frame{ID: 0x123, ErrorStateIndicator: true}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.frame.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Expected valid %t, got %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+func TestNewFrame(t *testing.T) {
+	t.Run("Test classic", func(t *testing.T) {
+		frame, err := NewFrame(0x123, false, false, []byte{1, 2, 3})
+		expected := Frame{ID: 0x123, DLC: 3, Data: []byte{1, 2, 3}}
+		if err != nil || !reflect.DeepEqual(frame, expected) {
+			t.Errorf("Expected %+v, got %+v (%v)", expected, frame, err)
+		}
+	})
+	t.Run("Test fd padding", func(t *testing.T) {
+		frame, err := NewFrame(0x12345, true, true, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+		expected := Frame{ID: 0x12345, Extended: true, DLC: 9, FD: true, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 0, 0}}
+		if err != nil || !reflect.DeepEqual(frame, expected) {
+			t.Errorf("Expected %+v, got %+v (%v)", expected, frame, err)
+		}
+	})
+	t.Run("Test data is copied", func(t *testing.T) {
+		data := []byte{1, 2}
+		frame, _ := NewFrame(0x123, false, false, data)
+		data[0] = 5
+		if frame.Data[0] != 1 {
+			t.Errorf("Expected frame data to be copied")
+		}
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		if _, err := NewFrame(0x123, false, false, make([]byte, 9)); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		if _, err := NewFrame(0x123, false, true, make([]byte, 65)); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		if _, err := NewFrame(0x800, false, false, nil); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestMessageFrame(t *testing.T) {
+	message := Message{
+		NewField("bms_cell_0_voltage", 2, Unsigned, BigEndian, nil).WithScale(0.001, 0, "V"),
+		NewField("bms_cell_1_voltage", 2, Unsigned, BigEndian, nil).WithScale(0.001, 0, "V"),
+		NewField("bms_cell_2_voltage", 2, Unsigned, BigEndian, nil).WithScale(0.001, 0, "V"),
+		NewField("bms_cell_3_voltage", 2, Unsigned, BigEndian, nil).WithScale(0.001, 0, "V"),
+		NewField("bms_cell_4_voltage", 2, Unsigned, BigEndian, nil).WithScale(0.001, 0, "V"),
+	}
+	message.FillFromInts([]int{3700, 3800, 3900, 4000, 4100})
+	t.Run("Test fd round trip", func(t *testing.T) {
+		frame, err := message.Frame(0x200, false, true)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if frame.DLC != 9 || len(frame.Data) != 12 {
+			t.Errorf("Expected dlc 9 with 12 bytes, got %d with %d", frame.DLC, len(frame.Data))
+		}
+		decoded := Message{
+			NewField("bms_cell_0_voltage", 2, Unsigned, BigEndian, nil),
+			NewField("bms_cell_1_voltage", 2, Unsigned, BigEndian, nil),
+			NewField("bms_cell_2_voltage", 2, Unsigned, BigEndian, nil),
+			NewField("bms_cell_3_voltage", 2, Unsigned, BigEndian, nil),
+			NewField("bms_cell_4_voltage", 2, Unsigned, BigEndian, nil),
+		}
+		if err := decoded.FillFromFrame(frame); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if decoded[0].Value != 3700 || decoded[4].Value != 4100 {
+			t.Errorf("Unexpected values %d %d", decoded[0].Value, decoded[4].Value)
+		}
+	})
+	t.Run("Test too large for classic", func(t *testing.T) {
+		if _, err := message.Frame(0x200, false, false); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test short frame", func(t *testing.T) {
+		err := message.Copy().FillFromFrame(Frame{ID: 0x200, DLC: 8, Data: make([]byte, 8)})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test invalid frame", func(t *testing.T) {
+		err := message.Copy().FillFromFrame(Frame{ID: 0x200, DLC: 9, Data: make([]byte, 10)})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test dbc message", func(t *testing.T) {
+		dbcMessage := testDBCMessage(t, 0x1000, "BMS_Cells", message)
+		dbcMessage.Extended = true
+		frame, err := dbcMessage.Frame()
+		if err != nil || !frame.FD || !frame.Extended || frame.ID != 0x1000 {
+			t.Errorf("Unexpected frame %+v (%v)", frame, err)
+		}
+	})
+	t.Run("Test dbc declared size", func(t *testing.T) {
+		dbc, err := ParseDBC(strings.NewReader(testDBC))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		// ACU_Cell_Data is declared as 8 bytes, but its signals only cover 6
+		frame, err := dbc.Messages[CANKey{ID: 0x1000, Extended: true}].Frame()
+		if err != nil || frame.DLC != 8 || len(frame.Data) != 8 || frame.FD {
+			t.Errorf("Expected classic frame with dlc 8, got %+v (%v)", frame, err)
+		}
+		dbcMessage := testDBCMessage(t, 0x100, "BMS_Cells", message)
+		dbcMessage.Size = 16
+		if frame, err := dbcMessage.Frame(); err != nil || frame.DLC != 10 || !frame.FD {
+			t.Errorf("Expected fd frame with dlc 10, got %+v (%v)", frame, err)
+		}
+		dbcMessage.Size = 8
+		if _, err := dbcMessage.Frame(); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
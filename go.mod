@@ -1,3 +1,5 @@
 module github.com/gaucho-racing/mapache-go
 
 go 1.22
+
+require gopkg.in/yaml.v3 v3.0.1
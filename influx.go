@@ -0,0 +1,217 @@
+package mapache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// influxVehicleTag is the tag holding the VehicleID of each line.
+const influxVehicleTag = "vehicle_id"
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	influxKeyEscaper         = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+)
+
+// MarshalInflux returns the signals in InfluxDB line protocol, with one line per vehicle and timestamp.
+// Each line uses the VehicleID as its measurement and as the vehicle_id tag, holds one float field per signal Name
+// with its Value, and has a nanosecond timestamp derived from the signal Timestamp. Since line protocol does not
+// allow empty tag values, signals without a VehicleID are written to the "signal" measurement without the tag.
+// Lines are in the order their first signal appears, and fields are sorted by name. If the same signal appears more
+// than once at a timestamp, the last one is kept. Signals with a NaN or infinite value are skipped, since they
+// cannot be represented in line protocol.
+func MarshalInflux(signals []Signal) []byte {
+	type line struct {
+		vehicleID string
+		timestamp int
+		fields    map[string]float64
+	}
+	lines := []*line{}
+	index := map[wideRow]*line{}
+	for _, signal := range signals {
+		if math.IsNaN(signal.Value) || math.IsInf(signal.Value, 0) {
+			continue
+		}
+		key := wideRow{signal.Timestamp, signal.VehicleID}
+		l, ok := index[key]
+		if !ok {
+			l = &line{vehicleID: signal.VehicleID, timestamp: signal.Timestamp, fields: map[string]float64{}}
+			index[key] = l
+			lines = append(lines, l)
+		}
+		l.fields[signal.Name] = signal.Value
+	}
+
+	buf := []byte{}
+	for _, l := range lines {
+		if l.vehicleID == "" {
+			buf = append(buf, Signal{}.TableName()...)
+		} else {
+			buf = append(buf, influxMeasurementEscaper.Replace(l.vehicleID)...)
+			buf = append(buf, ","+influxVehicleTag+"="...)
+			buf = append(buf, influxKeyEscaper.Replace(l.vehicleID)...)
+		}
+		names := make([]string, 0, len(l.fields))
+		for name := range l.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			if i == 0 {
+				buf = append(buf, ' ')
+			} else {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, influxKeyEscaper.Replace(name)...)
+			buf = append(buf, '=')
+			buf = strconv.AppendFloat(buf, l.fields[name], 'g', -1, 64)
+		}
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, int64(l.timestamp)*1000, 10)
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+// WriteInflux writes the signals in InfluxDB line protocol. See MarshalInflux.
+func WriteInflux(w io.Writer, signals []Signal) error {
+	_, err := w.Write(MarshalInflux(signals))
+	return err
+}
+
+// ParseInflux parses InfluxDB line protocol into signals, with one signal per field.
+// The VehicleID is taken from the vehicle_id tag, and is empty if the tag is missing.
+// Float, integer, unsigned integer, and boolean fields are supported; integer fields also set the RawValue.
+// It returns an error if an unsigned integer field does not fit in an int.
+// Lines without a timestamp have a Timestamp of 0, and sub-microsecond precision is truncated.
+func ParseInflux(r io.Reader) ([]Signal, error) {
+	signals := []Signal{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lineSignals, err := parseInfluxLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		signals = append(signals, lineSignals...)
+	}
+	return signals, scanner.Err()
+}
+
+func parseInfluxLine(line string) ([]Signal, error) {
+	sections := splitInflux(line, ' ')
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("invalid line protocol %q", line)
+	}
+	series := splitInflux(sections[0], ',')
+	if series[0] == "" {
+		return nil, fmt.Errorf("missing measurement")
+	}
+	vehicleID := ""
+	for _, tag := range series[1:] {
+		kv := splitInflux(tag, '=')
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		if unescapeInflux(kv[0]) == influxVehicleTag {
+			vehicleID = unescapeInflux(kv[1])
+		}
+	}
+	timestamp := 0
+	if len(sections) == 3 {
+		ns, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", sections[2])
+		}
+		timestamp = int(ns / 1000)
+	}
+
+	signals := []Signal{}
+	for _, field := range splitInflux(sections[1], ',') {
+		kv := splitInflux(field, '=')
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+		signal := Signal{
+			Timestamp:  timestamp,
+			VehicleID:  vehicleID,
+			Name:       unescapeInflux(kv[0]),
+			ProducedAt: time.UnixMicro(int64(timestamp)),
+		}
+		value := kv[1]
+		switch {
+		case strings.HasSuffix(value, "i"):
+			raw, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid integer field %q", field)
+			}
+			signal.Value, signal.RawValue = float64(raw), int(raw)
+		case strings.HasSuffix(value, "u"):
+			raw, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unsigned integer field %q", field)
+			} else if raw > math.MaxInt {
+				return nil, fmt.Errorf("unsigned integer field %q overflows int", field)
+			}
+			signal.Value, signal.RawValue = float64(raw), int(raw)
+		case value == "t" || value == "T" || value == "true" || value == "True" || value == "TRUE":
+			signal.Value, signal.RawValue = 1, 1
+		case value == "f" || value == "F" || value == "false" || value == "False" || value == "FALSE":
+		case strings.HasPrefix(value, `"`):
+			return nil, fmt.Errorf("unsupported string field %q", field)
+		default:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid float field %q", field)
+			}
+			signal.Value = v
+		}
+		signals = append(signals, signal)
+	}
+	return signals, nil
+}
+
+// splitInflux splits s around each instance of sep that is neither escaped by a backslash nor within a
+// double quoted string. Escapes are kept in the returned parts.
+func splitInflux(s string, sep byte) []string {
+	parts := []string{}
+	start := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeInflux removes the backslashes escaping commas, equal signs, spaces, double quotes, and backslashes.
+func unescapeInflux(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`,= "\`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
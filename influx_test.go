@@ -0,0 +1,93 @@
+package mapache
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalInflux(t *testing.T) {
+	signals := []Signal{
+		{Timestamp: 1697040000123456, VehicleID: "gr24", Name: "ecu_speed", Value: 12.5},
+		{Timestamp: 1697040000123456, VehicleID: "gr24", Name: "acu_temp", Value: 30},
+		{Timestamp: 1697040000123456, VehicleID: "gr 23,b", Name: "acu temp=x", Value: -1.5e-7},
+		{Timestamp: 1697040000200000, VehicleID: "gr24", Name: "ecu_speed", Value: 13},
+		{Timestamp: 1697040000200000, VehicleID: "gr24", Name: "ecu_speed", Value: 14},
+		{Timestamp: 1697040000200000, VehicleID: "gr24", Name: "ecu_nan", Value: math.NaN()},
+		{Timestamp: 1697040000200000, VehicleID: "", Name: "ecu_state", Value: 2},
+	}
+	expected := strings.Join([]string{
+		"gr24,vehicle_id=gr24 acu_temp=30,ecu_speed=12.5 1697040000123456000",
+		`gr\ 23\,b,vehicle_id=gr\ 23\,b acu\ temp\=x=-1.5e-07 1697040000123456000`,
+		"gr24,vehicle_id=gr24 ecu_speed=14 1697040000200000000",
+		"signal ecu_state=2 1697040000200000000",
+	}, "\n") + "\n"
+	if got := string(MarshalInflux(signals)); got != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, got)
+	}
+	var buf bytes.Buffer
+	if err := WriteInflux(&buf, signals); err != nil || buf.String() != expected {
+		t.Errorf("Expected written lines, got %v", err)
+	}
+
+	parsed, err := ParseInflux(strings.NewReader(expected))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if len(parsed) != 5 {
+		t.Fatalf("Expected 5 signals, got %d", len(parsed))
+	}
+	if !reflect.DeepEqual(parsed[2], Signal{Timestamp: 1697040000123456, VehicleID: "gr 23,b", Name: "acu temp=x", Value: -1.5e-7, ProducedAt: time.UnixMicro(1697040000123456)}) {
+		t.Errorf("Unexpected signal %+v", parsed[2])
+	}
+	if parsed[4].VehicleID != "" || parsed[4].Value != 2 || parsed[0].Name != "acu_temp" || parsed[1].VehicleID != "gr24" {
+		t.Errorf("Unexpected signals %+v", parsed)
+	}
+}
+
+func TestParseInflux(t *testing.T) {
+	input := strings.Join([]string{
+		"# comment",
+		"",
+		`gr24,vehicle_id=gr24,track=buttonwillow count=3i,active=t,idle=false,temp=21.5`,
+		`weather,vehicle_id=gr\ 24 humidity=0.4 1697040000000000999`,
+		`gr23 odometer=18446744073709551615u`,
+	}, "\n")
+	expected := []Signal{
+		{VehicleID: "gr24", Name: "count", Value: 3, RawValue: 3, ProducedAt: time.UnixMicro(0)},
+		{VehicleID: "gr24", Name: "active", Value: 1, RawValue: 1, ProducedAt: time.UnixMicro(0)},
+		{VehicleID: "gr24", Name: "idle", ProducedAt: time.UnixMicro(0)},
+		{VehicleID: "gr24", Name: "temp", Value: 21.5, ProducedAt: time.UnixMicro(0)},
+		{Timestamp: 1697040000000000, VehicleID: "gr 24", Name: "humidity", Value: 0.4, ProducedAt: time.UnixMicro(1697040000000000)},
+	}
+	if signals, err := ParseInflux(strings.NewReader(input)); err == nil {
+		t.Errorf("Expected error for uint overflowing int, got %+v", signals)
+	}
+	input = strings.Replace(input, "18446744073709551615u", "9223372036854775807u", 1)
+	expected = append(expected, Signal{Name: "odometer", Value: math.MaxInt64, RawValue: math.MaxInt64, ProducedAt: time.UnixMicro(0)})
+	signals, err := ParseInflux(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if !reflect.DeepEqual(signals, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, signals)
+	}
+	for _, invalid := range []string{
+		"gr24",
+		"gr24 speed",
+		"gr24 speed=fast",
+		`gr24 name="fast"`,
+		"gr24 speed=1 soon",
+		",vehicle_id=gr24 speed=1",
+		"gr24,vehicle_id speed=1",
+		"gr24 speed=1 1 extra",
+		"gr24 odometer=-1u",
+	} {
+		if _, err := ParseInflux(strings.NewReader("gr24 ok=1\n" + invalid)); err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Expected error on line 2 for %q, got %v", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package mapache
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ISO-TP (ISO 15765-2) frame types, stored in the upper nibble of the first byte of each frame.
+const (
+	isotpSingleFrame      = 0x0
+	isotpFirstFrame       = 0x1
+	isotpConsecutiveFrame = 0x2
+	isotpFlowControl      = 0x3
+)
+
+// isotpMaxShortLength is the largest payload length that fits in the 12-bit length of a first frame.
+const isotpMaxShortLength = 0xFFF
+
+// DefaultISOTPMaxLength is the default maximum payload length of an ISOTPReceiver, the largest length of a
+// first frame without the 32-bit length escape.
+const DefaultISOTPMaxLength = isotpMaxShortLength
+
+// isotpMaxPrealloc is the largest payload buffer allocated up front, since the length of a first frame cannot be
+// trusted until its consecutive frames arrive.
+const isotpMaxPrealloc = isotpMaxShortLength
+
+// DefaultISOTPTimeout is the default time to wait for the next frame of a transfer (N_Bs and N_Cr).
+const DefaultISOTPTimeout = time.Second
+
+var (
+	// ErrISOTPTimeout is returned when the next frame of a transfer is not received in time.
+	ErrISOTPTimeout = errors.New("isotp timeout")
+	// ErrISOTPSequence is returned when a consecutive frame is received out of order.
+	ErrISOTPSequence = errors.New("isotp sequence error")
+	// ErrISOTPOverflow is returned when a payload is too large for the receiver.
+	ErrISOTPOverflow = errors.New("isotp overflow")
+)
+
+// ISOTPFlowStatus is a type to represent the flow status of an ISO-TP flow control frame.
+type ISOTPFlowStatus int
+
+const (
+	// ISOTPContinue allows the sender to send the next block of consecutive frames.
+	ISOTPContinue ISOTPFlowStatus = 0
+	// ISOTPWait asks the sender to wait for another flow control frame.
+	ISOTPWait ISOTPFlowStatus = 1
+	// ISOTPAbort tells the sender that the payload is too large, aborting the transfer.
+	ISOTPAbort ISOTPFlowStatus = 2
+)
+
+// NewISOTPFlowControl returns the data of a flow control frame.
+// A block size of 0 lets the sender send every remaining consecutive frame without waiting for flow control,
+// and the separation time is the minimum time between consecutive frames (STmin).
+func NewISOTPFlowControl(status ISOTPFlowStatus, blockSize int, separation time.Duration) []byte {
+	return []byte{byte(isotpFlowControl<<4 | int(status)&0x0F), byte(blockSize), encodeSeparationTime(separation)}
+}
+
+// encodeSeparationTime encodes a separation time into STmin, which holds 0 to 127 ms or 100 to 900 µs.
+func encodeSeparationTime(d time.Duration) byte {
+	if d <= 0 {
+		return 0
+	} else if d < time.Millisecond {
+		return byte(0xF0 + max(d/(100*time.Microsecond), 1))
+	}
+	return byte(min(d/time.Millisecond, 0x7F))
+}
+
+// decodeSeparationTime decodes STmin into a separation time. Reserved values are treated as 127 ms.
+func decodeSeparationTime(stMin byte) time.Duration {
+	if stMin <= 0x7F {
+		return time.Duration(stMin) * time.Millisecond
+	} else if stMin >= 0xF1 && stMin <= 0xF9 {
+		return time.Duration(stMin-0xF0) * 100 * time.Microsecond
+	}
+	return 0x7F * time.Millisecond
+}
+
+// SegmentISOTP splits a payload into the data of ISO-TP frames of at most frameSize bytes,
+// which is 8 for classic CAN or a valid CAN FD length above 8.
+// A payload that fits in one frame is sent as a single frame, and larger payloads as a first frame
+// followed by consecutive frames. The frames are not padded.
+func SegmentISOTP(payload []byte, frameSize int) ([][]byte, error) {
+	if _, err := LengthToDLC(frameSize, true); err != nil || frameSize < 8 {
+		return nil, fmt.Errorf("invalid isotp frame size %d, expected 8 or a valid can fd length", frameSize)
+	} else if len(payload) == 0 {
+		return nil, fmt.Errorf("invalid isotp payload, expected at least 1 byte")
+	} else if int64(len(payload)) > 0xFFFFFFFF {
+		return nil, fmt.Errorf("invalid isotp payload length %d, expected at most 4294967295 bytes", len(payload))
+	}
+
+	if len(payload) <= 7 {
+		return [][]byte{append([]byte{byte(len(payload))}, payload...)}, nil
+	} else if frameSize > 8 && len(payload) <= frameSize-2 {
+		return [][]byte{append([]byte{0x00, byte(len(payload))}, payload...)}, nil
+	}
+
+	var first []byte
+	if len(payload) <= isotpMaxShortLength {
+		first = []byte{byte(isotpFirstFrame<<4 | len(payload)>>8), byte(len(payload))}
+	} else {
+		first = []byte{isotpFirstFrame << 4, 0x00, byte(len(payload) >> 24), byte(len(payload) >> 16), byte(len(payload) >> 8), byte(len(payload))}
+	}
+	n := frameSize - len(first)
+	frames := [][]byte{append(first, payload[:n]...)}
+	for sequence := 1; n < len(payload); sequence++ {
+		end := min(n+frameSize-1, len(payload))
+		frames = append(frames, append([]byte{byte(isotpConsecutiveFrame<<4 | sequence&0x0F)}, payload[n:end]...))
+		n = end
+	}
+	return frames, nil
+}
+
+// ISOTPReceiver reassembles ISO-TP payloads from the data of received frames, one transfer at a time.
+// Time is passed in with every frame instead of being read from the clock, so a receiver can replay logs.
+type ISOTPReceiver struct {
+	// Timeout is the maximum time between consecutive frames (N_Cr). Defaults to DefaultISOTPTimeout.
+	Timeout time.Duration
+	// BlockSize is the number of consecutive frames the sender may send before waiting for flow control,
+	// or 0 to receive every consecutive frame without further flow control.
+	BlockSize int
+	// SeparationTime is the minimum time the sender should wait between consecutive frames.
+	SeparationTime time.Duration
+	// MaxLength is the maximum length of a payload, or 0 for no limit. Defaults to DefaultISOTPMaxLength.
+	// Payloads using the 32-bit length of first frames need a larger MaxLength.
+	MaxLength int
+
+	receiving bool
+	payload   []byte
+	length    int
+	sequence  int
+	block     int
+	last      time.Time
+}
+
+// NewISOTPReceiver creates a new ISOTPReceiver with the default timeout and maximum length, no block size,
+// and no separation time.
+func NewISOTPReceiver() *ISOTPReceiver {
+	return &ISOTPReceiver{Timeout: DefaultISOTPTimeout, MaxLength: DefaultISOTPMaxLength}
+}
+
+// Receive handles the data of a frame received at time t.
+// It returns the payload once a transfer is complete, and the data of a flow control frame whenever one
+// has to be sent back to the sender (after a first frame and after every block of consecutive frames).
+// A single or first frame received during a transfer aborts it and starts over, as required by ISO 15765-2,
+// and flow control frames are ignored.
+// It returns an error wrapping ErrISOTPTimeout or ErrISOTPSequence if a consecutive frame is late or out of order,
+// and an error wrapping ErrISOTPOverflow if a payload is longer than MaxLength, in which case the returned
+// flow control frame aborts the transfer.
+func (r *ISOTPReceiver) Receive(data []byte, t time.Time) (payload []byte, flowControl []byte, err error) {
+	if len(data) == 0 {
+		return nil, nil, fmt.Errorf("invalid isotp frame, expected at least 1 byte")
+	}
+	switch data[0] >> 4 {
+	case isotpSingleFrame:
+		r.receiving = false
+		return r.receiveSingle(data)
+	case isotpFirstFrame:
+		r.receiving = false
+		return r.receiveFirst(data, t)
+	case isotpConsecutiveFrame:
+		return r.receiveConsecutive(data, t)
+	case isotpFlowControl:
+		return nil, nil, nil
+	}
+	return nil, nil, fmt.Errorf("invalid isotp frame type 0x%X", data[0]>>4)
+}
+
+// receiveSingle returns the payload of a single frame.
+func (r *ISOTPReceiver) receiveSingle(data []byte) ([]byte, []byte, error) {
+	length, start := int(data[0]&0x0F), 1
+	if length == 0 && len(data) > 8 {
+		length, start = int(data[1]), 2
+	}
+	if length == 0 || start+length > len(data) {
+		return nil, nil, fmt.Errorf("invalid isotp single frame length %d for %d bytes of data", length, len(data))
+	} else if r.MaxLength > 0 && length > r.MaxLength {
+		return nil, nil, fmt.Errorf("%w, payload of %d bytes exceeds %d", ErrISOTPOverflow, length, r.MaxLength)
+	}
+	return append([]byte{}, data[start:start+length]...), nil, nil
+}
+
+// receiveFirst starts a transfer from a first frame and returns the flow control frame to send back.
+func (r *ISOTPReceiver) receiveFirst(data []byte, t time.Time) ([]byte, []byte, error) {
+	if len(data) < 8 {
+		return nil, nil, fmt.Errorf("invalid isotp first frame, expected at least 8 bytes, got %d", len(data))
+	}
+	length, start := int(data[0]&0x0F)<<8|int(data[1]), 2
+	if length == 0 {
+		length, start = int(data[2])<<24|int(data[3])<<16|int(data[4])<<8|int(data[5]), 6
+	}
+	if length <= len(data)-start {
+		return nil, nil, fmt.Errorf("invalid isotp first frame length %d for %d bytes of data", length, len(data))
+	} else if r.MaxLength > 0 && length > r.MaxLength {
+		err := fmt.Errorf("%w, payload of %d bytes exceeds %d", ErrISOTPOverflow, length, r.MaxLength)
+		return nil, NewISOTPFlowControl(ISOTPAbort, 0, 0), err
+	}
+	r.receiving = true
+	r.payload = append(make([]byte, 0, min(length, isotpMaxPrealloc)), data[start:]...)
+	r.length = length
+	r.sequence = 1
+	r.block = 0
+	r.last = t
+	return nil, NewISOTPFlowControl(ISOTPContinue, r.BlockSize, r.SeparationTime), nil
+}
+
+// receiveConsecutive appends a consecutive frame to the current transfer.
+func (r *ISOTPReceiver) receiveConsecutive(data []byte, t time.Time) ([]byte, []byte, error) {
+	if err := r.CheckTimeout(t); err != nil {
+		return nil, nil, err
+	} else if !r.receiving {
+		return nil, nil, fmt.Errorf("unexpected isotp consecutive frame without a first frame")
+	} else if sequence := int(data[0] & 0x0F); sequence != r.sequence {
+		r.receiving = false
+		return nil, nil, fmt.Errorf("%w, expected consecutive frame %d, got %d", ErrISOTPSequence, r.sequence, sequence)
+	}
+	n := min(len(data)-1, r.length-len(r.payload))
+	r.payload = append(r.payload, data[1:1+n]...)
+	r.sequence = (r.sequence + 1) & 0x0F
+	r.last = t
+	if len(r.payload) == r.length {
+		r.receiving = false
+		return r.payload, nil, nil
+	}
+	r.block++
+	if r.BlockSize > 0 && r.block == r.BlockSize {
+		r.block = 0
+		return nil, NewISOTPFlowControl(ISOTPContinue, r.BlockSize, r.SeparationTime), nil
+	}
+	return nil, nil, nil
+}
+
+// CheckTimeout aborts the current transfer if no frame has been received within the timeout before time t,
+// returning an error wrapping ErrISOTPTimeout. It should be called periodically when no frames are received,
+// since Receive only checks the timeout when a consecutive frame arrives.
+func (r *ISOTPReceiver) CheckTimeout(t time.Time) error {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultISOTPTimeout
+	}
+	if r.receiving && t.Sub(r.last) > timeout {
+		r.receiving = false
+		return fmt.Errorf("%w, no consecutive frame for %v after %d of %d bytes", ErrISOTPTimeout, t.Sub(r.last), len(r.payload), r.length)
+	}
+	return nil
+}
+
+// ISOTPSender sends an ISO-TP payload, following the flow control frames of the receiver.
+// Time is passed in instead of being read from the clock, so a sender can be driven by tests and simulations.
+type ISOTPSender struct {
+	// Timeout is the maximum time to wait for a flow control frame (N_Bs). Defaults to DefaultISOTPTimeout.
+	Timeout time.Duration
+
+	frames     [][]byte
+	next       int
+	credits    int
+	waiting    bool
+	since      time.Time
+	separation time.Duration
+}
+
+// NewISOTPSender creates a new ISOTPSender for the given payload and frame size. See SegmentISOTP.
+func NewISOTPSender(payload []byte, frameSize int) (*ISOTPSender, error) {
+	frames, err := SegmentISOTP(payload, frameSize)
+	if err != nil {
+		return nil, err
+	}
+	return &ISOTPSender{Timeout: DefaultISOTPTimeout, frames: frames}, nil
+}
+
+// Frames returns the data of the frames that can be sent at time t, in order.
+// It returns the first frame (or the only single frame) on the first call, and then each block of consecutive
+// frames once the receiver allows it. Consecutive frames should be spaced by at least SeparationTime.
+// It returns an empty list while waiting for flow control, and an error wrapping ErrISOTPTimeout
+// if the receiver has not sent flow control within the timeout.
+func (s *ISOTPSender) Frames(t time.Time) ([][]byte, error) {
+	if s.Done() {
+		return nil, nil
+	} else if s.next == 0 {
+		s.next = 1
+		s.waiting = len(s.frames) > 1
+		s.since = t
+		return s.frames[:1], nil
+	} else if s.waiting {
+		timeout := s.Timeout
+		if timeout <= 0 {
+			timeout = DefaultISOTPTimeout
+		}
+		if t.Sub(s.since) > timeout {
+			return nil, fmt.Errorf("%w, no flow control for %v", ErrISOTPTimeout, t.Sub(s.since))
+		}
+		return nil, nil
+	}
+	end := len(s.frames)
+	if s.credits > 0 {
+		end = min(s.next+s.credits, end)
+		s.waiting = end < len(s.frames)
+		s.since = t
+	}
+	frames := s.frames[s.next:end]
+	s.next = end
+	return frames, nil
+}
+
+// HandleFlowControl handles the data of a flow control frame received from the receiver at time t.
+// It returns an error wrapping ErrISOTPOverflow if the receiver aborted the transfer,
+// or an error if the frame is not a valid flow control frame.
+func (s *ISOTPSender) HandleFlowControl(data []byte, t time.Time) error {
+	if len(data) < 3 || data[0]>>4 != isotpFlowControl {
+		return fmt.Errorf("invalid isotp flow control frame % X", data)
+	} else if !s.waiting {
+		return nil
+	}
+	switch ISOTPFlowStatus(data[0] & 0x0F) {
+	case ISOTPContinue:
+		s.waiting = false
+		s.credits = int(data[1])
+		s.separation = decodeSeparationTime(data[2])
+	case ISOTPWait:
+		s.since = t
+	case ISOTPAbort:
+		s.next = len(s.frames)
+		s.waiting = false
+		return fmt.Errorf("%w, transfer aborted by the receiver", ErrISOTPOverflow)
+	default:
+		return fmt.Errorf("invalid isotp flow status %d", data[0]&0x0F)
+	}
+	return nil
+}
+
+// SeparationTime returns the minimum time between consecutive frames requested by the receiver.
+func (s *ISOTPSender) SeparationTime() time.Duration {
+	return s.separation
+}
+
+// Done returns true once every frame has been returned by Frames.
+func (s *ISOTPSender) Done() bool {
+	return s.next >= len(s.frames) && !s.waiting
+}
@@ -0,0 +1,349 @@
+package mapache
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testISOTPPayload(length int) []byte {
+	payload := make([]byte, length)
+	for i := range payload {
+		payload[i] = byte(i * 7)
+	}
+	return payload
+}
+
+// transferISOTP sends a payload from a sender to a receiver in memory, returning the received payload
+// and the number of flow control frames sent by the receiver.
+func transferISOTP(t *testing.T, sender *ISOTPSender, receiver *ISOTPReceiver) ([]byte, int) {
+	now := time.Unix(1697040000, 0)
+	flowControls := 0
+	for i := 0; i < 10000 && !sender.Done(); i++ {
+		frames, err := sender.Frames(now)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		for _, frame := range frames {
+			now = now.Add(time.Millisecond)
+			payload, flowControl, err := receiver.Receive(frame, now)
+			if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			if flowControl != nil {
+				flowControls++
+				if err := sender.HandleFlowControl(flowControl, now); err != nil {
+					t.Fatalf("Expected nil, got %v", err)
+				}
+			}
+			if payload != nil {
+				return payload, flowControls
+			}
+		}
+	}
+	t.Fatalf("Expected transfer to complete")
+	return nil, 0
+}
+
+func TestSegmentISOTP(t *testing.T) {
+	testCases := []struct {
+		name      string
+		length    int
+		frameSize int
+		expected  [][]byte
+	}{
+		{"single", 3, 8, [][]byte{{0x03, 0x00, 0x07, 0x0E}}},
+		{"first and consecutive", 13, 8, [][]byte{
+			{0x10, 0x0D, 0x00, 0x07, 0x0E, 0x15, 0x1C, 0x23},
+			{0x21, 0x2A, 0x31, 0x38, 0x3F, 0x46, 0x4D, 0x54},
+		}},
+		{"fd single", 10, 12, [][]byte{append([]byte{0x00, 0x0A}, testISOTPPayload(10)...)}},
+		{"fd short single", 2, 64, [][]byte{{0x02, 0x00, 0x07}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			frames, err := SegmentISOTP(testISOTPPayload(tc.length), tc.frameSize)
+			if err != nil || !reflect.DeepEqual(frames, tc.expected) {
+				t.Errorf("Expected % X, got % X (%v)", tc.expected, frames, err)
+			}
+		})
+	}
+	t.Run("Test sequence wraps", func(t *testing.T) {
+		frames, _ := SegmentISOTP(testISOTPPayload(6+7*20), 8)
+		if len(frames) != 21 || frames[15][0] != 0x2F || frames[16][0] != 0x20 || frames[17][0] != 0x21 {
+			t.Errorf("Unexpected sequence numbers % X", frames)
+		}
+	})
+	t.Run("Test long payload", func(t *testing.T) {
+		frames, _ := SegmentISOTP(testISOTPPayload(5000), 64)
+		if !bytes.Equal(frames[0][:6], []byte{0x10, 0x00, 0x00, 0x00, 0x13, 0x88}) || len(frames[0]) != 64 {
+			t.Errorf("Unexpected first frame % X", frames[0])
+		}
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		for _, frameSize := range []int{0, 7, 10, 65} {
+			if _, err := SegmentISOTP([]byte{1}, frameSize); err == nil {
+				t.Errorf("Expected error for frame size %d, got nil", frameSize)
+			}
+		}
+		if _, err := SegmentISOTP(nil, 8); err == nil {
+			t.Errorf("Expected error for empty payload, got nil")
+		}
+	})
+}
+
+func TestISOTPTransfer(t *testing.T) {
+	testCases := []struct {
+		name         string
+		length       int
+		frameSize    int
+		blockSize    int
+		flowControls int
+	}{
+		{"single", 7, 8, 0, 0},
+		{"multi", 100, 8, 0, 1},
+		{"blocks", 100, 8, 4, 4},
+		{"wrapping sequence", 1000, 8, 0, 1},
+		{"fd single", 60, 64, 0, 0},
+		{"fd multi", 1000, 64, 2, 8},
+		{"long", 5000, 64, 0, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := testISOTPPayload(tc.length)
+			sender, err := NewISOTPSender(payload, tc.frameSize)
+			if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			receiver := NewISOTPReceiver()
+			receiver.BlockSize = tc.blockSize
+			receiver.MaxLength = 0
+			received, flowControls := transferISOTP(t, sender, receiver)
+			if !bytes.Equal(received, payload) {
+				t.Errorf("Expected % X, got % X", payload, received)
+			}
+			if flowControls != tc.flowControls {
+				t.Errorf("Expected %d flow control frames, got %d", tc.flowControls, flowControls)
+			}
+			if !sender.Done() {
+				t.Errorf("Expected sender to be done")
+			}
+		})
+	}
+	t.Run("Test padded frames", func(t *testing.T) {
+		payload := testISOTPPayload(20)
+		frames, _ := SegmentISOTP(payload, 8)
+		receiver := NewISOTPReceiver()
+		var received []byte
+		for _, data := range frames {
+			frame, _ := NewFrame(0x7E8, false, false, data)
+			frame.Data = append(frame.Data, bytes.Repeat([]byte{0xCC}, 8-len(frame.Data))...)
+			received, _, _ = receiver.Receive(frame.Data, time.Now())
+		}
+		if !bytes.Equal(received, payload) {
+			t.Errorf("Expected % X, got % X", payload, received)
+		}
+	})
+	t.Run("Test fill message", func(t *testing.T) {
+		message := Message{
+			NewField("ecu_serial", 4, Unsigned, BigEndian, nil),
+			NewField("ecu_firmware", 4, Unsigned, BigEndian, nil),
+			NewField("ecu_config", 4, Unsigned, BigEndian, nil),
+		}
+		sender, _ := NewISOTPSender([]byte{0, 0, 0, 1, 0, 0, 0, 2, 0, 0, 0, 3}, 8)
+		received, _ := transferISOTP(t, sender, NewISOTPReceiver())
+		if err := message.FillFromBytes(received); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if message[0].Value != 1 || message[1].Value != 2 || message[2].Value != 3 {
+			t.Errorf("Unexpected message %+v", message)
+		}
+	})
+}
+
+func TestISOTPReceiver(t *testing.T) {
+	now := time.Unix(1697040000, 0)
+	frames, _ := SegmentISOTP(testISOTPPayload(30), 8)
+	t.Run("Test flow control", func(t *testing.T) {
+		receiver := NewISOTPReceiver()
+		receiver.BlockSize = 8
+		receiver.SeparationTime = 500 * time.Microsecond
+		_, flowControl, err := receiver.Receive(frames[0], now)
+		if err != nil || !bytes.Equal(flowControl, []byte{0x30, 0x08, 0xF5}) {
+			t.Errorf("Unexpected flow control % X (%v)", flowControl, err)
+		}
+	})
+	t.Run("Test sequence error", func(t *testing.T) {
+		receiver := NewISOTPReceiver()
+		receiver.Receive(frames[0], now)
+		_, _, err := receiver.Receive(frames[2], now)
+		if !errors.Is(err, ErrISOTPSequence) {
+			t.Errorf("Expected ErrISOTPSequence, got %v", err)
+		}
+		_, _, err = receiver.Receive(frames[3], now)
+		if err == nil {
+			t.Errorf("Expected error after aborted transfer, got nil")
+		}
+	})
+	t.Run("Test timeout", func(t *testing.T) {
+		receiver := NewISOTPReceiver()
+		receiver.Timeout = 100 * time.Millisecond
+		receiver.Receive(frames[0], now)
+		if _, _, err := receiver.Receive(frames[1], now.Add(50*time.Millisecond)); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		_, _, err := receiver.Receive(frames[2], now.Add(200*time.Millisecond))
+		if !errors.Is(err, ErrISOTPTimeout) {
+			t.Errorf("Expected ErrISOTPTimeout, got %v", err)
+		}
+	})
+	t.Run("Test check timeout", func(t *testing.T) {
+		receiver := NewISOTPReceiver()
+		receiver.Receive(frames[0], now)
+		if err := receiver.CheckTimeout(now.Add(500 * time.Millisecond)); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if err := receiver.CheckTimeout(now.Add(2 * time.Second)); !errors.Is(err, ErrISOTPTimeout) {
+			t.Errorf("Expected ErrISOTPTimeout, got %v", err)
+		}
+		if err := receiver.CheckTimeout(now.Add(3 * time.Second)); err != nil {
+			t.Errorf("Expected nil after aborted transfer, got %v", err)
+		}
+	})
+	t.Run("Test restart", func(t *testing.T) {
+		receiver := NewISOTPReceiver()
+		receiver.Receive(frames[0], now)
+		receiver.Receive(frames[1], now)
+		payload, _, err := receiver.Receive([]byte{0x02, 0xAA, 0xBB}, now)
+		if err != nil || !bytes.Equal(payload, []byte{0xAA, 0xBB}) {
+			t.Errorf("Unexpected payload % X (%v)", payload, err)
+		}
+		if _, _, err := receiver.Receive(frames[2], now); err == nil {
+			t.Errorf("Expected error after restarted transfer, got nil")
+		}
+	})
+	t.Run("Test overflow", func(t *testing.T) {
+		receiver := NewISOTPReceiver()
+		receiver.MaxLength = 16
+		_, flowControl, err := receiver.Receive(frames[0], now)
+		if !errors.Is(err, ErrISOTPOverflow) || !bytes.Equal(flowControl, []byte{0x32, 0x00, 0x00}) {
+			t.Errorf("Expected ErrISOTPOverflow with abort, got % X (%v)", flowControl, err)
+		}
+		sender, _ := NewISOTPSender(testISOTPPayload(30), 8)
+		sender.Frames(now)
+		if err := sender.HandleFlowControl(flowControl, now); !errors.Is(err, ErrISOTPOverflow) || !sender.Done() {
+			t.Errorf("Expected ErrISOTPOverflow, got %v", err)
+		}
+	})
+	t.Run("Test oversized first frame", func(t *testing.T) {
+		first := []byte{0x10, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0x01, 0x02}
+		_, flowControl, err := NewISOTPReceiver().Receive(first, now)
+		if !errors.Is(err, ErrISOTPOverflow) || !bytes.Equal(flowControl, []byte{0x32, 0x00, 0x00}) {
+			t.Errorf("Expected ErrISOTPOverflow with abort, got % X (%v)", flowControl, err)
+		}
+		// without a limit, the buffer grows with the consecutive frames instead of the declared length
+		receiver := NewISOTPReceiver()
+		receiver.MaxLength = 0
+		if _, _, err := receiver.Receive(first, now); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if cap(receiver.payload) > isotpMaxPrealloc {
+			t.Errorf("Expected at most %d bytes preallocated, got %d", isotpMaxPrealloc, cap(receiver.payload))
+		}
+		payload, _, err := receiver.Receive([]byte{0x21, 0x03, 0x04}, now)
+		if err != nil || payload != nil || !bytes.Equal(receiver.payload, []byte{0x01, 0x02, 0x03, 0x04}) {
+			t.Errorf("Unexpected payload % X (%v)", receiver.payload, err)
+		}
+	})
+	t.Run("Test invalid frames", func(t *testing.T) {
+		invalid := [][]byte{
+			{},
+			{0x00},
+			{0x05, 0x01, 0x02},
+			{0x10, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+			{0x10, 0x10, 0x01},
+			{0x21, 0x01},
+			{0x40},
+		}
+		for _, data := range invalid {
+			if _, _, err := NewISOTPReceiver().Receive(data, now); err == nil {
+				t.Errorf("Expected error for % X, got nil", data)
+			}
+		}
+		if _, _, err := NewISOTPReceiver().Receive([]byte{0x30, 0x00, 0x00}, now); err != nil {
+			t.Errorf("Expected flow control to be ignored, got %v", err)
+		}
+	})
+}
+
+func TestISOTPSender(t *testing.T) {
+	now := time.Unix(1697040000, 0)
+	payload := testISOTPPayload(30)
+	t.Run("Test wait", func(t *testing.T) {
+		sender, _ := NewISOTPSender(payload, 8)
+		sender.Timeout = 100 * time.Millisecond
+		if frames, _ := sender.Frames(now); len(frames) != 1 {
+			t.Fatalf("Expected first frame, got % X", frames)
+		}
+		if frames, err := sender.Frames(now.Add(50 * time.Millisecond)); err != nil || len(frames) != 0 {
+			t.Errorf("Expected to wait, got % X (%v)", frames, err)
+		}
+		sender.HandleFlowControl(NewISOTPFlowControl(ISOTPWait, 0, 0), now.Add(90*time.Millisecond))
+		if frames, err := sender.Frames(now.Add(150 * time.Millisecond)); err != nil || len(frames) != 0 {
+			t.Errorf("Expected to wait, got % X (%v)", frames, err)
+		}
+		sender.HandleFlowControl(NewISOTPFlowControl(ISOTPContinue, 2, 20*time.Millisecond), now.Add(160*time.Millisecond))
+		if frames, err := sender.Frames(now.Add(160 * time.Millisecond)); err != nil || len(frames) != 2 {
+			t.Errorf("Expected 2 consecutive frames, got % X (%v)", frames, err)
+		}
+		if sender.SeparationTime() != 20*time.Millisecond {
+			t.Errorf("Expected 20ms, got %v", sender.SeparationTime())
+		}
+	})
+	t.Run("Test timeout", func(t *testing.T) {
+		sender, _ := NewISOTPSender(payload, 8)
+		sender.Frames(now)
+		if _, err := sender.Frames(now.Add(2 * time.Second)); !errors.Is(err, ErrISOTPTimeout) {
+			t.Errorf("Expected ErrISOTPTimeout, got %v", err)
+		}
+	})
+	t.Run("Test invalid flow control", func(t *testing.T) {
+		sender, _ := NewISOTPSender(payload, 8)
+		sender.Frames(now)
+		if err := sender.HandleFlowControl([]byte{0x21, 0x00, 0x00}, now); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		if err := sender.HandleFlowControl([]byte{0x35, 0x00, 0x00}, now); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestSeparationTime(t *testing.T) {
+	testCases := []struct {
+		duration time.Duration
+		stMin    byte
+	}{
+		{0, 0x00},
+		{20 * time.Millisecond, 0x14},
+		{127 * time.Millisecond, 0x7F},
+		{100 * time.Microsecond, 0xF1},
+		{900 * time.Microsecond, 0xF9},
+	}
+	for _, tc := range testCases {
+		if stMin := encodeSeparationTime(tc.duration); stMin != tc.stMin {
+			t.Errorf("Expected 0x%X for %v, got 0x%X", tc.stMin, tc.duration, stMin)
+		}
+		if duration := decodeSeparationTime(tc.stMin); duration != tc.duration {
+			t.Errorf("Expected %v for 0x%X, got %v", tc.duration, tc.stMin, duration)
+		}
+	}
+	if stMin := encodeSeparationTime(time.Second); stMin != 0x7F {
+		t.Errorf("Expected 0x7F, got 0x%X", stMin)
+	}
+	if duration := decodeSeparationTime(0x80); duration != 127*time.Millisecond {
+		t.Errorf("Expected 127ms, got %v", duration)
+	}
+}
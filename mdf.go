@@ -0,0 +1,573 @@
+package mapache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MDF4 block layout constants, see ASAM MDF 4.1.
+const (
+	mdfIDSize         = 64
+	mdfHeaderSize     = 24
+	mdfVersion        = 410
+	mdfChannelMaster  = 2
+	mdfSyncTime       = 1
+	mdfUnsignedLE     = 0
+	mdfSignedLE       = 2
+	mdfFloatLE        = 4
+	mdfConversionNone = 0
+	mdfLinear         = 1
+	mdfValueToText    = 7
+	// mdfRecordSize is the size of each record: the master time channel and the value channel.
+	mdfRecordSize = 16
+)
+
+// mdfHDComment is the XML comment of the header block, holding the metadata of the trip.
+type mdfHDComment struct {
+	XMLName    xml.Name            `xml:"HDcomment"`
+	Namespace  string              `xml:"xmlns,attr"`
+	TX         string              `xml:"TX"`
+	Properties []mdfCommonProperty `xml:"common_properties>e"`
+}
+
+// mdfCommonProperty is a named value of the common properties of a comment.
+type mdfCommonProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteMDFFile writes the signals of a trip to an MDF4 file at the given path. See WriteMDF.
+func WriteMDFFile(path string, trip Trip, signals []Signal, messages ...Message) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteMDF(file, trip, signals, messages...); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteMDF writes the signals of a trip as an ASAM MDF 4.1 file, readable by MDF tools such as asammdf or CANape.
+// Signals are grouped by Name into one data group per signal, with a master time channel in seconds
+// relative to the start of the trip (or the first signal if the trip has no StartTime).
+// Signals matching a non-flags Field of the given messages are stored as raw values, signed or unsigned as the
+// field, with the unit of the field and a linear conversion for its Factor and Offset, or a value to text
+// conversion for its ValueTable. Other signals are stored as physical values without a conversion.
+// The ID, vehicle, name, and description of the trip are stored in the comment of the file header, and the file
+// history records the time the file was written.
+func WriteMDF(w io.Writer, trip Trip, signals []Signal, messages ...Message) error {
+	groups := map[string][]Signal{}
+	for _, signal := range signals {
+		groups[signal.Name] = append(groups[signal.Name], signal)
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := map[string]Field{}
+	for _, message := range messages {
+		for _, field := range message {
+			if len(field.Flags) == 0 && field.Sign != Float && field.bitSize() <= 64 {
+				fields[field.Name] = field
+			}
+		}
+	}
+
+	start := trip.StartTime
+	if start.IsZero() {
+		for _, signal := range signals {
+			if t := time.UnixMicro(int64(signal.Timestamp)); start.IsZero() || t.Before(start) {
+				start = t
+			}
+		}
+	}
+
+	m := &mdfWriter{}
+	m.buf.Write(mdfIDBlock())
+	// the header block must directly follow the identification block, so it is patched once its links are known
+	hd := m.block("##HD", make([]uint64, 6), make([]byte, 32))
+	fh := m.block("##FH", []uint64{0, m.md(fmt.Sprintf(`<FHcomment xmlns="http://www.asam.net/mdf/v4"><TX>created</TX>`+
+		`<tool_id>mapache-go</tool_id><tool_vendor>Gaucho Racing</tool_vendor><tool_version>%d</tool_version></FHcomment>`, mdfVersion))},
+		mdfTimeData(time.Now(), 16))
+	timeConversion := m.linearConversion(0, 1e-6, "s")
+
+	dgFirst := uint64(0)
+	for i := len(names) - 1; i >= 0; i-- {
+		points := groups[names[i]]
+		sort.SliceStable(points, func(a, b int) bool {
+			return points[a].Timestamp < points[b].Timestamp
+		})
+		field, known := fields[names[i]]
+		data := make([]byte, 0, len(points)*mdfRecordSize)
+		for _, point := range points {
+			data = binary.LittleEndian.AppendUint64(data, uint64(int64(point.Timestamp)-start.UnixMicro()))
+			if known {
+				data = binary.LittleEndian.AppendUint64(data, uint64(point.RawValue))
+			} else {
+				data = binary.LittleEndian.AppendUint64(data, math.Float64bits(point.Value))
+			}
+		}
+		dt := m.block("##DT", nil, data)
+
+		valueType, valueConversion, unit := uint8(mdfFloatLE), uint64(0), uint64(0)
+		if known {
+			valueType = mdfSignedLE
+			if field.Sign == Unsigned {
+				valueType = mdfUnsignedLE
+			}
+			if len(field.ValueTable) > 0 && field.scale() == 1 && field.Offset == 0 {
+				valueConversion = m.valueToTextConversion(field.ValueTable)
+			} else if field.scale() != 1 || field.Offset != 0 {
+				valueConversion = m.linearConversion(field.Offset, field.scale(), "")
+			}
+			if field.Unit != "" {
+				unit = m.tx(field.Unit)
+			}
+		}
+		value := m.channel(0, names[i], 0, valueType, 8, valueConversion, unit)
+		master := m.channel(value, "time", mdfChannelMaster, mdfSignedLE, 0, timeConversion, m.tx("s"))
+		cg := m.block("##CG", []uint64{0, master, 0, 0, 0, 0}, mdfChannelGroupData(len(points)))
+		dgFirst = m.block("##DG", []uint64{dgFirst, cg, dt, 0}, make([]byte, 8))
+	}
+
+	comment := mdfHDComment{
+		Namespace: "http://www.asam.net/mdf/v4",
+		TX:        strings.TrimSpace(trip.Name + "\n" + trip.Description),
+		Properties: []mdfCommonProperty{
+			{Name: "trip_id", Value: trip.ID},
+			{Name: "vehicle_id", Value: trip.VehicleID},
+			{Name: "name", Value: trip.Name},
+			{Name: "description", Value: trip.Description},
+		},
+	}
+	if !trip.EndTime.IsZero() {
+		comment.Properties = append(comment.Properties, mdfCommonProperty{Name: "end_time", Value: trip.EndTime.Format(time.RFC3339Nano)})
+	}
+	commentXML, err := xml.Marshal(comment)
+	if err != nil {
+		return err
+	}
+	m.patch(hd, []uint64{dgFirst, fh, 0, 0, 0, m.md(string(commentXML))}, mdfTimeData(start, 32))
+	_, err = w.Write(m.buf.Bytes())
+	return err
+}
+
+// mdfIDBlock returns the identification block at the start of every MDF file.
+func mdfIDBlock() []byte {
+	id := make([]byte, mdfIDSize)
+	copy(id, "MDF     4.10    mapache ")
+	binary.LittleEndian.PutUint16(id[28:], mdfVersion)
+	return id
+}
+
+// mdfTimeData returns the data of a header or file history block with the given time, padded to size.
+func mdfTimeData(t time.Time, size int) []byte {
+	data := make([]byte, size)
+	if !t.IsZero() {
+		binary.LittleEndian.PutUint64(data, uint64(t.UnixNano()))
+	}
+	return data
+}
+
+// mdfChannelGroupData returns the data of a channel group block with the given number of records.
+func mdfChannelGroupData(count int) []byte {
+	data := make([]byte, 32)
+	binary.LittleEndian.PutUint64(data[8:], uint64(count))
+	binary.LittleEndian.PutUint32(data[24:], mdfRecordSize)
+	return data
+}
+
+// mdfWriter builds an MDF file in memory, one block at a time.
+type mdfWriter struct {
+	buf bytes.Buffer
+}
+
+// block appends a block with the given links and data, and returns its offset.
+func (m *mdfWriter) block(id string, links []uint64, data []byte) uint64 {
+	offset := uint64(m.buf.Len())
+	header := make([]byte, mdfHeaderSize, mdfHeaderSize+len(links)*8)
+	copy(header, id)
+	binary.LittleEndian.PutUint64(header[8:], uint64(mdfHeaderSize+len(links)*8+len(data)))
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(links)))
+	for _, link := range links {
+		header = binary.LittleEndian.AppendUint64(header, link)
+	}
+	m.buf.Write(header)
+	m.buf.Write(data)
+	// blocks are aligned to 8 bytes
+	m.buf.Write(make([]byte, (8-m.buf.Len()%8)%8))
+	return offset
+}
+
+// patch overwrites the links and data of a block written by block, which must keep the same sizes.
+func (m *mdfWriter) patch(offset uint64, links []uint64, data []byte) {
+	b := m.buf.Bytes()[offset+mdfHeaderSize:]
+	for i, link := range links {
+		binary.LittleEndian.PutUint64(b[i*8:], link)
+	}
+	copy(b[len(links)*8:], data)
+}
+
+// tx appends a text block and returns its offset.
+func (m *mdfWriter) tx(text string) uint64 {
+	return m.block("##TX", nil, append([]byte(text), 0))
+}
+
+// md appends an XML metadata block and returns its offset.
+func (m *mdfWriter) md(text string) uint64 {
+	return m.block("##MD", nil, append([]byte(text), 0))
+}
+
+// channel appends a channel block and returns its offset.
+func (m *mdfWriter) channel(next uint64, name string, channelType uint8, dataType uint8, byteOffset int, conversion uint64, unit uint64) uint64 {
+	data := make([]byte, 72)
+	data[0] = channelType
+	if channelType == mdfChannelMaster {
+		data[1] = mdfSyncTime
+	}
+	data[2] = dataType
+	binary.LittleEndian.PutUint32(data[4:], uint32(byteOffset))
+	binary.LittleEndian.PutUint32(data[8:], 64)
+	return m.block("##CN", []uint64{next, 0, m.tx(name), 0, conversion, 0, unit, 0}, data)
+}
+
+// linearConversion appends a linear conversion block (physical = offset + factor * raw) and returns its offset.
+func (m *mdfWriter) linearConversion(offset float64, factor float64, unit string) uint64 {
+	unitLink := uint64(0)
+	if unit != "" {
+		unitLink = m.tx(unit)
+	}
+	data := make([]byte, 24, 40)
+	data[0] = mdfLinear
+	binary.LittleEndian.PutUint16(data[6:], 2)
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(offset))
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(factor))
+	return m.block("##CC", []uint64{0, unitLink, 0, 0}, data)
+}
+
+// valueToTextConversion appends a value to text conversion block for a value table and returns its offset.
+// Values without a label are converted to an empty default text.
+func (m *mdfWriter) valueToTextConversion(table ValueTable) uint64 {
+	values := make([]int, 0, len(table))
+	for value := range table {
+		values = append(values, value)
+	}
+	sort.Ints(values)
+	links := []uint64{0, 0, 0, 0}
+	data := make([]byte, 24)
+	data[0] = mdfValueToText
+	binary.LittleEndian.PutUint16(data[4:], uint16(len(values)+1))
+	binary.LittleEndian.PutUint16(data[6:], uint16(len(values)))
+	for _, value := range values {
+		links = append(links, m.tx(table[value]))
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(float64(value)))
+	}
+	links = append(links, 0)
+	return m.block("##CC", links, data)
+}
+
+// ReadMDFFile reads the trip and signals of an MDF4 file at the given path. See ReadMDF.
+func ReadMDFFile(path string) (Trip, []Signal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Trip{}, nil, err
+	}
+	return ReadMDF(bytes.NewReader(data))
+}
+
+// ReadMDF reads the trip and signals of an MDF4 file written by WriteMDF.
+// Signals are returned grouped by Name in ascending order, and by Timestamp within each group.
+// Only the subset of MDF4 used by WriteMDF is supported: sorted data groups with a master time channel and one
+// value channel, and linear or value to text conversions.
+func ReadMDF(r io.Reader) (Trip, []Signal, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Trip{}, nil, err
+	}
+	if len(data) < mdfIDSize || string(data[:8]) != "MDF     " {
+		return Trip{}, nil, fmt.Errorf("invalid mdf file, missing identification block")
+	} else if version := binary.LittleEndian.Uint16(data[28:]); version < 400 {
+		return Trip{}, nil, fmt.Errorf("unsupported mdf version %d", version)
+	}
+	m := mdfReader{data: data}
+	hd, err := m.block(mdfIDSize, "##HD")
+	if err != nil {
+		return Trip{}, nil, err
+	}
+	if len(hd.links) < 6 || len(hd.data) < 8 {
+		return Trip{}, nil, fmt.Errorf("invalid mdf header block")
+	}
+	start := time.Unix(0, int64(binary.LittleEndian.Uint64(hd.data)))
+	trip, err := m.trip(hd.links[5])
+	if err != nil {
+		return Trip{}, nil, err
+	}
+	trip.StartTime = start
+
+	signals := []Signal{}
+	for dgOffset := hd.links[0]; dgOffset != 0; {
+		dg, err := m.block(dgOffset, "##DG")
+		if err != nil {
+			return Trip{}, nil, err
+		} else if len(dg.links) < 3 || len(dg.data) < 1 || dg.data[0] != 0 {
+			return Trip{}, nil, fmt.Errorf("unsupported mdf data group at %d", dgOffset)
+		}
+		groupSignals, err := m.dataGroup(dg, start)
+		if err != nil {
+			return Trip{}, nil, err
+		}
+		for i := range groupSignals {
+			groupSignals[i].VehicleID = trip.VehicleID
+		}
+		signals = append(signals, groupSignals...)
+		dgOffset = dg.links[0]
+	}
+	return trip, signals, nil
+}
+
+// mdfBlock is a block read from an MDF file.
+type mdfBlock struct {
+	links []uint64
+	data  []byte
+}
+
+// mdfReader reads blocks from an MDF file held in memory.
+type mdfReader struct {
+	data []byte
+}
+
+// block reads the block at the given offset, checking its ID.
+func (m *mdfReader) block(offset uint64, id string) (mdfBlock, error) {
+	if offset+mdfHeaderSize > uint64(len(m.data)) {
+		return mdfBlock{}, fmt.Errorf("invalid mdf link %d", offset)
+	} else if string(m.data[offset:offset+4]) != id {
+		return mdfBlock{}, fmt.Errorf("invalid mdf block at %d, expected %s, got %q", offset, id, m.data[offset:offset+4])
+	}
+	length := binary.LittleEndian.Uint64(m.data[offset+8:])
+	linkCount := binary.LittleEndian.Uint64(m.data[offset+16:])
+	if length < mdfHeaderSize+linkCount*8 || offset+length > uint64(len(m.data)) {
+		return mdfBlock{}, fmt.Errorf("invalid mdf block length %d at %d", length, offset)
+	}
+	block := mdfBlock{
+		links: make([]uint64, linkCount),
+		data:  m.data[offset+mdfHeaderSize+linkCount*8 : offset+length],
+	}
+	for i := range block.links {
+		block.links[i] = binary.LittleEndian.Uint64(m.data[offset+mdfHeaderSize+uint64(i)*8:])
+	}
+	return block, nil
+}
+
+// text reads the zero terminated string of a TX or MD block, or returns an empty string for a nil link.
+func (m *mdfReader) text(offset uint64) (string, error) {
+	if offset == 0 {
+		return "", nil
+	}
+	block, err := m.block(offset, "##TX")
+	if err != nil {
+		if block, err = m.block(offset, "##MD"); err != nil {
+			return "", err
+		}
+	}
+	text, _, _ := bytes.Cut(block.data, []byte{0})
+	return string(text), nil
+}
+
+// trip reads the trip metadata from the comment of the header block.
+func (m *mdfReader) trip(commentOffset uint64) (Trip, error) {
+	text, err := m.text(commentOffset)
+	if err != nil || text == "" || !strings.HasPrefix(strings.TrimSpace(text), "<") {
+		return Trip{}, err
+	}
+	var comment mdfHDComment
+	if err := xml.Unmarshal([]byte(text), &comment); err != nil {
+		return Trip{}, fmt.Errorf("invalid mdf header comment: %v", err)
+	}
+	trip := Trip{Name: comment.TX}
+	for _, property := range comment.Properties {
+		switch property.Name {
+		case "trip_id":
+			trip.ID = property.Value
+		case "vehicle_id":
+			trip.VehicleID = property.Value
+		case "name":
+			trip.Name = property.Value
+		case "description":
+			trip.Description = property.Value
+		case "end_time":
+			trip.EndTime, _ = time.Parse(time.RFC3339Nano, property.Value)
+		}
+	}
+	return trip, nil
+}
+
+// mdfChannel is a channel read from an MDF file.
+type mdfChannel struct {
+	name       string
+	master     bool
+	dataType   uint8
+	byteOffset int
+	bitCount   int
+	conversion mdfConversion
+}
+
+// mdfConversion is a conversion read from an MDF file.
+type mdfConversion struct {
+	conversionType uint8
+	values         []float64
+	texts          []string
+}
+
+// dataGroup reads the signals of a data group with a single channel group.
+func (m *mdfReader) dataGroup(dg mdfBlock, start time.Time) ([]Signal, error) {
+	cg, err := m.block(dg.links[1], "##CG")
+	if err != nil {
+		return nil, err
+	} else if len(cg.links) < 2 || len(cg.data) < 32 {
+		return nil, fmt.Errorf("invalid mdf channel group")
+	}
+	count := int(binary.LittleEndian.Uint64(cg.data[8:]))
+	recordSize := int(binary.LittleEndian.Uint32(cg.data[24:]))
+	var master, value *mdfChannel
+	for cnOffset := cg.links[1]; cnOffset != 0; {
+		cn, err := m.block(cnOffset, "##CN")
+		if err != nil {
+			return nil, err
+		} else if len(cn.links) < 5 || len(cn.data) < 12 {
+			return nil, fmt.Errorf("invalid mdf channel at %d", cnOffset)
+		}
+		channel := &mdfChannel{
+			master:     cn.data[0] == mdfChannelMaster,
+			dataType:   cn.data[2],
+			byteOffset: int(binary.LittleEndian.Uint32(cn.data[4:])),
+			bitCount:   int(binary.LittleEndian.Uint32(cn.data[8:])),
+		}
+		if channel.name, err = m.text(cn.links[2]); err != nil {
+			return nil, err
+		} else if channel.conversion, err = m.conversion(cn.links[4]); err != nil {
+			return nil, err
+		} else if cn.data[3] != 0 || channel.bitCount != 64 || channel.byteOffset+8 > recordSize {
+			return nil, fmt.Errorf("unsupported mdf channel %s, expected a byte aligned 64-bit value", channel.name)
+		}
+		if channel.master {
+			master = channel
+		} else if value == nil {
+			value = channel
+		}
+		cnOffset = cn.links[0]
+	}
+	if master == nil || value == nil {
+		return nil, fmt.Errorf("unsupported mdf channel group, expected a master and a value channel")
+	}
+
+	records := []byte{}
+	if dg.links[2] != 0 {
+		dt, err := m.block(dg.links[2], "##DT")
+		if err != nil {
+			return nil, err
+		}
+		records = dt.data
+	}
+	if len(records) < count*recordSize {
+		return nil, fmt.Errorf("invalid mdf data for %s, expected %d records of %d bytes, got %d bytes", value.name, count, recordSize, len(records))
+	}
+	signals := make([]Signal, count)
+	for i := range signals {
+		record := records[i*recordSize : (i+1)*recordSize]
+		seconds, _, _ := master.read(record)
+		timestamp := start.UnixMicro() + int64(math.Round(seconds*1e6))
+		physical, raw, label := value.read(record)
+		signals[i] = Signal{
+			Timestamp:  int(timestamp),
+			Name:       value.name,
+			Value:      physical,
+			RawValue:   raw,
+			Label:      label,
+			ProducedAt: time.UnixMicro(timestamp),
+		}
+	}
+	return signals, nil
+}
+
+// read returns the physical value, raw value, and label of the channel in a record.
+// The raw value of a float channel is its value truncated to an integer.
+func (c *mdfChannel) read(record []byte) (float64, int, string) {
+	bits := binary.LittleEndian.Uint64(record[c.byteOffset:])
+	var raw float64
+	switch c.dataType {
+	case mdfFloatLE:
+		raw = math.Float64frombits(bits)
+	case mdfSignedLE:
+		raw = float64(int64(bits))
+	default:
+		raw = float64(bits)
+	}
+	rawValue := int(int64(bits))
+	if c.dataType == mdfFloatLE {
+		rawValue = int(raw)
+	}
+	switch c.conversion.conversionType {
+	case mdfLinear:
+		return c.conversion.values[0] + c.conversion.values[1]*raw, rawValue, ""
+	case mdfValueToText:
+		for i, value := range c.conversion.values {
+			if value == raw {
+				return raw, rawValue, c.conversion.texts[i]
+			}
+		}
+	}
+	return raw, rawValue, ""
+}
+
+// conversion reads the conversion block at the given offset, or returns no conversion for a nil link.
+func (m *mdfReader) conversion(offset uint64) (mdfConversion, error) {
+	if offset == 0 {
+		return mdfConversion{}, nil
+	}
+	cc, err := m.block(offset, "##CC")
+	if err != nil {
+		return mdfConversion{}, err
+	} else if len(cc.data) < 24 {
+		return mdfConversion{}, fmt.Errorf("invalid mdf conversion at %d", offset)
+	}
+	conversion := mdfConversion{conversionType: cc.data[0]}
+	valueCount := int(binary.LittleEndian.Uint16(cc.data[6:]))
+	if len(cc.data) < 24+valueCount*8 {
+		return mdfConversion{}, fmt.Errorf("invalid mdf conversion at %d", offset)
+	}
+	for i := 0; i < valueCount; i++ {
+		conversion.values = append(conversion.values, math.Float64frombits(binary.LittleEndian.Uint64(cc.data[24+i*8:])))
+	}
+	switch conversion.conversionType {
+	case mdfConversionNone:
+	case mdfLinear:
+		if valueCount != 2 {
+			return mdfConversion{}, fmt.Errorf("invalid mdf linear conversion at %d", offset)
+		}
+	case mdfValueToText:
+		if len(cc.links) < 4+valueCount {
+			return mdfConversion{}, fmt.Errorf("invalid mdf value to text conversion at %d", offset)
+		}
+		for _, link := range cc.links[4 : 4+valueCount] {
+			text, err := m.text(link)
+			if err != nil {
+				return mdfConversion{}, err
+			}
+			conversion.texts = append(conversion.texts, text)
+		}
+	default:
+		return mdfConversion{}, fmt.Errorf("unsupported mdf conversion type %d", conversion.conversionType)
+	}
+	return conversion, nil
+}
@@ -0,0 +1,203 @@
+package mapache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testMDFSignals returns signals of a trip, with a message describing the conversions of some of them.
+func testMDFSignals() (Trip, []Signal, Message) {
+	message := Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil).WithValueTable(ValueTable{0: "off", 1: "idle", 3: "drive"}),
+		NewField("ecu_motor_temp", 2, Signed, BigEndian, nil).WithScale(0.1, -40, "degC"),
+		NewField("ecu_gear", 1, Unsigned, BigEndian, nil),
+	}
+	start := time.UnixMicro(1697040000000000)
+	trip := Trip{
+		ID:          "trip-1",
+		VehicleID:   "gr24",
+		Name:        "Endurance",
+		Description: "Practice & tuning <run 2>",
+		StartTime:   start,
+		EndTime:     start.Add(time.Minute),
+	}
+	signals := []Signal{}
+	for i := 0; i < 50; i++ {
+		timestamp := int(start.UnixMicro()) + i*10003
+		state := []int{0, 1, 3, 2}[i%4]
+		signals = append(signals,
+			Signal{Timestamp: timestamp, Name: "ecu_state", Value: float64(state), RawValue: state, Label: ValueTable{0: "off", 1: "idle", 3: "drive"}[state]},
+			Signal{Timestamp: timestamp + 7, Name: "ecu_motor_temp", Value: float64(i*25)*0.1 - 40, RawValue: i * 25},
+			Signal{Timestamp: timestamp + 11, Name: "ecu_gear", Value: float64(i % 5), RawValue: i % 5},
+			Signal{Timestamp: timestamp + 13, Name: "imu_accel_x", Value: float64(i) / 3, RawValue: i / 3},
+		)
+	}
+	for i := range signals {
+		signals[i].VehicleID = trip.VehicleID
+		signals[i].ProducedAt = time.UnixMicro(int64(signals[i].Timestamp))
+	}
+	return trip, signals, message
+}
+
+// signalsByName returns the signals with the given name.
+func signalsByName(signals []Signal, name string) []Signal {
+	filtered := []Signal{}
+	for _, signal := range signals {
+		if signal.Name == name {
+			filtered = append(filtered, signal)
+		}
+	}
+	return filtered
+}
+
+func TestMDFRoundTrip(t *testing.T) {
+	trip, signals, message := testMDFSignals()
+	var buf bytes.Buffer
+	if err := WriteMDF(&buf, trip, signals, message); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	readTrip, readSignals, err := ReadMDF(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if readTrip.ID != trip.ID || readTrip.VehicleID != trip.VehicleID || readTrip.Name != trip.Name ||
+		readTrip.Description != trip.Description || !readTrip.StartTime.Equal(trip.StartTime) || !readTrip.EndTime.Equal(trip.EndTime) {
+		t.Errorf("Expected %+v, got %+v", trip, readTrip)
+	}
+	if len(readSignals) != len(signals) {
+		t.Fatalf("Expected %d signals, got %d", len(signals), len(readSignals))
+	}
+	if readSignals[0].Name != "ecu_gear" || readSignals[len(readSignals)-1].Name != "imu_accel_x" {
+		t.Errorf("Expected signals grouped by name, got %s first and %s last", readSignals[0].Name, readSignals[len(readSignals)-1].Name)
+	}
+	for _, name := range []string{"ecu_state", "ecu_motor_temp", "ecu_gear"} {
+		expected, got := signalsByName(signals, name), signalsByName(readSignals, name)
+		for i := range expected {
+			if !expected[i].ProducedAt.Equal(got[i].ProducedAt) {
+				t.Errorf("Expected %v, got %v", expected[i].ProducedAt, got[i].ProducedAt)
+			}
+			got[i].ProducedAt = expected[i].ProducedAt
+		}
+		if !reflect.DeepEqual(expected, got) {
+			t.Errorf("Expected %+v, got %+v", expected[:2], got[:2])
+		}
+	}
+	t.Run("Test unknown signal", func(t *testing.T) {
+		got := signalsByName(readSignals, "imu_accel_x")
+		for i, expected := range signalsByName(signals, "imu_accel_x") {
+			if got[i].Timestamp != expected.Timestamp || got[i].Value != expected.Value || got[i].RawValue != int(expected.Value) {
+				t.Errorf("Expected %+v, got %+v", expected, got[i])
+			}
+		}
+	})
+	t.Run("Test without message", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteMDF(&buf, Trip{}, signals); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		readTrip, readSignals, err := ReadMDF(&buf)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if readTrip.StartTime.UnixMicro() != int64(signals[0].Timestamp) {
+			t.Errorf("Expected start time of the first signal, got %v", readTrip.StartTime)
+		}
+		temps := signalsByName(readSignals, "ecu_motor_temp")
+		if temps[10].Value != signalsByName(signals, "ecu_motor_temp")[10].Value || temps[10].Label != "" {
+			t.Errorf("Unexpected signal %+v", temps[10])
+		}
+	})
+	t.Run("Test file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "trip.mf4")
+		if err := WriteMDFFile(path, trip, signals, message); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		_, fileSignals, err := ReadMDFFile(path)
+		if err != nil || len(fileSignals) != len(signals) {
+			t.Errorf("Expected %d signals, got %d (%v)", len(signals), len(fileSignals), err)
+		}
+	})
+}
+
+func TestMDFStructure(t *testing.T) {
+	trip, signals, message := testMDFSignals()
+	var buf bytes.Buffer
+	before := time.Now()
+	if err := WriteMDF(&buf, trip, signals, message); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	after := time.Now()
+	data := buf.Bytes()
+	if string(data[:16]) != "MDF     4.10    " || binary.LittleEndian.Uint16(data[28:]) != 410 {
+		t.Errorf("Unexpected identification block % X", data[:mdfIDSize])
+	}
+	if string(data[mdfIDSize:mdfIDSize+4]) != "##HD" {
+		t.Errorf("Expected header block at %d, got %q", mdfIDSize, data[mdfIDSize:mdfIDSize+4])
+	}
+	// every block is 8-byte aligned, and every link points to a block
+	blocks := map[uint64]bool{}
+	links := []uint64{}
+	for offset := uint64(mdfIDSize); offset < uint64(len(data)); {
+		if offset%8 != 0 || data[offset] != '#' || data[offset+1] != '#' {
+			t.Fatalf("Expected aligned block at %d, got % X", offset, data[offset:offset+4])
+		}
+		blocks[offset] = true
+		length := binary.LittleEndian.Uint64(data[offset+8:])
+		linkCount := binary.LittleEndian.Uint64(data[offset+16:])
+		for i := uint64(0); i < linkCount; i++ {
+			links = append(links, binary.LittleEndian.Uint64(data[offset+mdfHeaderSize+i*8:]))
+		}
+		offset += (length + 7) / 8 * 8
+	}
+	for _, link := range links {
+		if link != 0 && !blocks[link] {
+			t.Errorf("Expected link %d to point to a block", link)
+		}
+	}
+	t.Run("Test file history", func(t *testing.T) {
+		m := mdfReader{data: data}
+		hd, _ := m.block(mdfIDSize, "##HD")
+		fh, err := m.block(hd.links[1], "##FH")
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		created := time.Unix(0, int64(binary.LittleEndian.Uint64(fh.data)))
+		if created.Before(before) || created.After(after) {
+			t.Errorf("Expected creation time between %v and %v, got %v", before, after, created)
+		}
+	})
+	t.Run("Test data types", func(t *testing.T) {
+		m := mdfReader{data: data}
+		hd, _ := m.block(mdfIDSize, "##HD")
+		dataTypes := map[string]uint8{}
+		for dgOffset := hd.links[0]; dgOffset != 0; {
+			dg, _ := m.block(dgOffset, "##DG")
+			cg, _ := m.block(dg.links[1], "##CG")
+			master, _ := m.block(cg.links[1], "##CN")
+			value, err := m.block(master.links[0], "##CN")
+			if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			name, _ := m.text(value.links[2])
+			dataTypes[name] = value.data[2]
+			dgOffset = dg.links[0]
+		}
+		expected := map[string]uint8{"ecu_state": mdfUnsignedLE, "ecu_gear": mdfUnsignedLE, "ecu_motor_temp": mdfSignedLE, "imu_accel_x": mdfFloatLE}
+		if !reflect.DeepEqual(dataTypes, expected) {
+			t.Errorf("Expected %v, got %v", expected, dataTypes)
+		}
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		if _, _, err := ReadMDF(bytes.NewReader([]byte("MDF"))); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		truncated := data[:len(data)/2]
+		if _, _, err := ReadMDF(bytes.NewReader(truncated)); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package mapache
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
 
 // A Message is a single data message from a vehicle, comprised of a list of Fields.
 type Message []Field
@@ -11,32 +15,135 @@ func (m Message) Length() int {
 }
 
 // Size returns the total number of bytes in the message.
+// Byte-aligned fields are laid out one after another, while bit fields occupy the bytes
+// covered by their StartBit and BitLength. The size is whichever of the two extends further.
+// For multiplexed messages, the size is that of the largest multiplexed layout.
 func (m Message) Size() int {
+	return m.layout(nil)
+}
+
+// offsets returns the byte offset of each byte-aligned field in the message. See layout.
+func (m Message) offsets() []int {
+	offsets := make([]int, len(m))
+	m.layout(offsets)
+	return offsets
+}
+
+// layout returns the size of the message, and stores the byte offset of each byte-aligned field in offsets
+// unless it is nil. Fields are laid out one after another, except for consecutive multiplexed fields,
+// where the fields of each mux value start at the same offset and overlap each other.
+func (m Message) layout(offsets []int) int {
 	size := 0
-	for _, field := range m {
-		size += field.Size
+	end := 0
+	groupStart := -1
+	// groupEnds holds the end of the fields of each mux value in the current multiplexed group,
+	// which rarely has more than a few mux values
+	type groupEnd struct{ mux, end int }
+	var groupBuf [8]groupEnd
+	groupEnds := groupBuf[:0]
+	for i, field := range m {
+		offset := end
+		if field.IsBitField() {
+			size = max(size, field.bitSpan())
+			continue
+		} else if !field.Multiplexed {
+			end += field.Size
+			groupStart = -1
+		} else {
+			if groupStart < 0 {
+				groupStart = end
+				groupEnds = groupEnds[:0]
+			}
+			offset = groupStart
+			j := 0
+			for j < len(groupEnds) && groupEnds[j].mux != field.MuxValue {
+				j++
+			}
+			if j < len(groupEnds) {
+				offset = groupEnds[j].end
+				groupEnds[j].end += field.Size
+			} else {
+				groupEnds = append(groupEnds, groupEnd{field.MuxValue, offset + field.Size})
+			}
+			end = max(end, offset+field.Size)
+		}
+		if offsets != nil {
+			offsets[i] = offset
+		}
+		size = max(size, offset+field.Size)
 	}
 	return size
 }
 
+// MuxValue returns the value of the multiplexer field of the message, and whether the message has one.
+func (m Message) MuxValue() (int, bool) {
+	for _, field := range m {
+		if field.Multiplexer && !field.Multiplexed {
+			return field.Value, true
+		}
+	}
+	return 0, false
+}
+
+// IsPresent returns true if the field is present in the current frame of the message.
+// Fields that are not multiplexed are always present, while multiplexed fields are only
+// present when their MuxValue matches the value of the multiplexer field.
+func (m Message) IsPresent(f Field) bool {
+	if !f.Multiplexed {
+		return true
+	}
+	mux, ok := m.MuxValue()
+	return ok && mux == f.MuxValue
+}
+
 // FillFromBytes fills the Fields of a Message with the provided byte array.
 // It decodes the bytes into integer values and stores them in the Value of each Field.
+// Bit fields are given the entire byte array, and extract their own bits from it.
+// For multiplexed messages, only the fields selected by the multiplexer are decoded,
+// and the remaining multiplexed fields are cleared.
 // It returns an error if the data length does not match the size of the Message.
 func (m Message) FillFromBytes(data []byte) error {
-	if len(data) != m.Size() {
-		return fmt.Errorf("invalid data length, expected %d bytes, got %d", m.Size(), len(data))
+	// the offsets of most messages fit on the stack, which keeps decoding free of allocations
+	var buf [16]int
+	offsets := buf[:]
+	if len(m) > len(buf) {
+		offsets = make([]int, len(m))
 	}
-	counter := 0
-	for i, field := range m {
-		field.Bytes = data[counter : counter+field.Size]
-		counter += field.Size
+	offsets = offsets[:len(m)]
+	if size := m.layout(offsets); len(data) != size {
+		return fmt.Errorf("invalid data length, expected %d bytes, got %d", size, len(data))
+	}
+	fill := func(i int, field Field) {
+		if field.IsBitField() {
+			field.Bytes = data
+		} else {
+			field.Bytes = data[offsets[i] : offsets[i]+field.Size]
+		}
 		m[i] = field.Decode()
 	}
+	// the multiplexer has to be decoded before any multiplexed fields
+	for i, field := range m {
+		if !field.Multiplexed {
+			fill(i, field)
+		}
+	}
+	for i, field := range m {
+		if !field.Multiplexed {
+			continue
+		} else if m.IsPresent(field) {
+			fill(i, field)
+		} else {
+			field.Bytes = nil
+			field.Value = 0
+			m[i] = field
+		}
+	}
 	return nil
 }
 
 // FillFromInts fills the Fields of a Message with the provided integers.
 // It encodes the integers into bytes and stores them in the Bytes of each Field.
+// For Float fields, the integer is taken as the raw IEEE-754 bits, matching the Value set by FillFromBytes.
 // It returns an error if the number of integers does not match the number of Fields in the Message.
 func (m Message) FillFromInts(ints []int) error {
 	if len(ints) != m.Length() {
@@ -53,12 +160,160 @@ func (m Message) FillFromInts(ints []int) error {
 	return nil
 }
 
+// FillFromFloats fills the Fields of a Message with the provided floating point numbers.
+// Float fields are encoded natively, while integer fields are rounded to the nearest integer.
+// It returns an error if the number of floats does not match the number of Fields in the Message.
+func (m Message) FillFromFloats(floats []float64) error {
+	if len(floats) != m.Length() {
+		return fmt.Errorf("invalid floats length, expected %d, got %d", m.Length(), len(floats))
+	}
+	for i, field := range m {
+		field, err := field.EncodeFloat(floats[i])
+		if err != nil {
+			return err
+		}
+		m[i] = field
+	}
+	return nil
+}
+
+// FillFromValues fills the Fields of a Message with the provided physical values.
+// Each value is converted back to its raw form using the Factor and Offset of its Field before being encoded.
+// It returns an error if the number of values does not match the number of Fields in the Message,
+// or if any value is outside of the range of its Field.
+func (m Message) FillFromValues(values []float64) error {
+	if len(values) != m.Length() {
+		return fmt.Errorf("invalid values length, expected %d, got %d", m.Length(), len(values))
+	}
+	for i, field := range m {
+		field, err := field.EncodeValue(values[i])
+		if err != nil {
+			return err
+		}
+		m[i] = field
+	}
+	return nil
+}
+
+// Bytes assembles the byte array of the Message from the Bytes of each Field.
+// It is the inverse of FillFromBytes, and is typically called after FillFromInts.
+// Multiplexed fields that are not selected by the multiplexer are left out.
+// The layout is not checked on every call, so Messages should be created with NewMessage or checked once
+// with Validate. It returns an error if the value of a bit field does not fit in the Message.
+func (m Message) Bytes() ([]byte, error) {
+	var buf [16]int
+	offsets := buf[:]
+	if len(m) > len(buf) {
+		offsets = make([]int, len(m))
+	}
+	offsets = offsets[:len(m)]
+	data := make([]byte, m.layout(offsets))
+	for i, field := range m {
+		if !m.IsPresent(field) {
+			continue
+		} else if field.IsBitField() {
+			if err := field.insertBits(data, field.extractBits()); err != nil {
+				return nil, fmt.Errorf("invalid bit field %s: %w", field.Name, err)
+			}
+		} else {
+			copy(data[offsets[i]:offsets[i]+field.Size], field.Bytes)
+		}
+	}
+	return data, nil
+}
+
+// NewMessage creates a new Message from the given fields.
+// It returns an error if the layout of the Message is invalid (see Validate).
+func NewMessage(fields ...Field) (Message, error) {
+	message := Message(fields)
+	if err := message.Validate(); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// Validate checks the layout of the Message. Byte-aligned fields must be at least 1 byte, bit fields must be
+// 1 to 64 bits starting at a non-negative bit, and fields that can be present in the same frame must not share
+// any bits. Multiplexed fields with different MuxValues may overlap, since they are never present together.
+func (m Message) Validate() error {
+	offsets := make([]int, len(m))
+	size := m.layout(offsets)
+	bits := make([][]bool, len(m))
+	for i, field := range m {
+		bits[i] = make([]bool, size*8)
+		if !field.IsBitField() {
+			if field.Size < 1 {
+				return fmt.Errorf("invalid size %d for %s, expected at least 1 byte", field.Size, field.Name)
+			}
+			for pos := offsets[i] * 8; pos < (offsets[i]+field.Size)*8; pos++ {
+				bits[i][pos] = true
+			}
+			continue
+		} else if field.StartBit < 0 || field.BitLength > 64 {
+			return fmt.Errorf("invalid bit range %d:%d for %s", field.StartBit, field.BitLength, field.Name)
+		}
+		pos := field.StartBit
+		for n := 0; n < field.BitLength; n++ {
+			bits[i][pos] = true
+			if field.Endian == BigEndian {
+				pos = nextMotorolaBit(pos)
+			} else {
+				pos++
+			}
+		}
+	}
+	for i, a := range m {
+		for j := i + 1; j < len(m); j++ {
+			b := m[j]
+			if a.Multiplexed && b.Multiplexed && a.MuxValue != b.MuxValue {
+				continue
+			}
+			for pos := range bits[i] {
+				if bits[i][pos] && bits[j][pos] {
+					return fmt.Errorf("fields %s and %s overlap at bit %d", a.Name, b.Name, pos)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Copy returns a deep copy of the Message, so that it can be filled without modifying the original.
+func (m Message) Copy() Message {
+	message := make(Message, len(m))
+	for i, field := range m {
+		if field.Bytes != nil {
+			field.Bytes = append([]byte{}, field.Bytes...)
+		}
+		message[i] = field
+	}
+	return message
+}
+
+// DecodeSignals fills a copy of the Message with the provided byte array and returns its exported Signals.
+// Unlike FillFromBytes, the data may be longer than the Message, since frames are often padded to a fixed
+// length, in which case the trailing bytes are ignored. The Message itself is left untouched.
+// It returns an error if the data is shorter than the size of the Message.
+func (m Message) DecodeSignals(data []byte) ([]Signal, error) {
+	if len(data) < m.Size() {
+		return nil, fmt.Errorf("invalid data length, expected at least %d bytes, got %d", m.Size(), len(data))
+	}
+	message := m.Copy()
+	if err := message.FillFromBytes(data[:m.Size()]); err != nil {
+		return nil, err
+	}
+	return message.ExportSignals(), nil
+}
+
 // ExportSignals returns a list of all Signals contained in each Field of the Message.
 // Basically just calls ExportSignals on each Field and concatenates the results.
+// Multiplexed fields that are not present in the current frame are skipped.
 func (m Message) ExportSignals() []Signal {
 	signals := []Signal{}
 	for _, field := range m {
-		signals = append(signals, field.ExportSignals()...)
+		if m.IsPresent(field) {
+			signals = append(signals, field.ExportSignals()...)
+		}
 	}
 	return signals
 }
@@ -74,12 +329,66 @@ type Field struct {
 	Size   int
 	Sign   SignMode
 	Endian Endian
-	// Value is the integer value of the field.
+	// StartBit and BitLength place a bit field at an arbitrary bit position within the message.
+	// They are only used when BitLength is greater than 0, in which case Bytes holds the whole
+	// message and Endian also selects the bit numbering (BigEndian for Motorola, LittleEndian for Intel).
+	StartBit  int
+	BitLength int
+	// Multiplexer marks the field whose value selects which multiplexed fields are present in a frame.
+	Multiplexer bool
+	// Multiplexed fields are only present in a frame when the multiplexer is equal to MuxValue.
+	// Consecutive byte-aligned multiplexed fields with different MuxValues share the same bytes.
+	Multiplexed bool
+	MuxValue    int
+	// Value is the integer value of the field. For Float fields, it holds the raw IEEE-754 bits.
+	// For 64-bit Unsigned fields, values above 2^63-1 wrap around, so use Uint64Value to read them.
 	Value int
+	// Factor and Offset linearly scale Value into a physical value (Value * Factor + Offset).
+	// A Factor of 0 is treated as 1, so fields without scaling are exported as-is.
+	Factor float64
+	Offset float64
+	// Unit is the unit of the physical value, such as "V" or "degC".
+	Unit string
+	// Min and Max are the limits of the physical value. They are ignored when both are 0.
+	Min float64
+	Max float64
+	// ValueTable maps raw values of the field to named states, such as the states of a state machine.
+	ValueTable ValueTable
+	// Flags are the names of the boolean flags packed into the field, in bit order. When set, the field is
+	// exported as one Signal per flag instead of a single Signal. FlagOrder selects how the bits are counted.
+	Flags     []string
+	FlagOrder BitOrder
 	// ExportSignalFunc is the function that is used to export the field as an array of signals.
 	ExportSignalFunc ExportSignalFunc
 }
 
+// BitOrder is a type to represent the order in which the flags of a flags field are assigned to bits.
+type BitOrder int
+
+const (
+	// MSBFirst assigns flag 0 to the most significant bit of byte 0, matching CheckBit.
+	MSBFirst BitOrder = 0
+	// LSBFirst assigns flag 0 to the least significant bit of byte 0.
+	LSBFirst BitOrder = 1
+)
+
+// ValueTable maps raw integer values of a field to their labels.
+type ValueTable map[int]string
+
+// Value returns the raw value for the given label, and whether the label is in the table.
+// If multiple values share the same label, the smallest value is returned.
+func (t ValueTable) Value(label string) (int, bool) {
+	found := false
+	result := 0
+	for value, l := range t {
+		if l == label && (!found || value < result) {
+			result = value
+			found = true
+		}
+	}
+	return result, found
+}
+
 // ExportSignalFunc is a function that indicates how a field should be exported as an array of signals.
 // Any required scaling will be applied here. If ExportSignalFunc is not set, the field will be directly
 // exported as a single signal without scaling.
@@ -97,8 +406,114 @@ func NewField(name string, size int, sign SignMode, endian Endian, exportSignalF
 	}
 }
 
+// NewBitField creates a new bit field with the given name, start bit, bit length, sign, endian, and export function.
+// For LittleEndian (Intel) fields the start bit is the least significant bit of the value, and for BigEndian
+// (Motorola) fields it is the most significant bit, following the same convention as DBC files.
+// See LittleEndianBitsToUnsignedInt and BigEndianBitsToUnsignedInt for how bits are numbered.
+func NewBitField(name string, startBit int, bitLength int, sign SignMode, endian Endian, exportSignalFunc ExportSignalFunc) Field {
+	return Field{
+		Name:             name,
+		Size:             (bitLength + 7) / 8,
+		Sign:             sign,
+		Endian:           endian,
+		StartBit:         startBit,
+		BitLength:        bitLength,
+		ExportSignalFunc: exportSignalFunc,
+	}
+}
+
+// NewFlagsField creates a new unsigned Field of the given size that holds a boolean flag for each of the given names.
+// The flags are assigned to bits in the given order, and each flag is exported as its own Signal with a value of 0 or 1.
+func NewFlagsField(name string, size int, order BitOrder, flags []string) Field {
+	return Field{
+		Name:      name,
+		Size:      size,
+		Sign:      Unsigned,
+		Endian:    BigEndian,
+		Flags:     flags,
+		FlagOrder: order,
+	}
+}
+
+// WithScale returns a copy of the Field with the given factor, offset, and unit.
+// The physical value of the field is computed as Value * factor + offset.
+func (f Field) WithScale(factor float64, offset float64, unit string) Field {
+	f.Factor = factor
+	f.Offset = offset
+	f.Unit = unit
+	return f
+}
+
+// WithRange returns a copy of the Field with the given minimum and maximum physical values.
+func (f Field) WithRange(min float64, max float64) Field {
+	f.Min = min
+	f.Max = max
+	return f
+}
+
+// WithValueTable returns a copy of the Field with the given value table.
+func (f Field) WithValueTable(table ValueTable) Field {
+	f.ValueTable = table
+	return f
+}
+
+// AsMultiplexer returns a copy of the Field marked as the multiplexer of its message.
+func (f Field) AsMultiplexer() Field {
+	f.Multiplexer = true
+	return f
+}
+
+// WithMuxValue returns a copy of the Field that is only present when the multiplexer is equal to value.
+func (f Field) WithMuxValue(value int) Field {
+	f.Multiplexed = true
+	f.MuxValue = value
+	return f
+}
+
+// IsBitField returns true if the field is placed by StartBit and BitLength instead of being byte-aligned.
+func (f Field) IsBitField() bool {
+	return f.BitLength > 0
+}
+
+// bitSize returns the number of bits holding the value of the field.
+func (f Field) bitSize() int {
+	if f.IsBitField() {
+		return f.BitLength
+	}
+	return f.Size * 8
+}
+
+// bitSpan returns the number of message bytes covered by a bit field.
+func (f Field) bitSpan() int {
+	return BitSpan(f.StartBit, f.BitLength, f.Endian == BigEndian)
+}
+
+// extractBits returns the raw, unsigned bits of a bit field from its Bytes.
+func (f Field) extractBits() uint64 {
+	if f.Endian == BigEndian {
+		return BigEndianBitsToUnsignedInt(f.Bytes, f.StartBit, f.BitLength)
+	}
+	return LittleEndianBitsToUnsignedInt(f.Bytes, f.StartBit, f.BitLength)
+}
+
+// insertBits writes the raw bits of a bit field into data.
+func (f Field) insertBits(data []byte, raw uint64) error {
+	if f.Endian == BigEndian {
+		return BigEndianUnsignedIntToBits(data, f.StartBit, f.BitLength, raw)
+	}
+	return LittleEndianUnsignedIntToBits(data, f.StartBit, f.BitLength, raw)
+}
+
 // Decode takes a Field object, decodes the bytes into an integer value, and returns the decoded Field object.
 func (f Field) Decode() Field {
+	if f.IsBitField() {
+		raw := f.extractBits()
+		if f.Sign == Signed && f.BitLength < 64 && raw>>uint(f.BitLength-1)&1 == 1 {
+			raw |= ^uint64(0) << uint(f.BitLength)
+		}
+		f.Value = int(raw)
+		return f
+	}
 	if f.Sign == Signed && f.Endian == BigEndian {
 		f.Value = BigEndianBytesToSignedInt(f.Bytes)
 	} else if f.Sign == Signed && f.Endian == LittleEndian {
@@ -107,29 +522,304 @@ func (f Field) Decode() Field {
 		f.Value = BigEndianBytesToUnsignedInt(f.Bytes)
 	} else if f.Sign == Unsigned && f.Endian == LittleEndian {
 		f.Value = LittleEndianBytesToUnsignedInt(f.Bytes)
+	} else if f.Sign == Float && f.Endian == BigEndian {
+		f.Value = BigEndianBytesToUnsignedInt(f.Bytes)
+	} else if f.Sign == Float && f.Endian == LittleEndian {
+		f.Value = LittleEndianBytesToUnsignedInt(f.Bytes)
 	}
 	return f
 }
 
 // Encode takes a Field object, encodes the integer value into bytes, and returns the encoded Field object.
+// It returns an error if the Value of an Unsigned field is negative, so Unsigned values above the range
+// of int must be encoded with EncodeUint64 or EncodeBigInt instead.
 func (f Field) Encode() (Field, error) {
+	if f.IsBitField() {
+		return f.encodeBits()
+	}
+	if f.Sign == Unsigned && f.Value < 0 {
+		return f, fmt.Errorf("cannot convert negative number to binary")
+	}
 	var err error
 	if f.Sign == Signed && f.Endian == BigEndian {
 		f.Bytes, err = BigEndianSignedIntToBinary(f.Value, f.Size)
 	} else if f.Sign == Signed && f.Endian == LittleEndian {
 		f.Bytes, err = LittleEndianSignedIntToBinary(f.Value, f.Size)
+	} else if f.Sign == Unsigned && f.Endian == BigEndian && f.Size == 8 {
+		f.Bytes, err = BigEndianUint64ToBinary(uint64(f.Value), f.Size)
+	} else if f.Sign == Unsigned && f.Endian == LittleEndian && f.Size == 8 {
+		f.Bytes, err = LittleEndianUint64ToBinary(uint64(f.Value), f.Size)
 	} else if f.Sign == Unsigned && f.Endian == BigEndian {
 		f.Bytes, err = BigEndianUnsignedIntToBinary(f.Value, f.Size)
 	} else if f.Sign == Unsigned && f.Endian == LittleEndian {
 		f.Bytes, err = LittleEndianUnsignedIntToBinary(f.Value, f.Size)
+	} else if f.Sign == Float && f.Endian == BigEndian {
+		f.Bytes, err = BigEndianFloatToBinary(f.FloatValue(), f.Size)
+	} else if f.Sign == Float && f.Endian == LittleEndian {
+		f.Bytes, err = LittleEndianFloatToBinary(f.FloatValue(), f.Size)
+	} else {
+		return f, fmt.Errorf("invalid sign or endian")
+	}
+	return f, err
+}
+
+// FloatValue returns the value of the field as a floating point number.
+// For Float fields, Value is interpreted as raw IEEE-754 bits of the field's precision
+// (16, 32, or 64 bits), and NaN is returned for any other size. For integer fields,
+// Value is simply converted to a float64.
+func (f Field) FloatValue() float64 {
+	if f.Sign == Unsigned && f.bitSize() == 64 {
+		return float64(f.Uint64Value())
+	} else if f.Sign == Unsigned && f.bitSize() > 64 {
+		v, _ := new(big.Float).SetInt(f.BigIntValue()).Float64()
+		return v
+	} else if f.Sign != Float {
+		return float64(f.Value)
+	}
+	v, err := floatFromBits(uint64(f.Value), f.bitSize())
+	if err != nil {
+		return math.NaN()
+	}
+	return v
+}
+
+// Uint64Value returns the Value of the field as an unsigned 64-bit integer, which is able to hold
+// the full range of 64-bit Unsigned fields.
+func (f Field) Uint64Value() uint64 {
+	return uint64(f.Value)
+}
+
+// BigIntValue returns the value of an Unsigned field as a big integer, decoded directly from Bytes.
+// It should be used for fields wider than 8 bytes, whose values cannot be held by Value.
+func (f Field) BigIntValue() *big.Int {
+	if f.IsBitField() {
+		return new(big.Int).SetUint64(f.extractBits())
+	} else if f.Endian == LittleEndian {
+		return LittleEndianBytesToBigInt(f.Bytes)
+	}
+	return BigEndianBytesToBigInt(f.Bytes)
+}
+
+// EncodeBigInt takes a Field object and a non-negative big integer, encodes the integer into bytes,
+// and returns the encoded Field object. It should be used for Unsigned fields wider than 8 bytes.
+// It returns an error if the value is nil, negative, or does not fit in the field.
+func (f Field) EncodeBigInt(v *big.Int) (Field, error) {
+	if f.Sign != Unsigned {
+		return f, fmt.Errorf("cannot encode big integer into %s, which is not unsigned", f.Name)
+	} else if v == nil || v.Sign() < 0 {
+		return f, fmt.Errorf("cannot encode nil or negative big integer into %s", f.Name)
+	} else if f.IsBitField() {
+		if v.BitLen() > f.BitLength {
+			return f, fmt.Errorf("number is too large to fit in %d bits", f.BitLength)
+		}
+		return f.EncodeUint64(v.Uint64())
+	}
+	var err error
+	if f.Endian == BigEndian {
+		f.Bytes, err = BigEndianBigIntToBinary(v, f.Size)
+	} else if f.Endian == LittleEndian {
+		f.Bytes, err = LittleEndianBigIntToBinary(v, f.Size)
+	} else {
+		return f, fmt.Errorf("invalid sign or endian")
+	}
+	if err != nil {
+		return f, err
+	}
+	return f.Decode(), nil
+}
+
+// EncodeUint64 takes a Field object and an unsigned 64-bit integer, encodes the integer into bytes,
+// and returns the encoded Field object. Unlike Encode, the full 64-bit range is supported.
+// It returns an error if the value does not fit in the field.
+func (f Field) EncodeUint64(v uint64) (Field, error) {
+	if f.Sign != Unsigned {
+		return f, fmt.Errorf("cannot encode unsigned 64-bit value into %s, which is not unsigned", f.Name)
+	}
+	var err error
+	if f.IsBitField() {
+		if f.BitLength < 64 && v >= 1<<uint(f.BitLength) {
+			return f, fmt.Errorf("number is too large to fit in %d bits", f.BitLength)
+		}
+		f.Value = int(v)
+		return f.encodeBits()
+	} else if f.Endian == BigEndian {
+		f.Bytes, err = BigEndianUint64ToBinary(v, f.Size)
+	} else if f.Endian == LittleEndian {
+		f.Bytes, err = LittleEndianUint64ToBinary(v, f.Size)
 	} else {
 		return f, fmt.Errorf("invalid sign or endian")
 	}
+	if err != nil {
+		return f, err
+	}
+	f.Value = int(v)
+	return f, nil
+}
+
+// EncodeFloat takes a Field object and a floating point value, encodes the value into bytes,
+// and returns the encoded Field object. Float fields store the raw IEEE-754 bits of v in Value,
+// while integer fields round v to the nearest integer.
+func (f Field) EncodeFloat(v float64) (Field, error) {
+	if f.Sign != Float {
+		f.Value = int(math.Round(v))
+		return f.Encode()
+	}
+	bits, err := floatToBits(v, f.bitSize())
+	if err != nil {
+		return f, err
+	}
+	f.Value = int(bits)
+	return f.Encode()
+}
+
+// encodeBits encodes the integer value of a bit field into a byte array just large enough to hold the field.
+func (f Field) encodeBits() (Field, error) {
+	if f.BitLength > 64 {
+		return f, fmt.Errorf("bit field %s is longer than 64 bits", f.Name)
+	} else if f.Endian != BigEndian && f.Endian != LittleEndian {
+		return f, fmt.Errorf("invalid sign or endian")
+	}
+	if f.Sign == Signed && f.BitLength < 64 {
+		minValue := -1 << (f.BitLength - 1)
+		maxValue := (1 << (f.BitLength - 1)) - 1
+		if f.Value < minValue || f.Value > maxValue {
+			return f, fmt.Errorf("number is too large to fit in %d bits", f.BitLength)
+		}
+	} else if f.Sign == Unsigned {
+		if f.Value < 0 && f.BitLength < 64 {
+			return f, fmt.Errorf("cannot convert negative number to binary")
+		} else if f.BitLength < 63 && f.Value >= 1<<f.BitLength {
+			return f, fmt.Errorf("number is too large to fit in %d bits", f.BitLength)
+		}
+	} else if f.Sign == Float {
+		if _, err := floatFromBits(0, f.BitLength); err != nil {
+			return f, err
+		}
+	} else if f.Sign != Signed {
+		return f, fmt.Errorf("invalid sign or endian")
+	}
+	raw := uint64(f.Value)
+	if f.BitLength < 64 {
+		raw &= 1<<uint(f.BitLength) - 1
+	}
+	f.Bytes = make([]byte, f.bitSpan())
+	err := f.insertBits(f.Bytes, raw)
 	return f, err
 }
 
+// scale returns the Factor of the field, defaulting to 1 when no Factor is set.
+func (f Field) scale() float64 {
+	if f.Factor == 0 {
+		return 1
+	}
+	return f.Factor
+}
+
+// PhysicalValue returns the value of the field after applying Factor and Offset.
+func (f Field) PhysicalValue() float64 {
+	return f.FloatValue()*f.scale() + f.Offset
+}
+
+// EncodeValue takes a Field object and a physical value, converts the value back to its raw form using
+// Factor and Offset, encodes it into bytes, and returns the encoded Field object.
+// It returns an error if the value is outside of Min and Max, or if the raw value does not fit in the field.
+func (f Field) EncodeValue(v float64) (Field, error) {
+	if (f.Min != 0 || f.Max != 0) && (v < f.Min || v > f.Max) {
+		return f, fmt.Errorf("value %g for %s is outside of range [%g, %g]", v, f.Name, f.Min, f.Max)
+	}
+	return f.EncodeFloat((v - f.Offset) / f.scale())
+}
+
+// Label returns the label of the current Value of the field from its ValueTable,
+// or an empty string if the value has no label.
+func (f Field) Label() string {
+	return f.ValueTable[f.Value]
+}
+
+// EncodeLabel takes a Field object and a label from its ValueTable, encodes the matching raw value
+// into bytes, and returns the encoded Field object.
+// It returns an error if the label is not in the ValueTable.
+func (f Field) EncodeLabel(label string) (Field, error) {
+	value, ok := f.ValueTable.Value(label)
+	if !ok {
+		return f, fmt.Errorf("unknown label %q for %s", label, f.Name)
+	}
+	f.Value = value
+	return f.Encode()
+}
+
+// CheckFlag takes a Field object and a flag index, and returns the value of the flag (0 or 1).
+// For byte-aligned fields, MSBFirst counts bits the same way as CheckBit, while LSBFirst starts
+// from the least significant bit of byte 0. For bit fields, the flags are counted from the most
+// (MSBFirst) or least (LSBFirst) significant bit of the field value.
+func (f Field) CheckFlag(flag int) int {
+	if f.IsBitField() {
+		pos := flag
+		if f.FlagOrder == MSBFirst {
+			pos = f.BitLength - 1 - flag
+		}
+		if pos < 0 || pos >= 64 {
+			return 0
+		}
+		return int(uint64(f.Value)>>uint(pos)) & 1
+	}
+	if f.FlagOrder == LSBFirst {
+		return readBit(f.Bytes, flag)
+	}
+	return f.CheckBit(flag)
+}
+
+// EncodeFlags takes a Field object and the names of the flags that are set, encodes the flags
+// into bytes, and returns the encoded Field object. Flags that are not named are cleared.
+// The Value of the field is only updated if it fits in 8 bytes, since wider flags are only held in Bytes.
+// It returns an error if a name is not one of the Flags of the field.
+func (f Field) EncodeFlags(set ...string) (Field, error) {
+	bits := map[string]int{}
+	for i, name := range f.Flags {
+		bits[name] = i
+	}
+	if f.IsBitField() {
+		var raw uint64
+		for _, name := range set {
+			i, ok := bits[name]
+			if !ok {
+				return f, fmt.Errorf("unknown flag %q for %s", name, f.Name)
+			} else if i >= f.BitLength {
+				return f, fmt.Errorf("flag %q does not fit in %d bits", name, f.BitLength)
+			}
+			pos := i
+			if f.FlagOrder == MSBFirst {
+				pos = f.BitLength - 1 - i
+			}
+			raw |= 1 << uint(pos)
+		}
+		f.Value = int(raw)
+		return f.Encode()
+	}
+	data := make([]byte, f.Size)
+	for _, name := range set {
+		i, ok := bits[name]
+		if !ok {
+			return f, fmt.Errorf("unknown flag %q for %s", name, f.Name)
+		} else if i >= f.Size*8 {
+			return f, fmt.Errorf("flag %q does not fit in %d bytes", name, f.Size)
+		}
+		pos := i
+		if f.FlagOrder == MSBFirst {
+			pos = i/8*8 + 7 - i%8
+		}
+		writeBit(data, pos, 1)
+	}
+	f.Bytes = data
+	if f.Size <= 8 {
+		f = f.Decode()
+	}
+	return f, nil
+}
+
 // CheckBit takes a Field object and a bit position, and returns the integer value of the bit at the given position (0 or 1).
 // Bit positions are counted from left to right, where bit 0 is the leftmost bit.
+// For bit fields, the positions are relative to the whole message rather than the field.
 func (f Field) CheckBit(bit int) int {
 	byteIndex := bit / 8
 	bitPosition := 7 - (bit % 8)
@@ -140,18 +830,48 @@ func (f Field) CheckBit(bit int) int {
 }
 
 // ExportSignals takes a Field object and exports it as an array of signals.
+// Fields with Flags and no ExportSignalFunc are exported with FlagsSignalExportFunc.
 func (f Field) ExportSignals() []Signal {
-	if f.ExportSignalFunc == nil {
-		return DefaultSignalExportFunc(f)
+	if f.ExportSignalFunc != nil {
+		return f.ExportSignalFunc(f)
+	} else if len(f.Flags) > 0 {
+		return FlagsSignalExportFunc(f)
 	}
-	return f.ExportSignalFunc(f)
+	return DefaultSignalExportFunc(f)
 }
 
-// DefaultSignalExportFunc is the default export function for a field. It exports the field as a single signal with no scaling.
+// DefaultSignalExportFunc is the default export function for a field. It exports the field as a single signal,
+// scaled by the Factor and Offset of the field if they are set.
+// Float fields are exported with their floating point value, and the raw IEEE-754 bits as the RawValue.
+// If the field has a ValueTable, the label of the raw value is exported as the Label.
 func DefaultSignalExportFunc(f Field) []Signal {
-	return []Signal{{
+	return appendDefaultSignals(make([]Signal, 0, 1), f)
+}
+
+// FlagsSignalExportFunc is the export function for a flags field. It exports one signal for each flag, with a value of 0 or 1.
+func FlagsSignalExportFunc(f Field) []Signal {
+	return appendFlagSignals(make([]Signal, 0, len(f.Flags)), f)
+}
+
+// appendDefaultSignals appends the signal exported by DefaultSignalExportFunc to signals.
+func appendDefaultSignals(signals []Signal, f Field) []Signal {
+	return append(signals, Signal{
 		Name:     f.Name,
-		Value:    float64(f.Value),
+		Value:    f.PhysicalValue(),
 		RawValue: f.Value,
-	}}
+		Label:    f.Label(),
+	})
+}
+
+// appendFlagSignals appends the signals exported by FlagsSignalExportFunc to signals.
+func appendFlagSignals(signals []Signal, f Field) []Signal {
+	for i, name := range f.Flags {
+		bit := f.CheckFlag(i)
+		signals = append(signals, Signal{
+			Name:     name,
+			Value:    float64(bit),
+			RawValue: bit,
+		})
+	}
+	return signals
 }
@@ -1,6 +1,10 @@
 package mapache
 
 import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
 	"testing"
 )
 
@@ -133,6 +137,15 @@ func TestMessage(t *testing.T) {
 			}
 		}
 	})
+	t.Run("Test fill from bytes does not allocate", func(t *testing.T) {
+		data := []byte{0x12, 0x42, 0xFF, 0x00, 0x31, 0x82, 0x58, 0x72}
+		allocs := testing.AllocsPerRun(100, func() {
+			ecuStatusMessage.FillFromBytes(data)
+		})
+		if allocs != 0 {
+			t.Errorf("Expected 0 allocations, got %v", allocs)
+		}
+	})
 }
 
 func TestNewField(t *testing.T) {
@@ -446,3 +459,593 @@ func TestCheckBit(t *testing.T) {
 		}
 	}
 }
+
+func TestBitFieldMessage(t *testing.T) {
+	ecuMapsMessage := Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil),
+		NewBitField("ecu_power_level", 15, 4, Unsigned, BigEndian, nil),
+		NewBitField("ecu_torque_map", 11, 4, Unsigned, BigEndian, nil),
+		NewBitField("ecu_cell_temp_delta", 16, 12, Signed, LittleEndian, nil),
+	}
+	t.Run("Test size", func(t *testing.T) {
+		if ecuMapsMessage.Size() != 4 {
+			t.Errorf("Expected Size 4, got %d", ecuMapsMessage.Size())
+		}
+	})
+	t.Run("Test decode", func(t *testing.T) {
+		err := ecuMapsMessage.FillFromBytes([]byte{0x12, 0x31, 0xFE, 0x0F})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []int{18, 3, 1, -2}
+		for i, field := range ecuMapsMessage {
+			if field.Value != expected[i] {
+				t.Errorf("Expected %s %d, got %d", field.Name, expected[i], field.Value)
+			}
+		}
+	})
+	t.Run("Test encode", func(t *testing.T) {
+		err := ecuMapsMessage.FillFromInts([]int{18, 3, 1, -2})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x12, 0x31, 0xFE, 0x0F}
+		data, err := ecuMapsMessage.Bytes()
+		if err != nil || !reflect.DeepEqual(data, expected) {
+			t.Errorf("Expected %v, got %v", expected, data)
+		}
+	})
+	t.Run("Test value too large", func(t *testing.T) {
+		err := ecuMapsMessage.FillFromInts([]int{18, 16, 1, 0})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test signed value too large", func(t *testing.T) {
+		err := ecuMapsMessage.FillFromInts([]int{18, 3, 1, 2048})
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestMessageValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		message Message
+		valid   bool
+	}{
+		{"byte fields", Message{NewField("a", 1, Unsigned, BigEndian, nil), NewField("b", 2, Signed, LittleEndian, nil)}, true},
+		{"adjacent bit fields", Message{
+			NewBitField("a", 0, 4, Unsigned, LittleEndian, nil),
+			NewBitField("b", 7, 4, Unsigned, BigEndian, nil),
+			NewBitField("c", 8, 8, Unsigned, LittleEndian, nil),
+		}, true},
+		{"multiplexed fields", Message{
+			NewField("mux", 1, Unsigned, BigEndian, nil).AsMultiplexer(),
+			NewField("a", 2, Unsigned, BigEndian, nil).WithMuxValue(0),
+			NewField("b", 2, Unsigned, BigEndian, nil).WithMuxValue(1),
+		}, true},
+		{"byte field after bit field", Message{
+			NewBitField("a", 0, 4, Unsigned, LittleEndian, nil),
+			NewField("b", 1, Unsigned, BigEndian, nil),
+		}, false},
+		{"overlapping bit fields", Message{
+			NewBitField("a", 0, 8, Unsigned, LittleEndian, nil),
+			NewBitField("b", 7, 4, Unsigned, BigEndian, nil),
+		}, false},
+		{"multiplexed and plain field", Message{
+			NewBitField("a", 8, 16, Unsigned, LittleEndian, nil).WithMuxValue(1),
+			NewField("b", 2, Unsigned, BigEndian, nil),
+		}, false},
+		{"empty byte field", Message{NewField("a", 0, Unsigned, BigEndian, nil)}, false},
+		{"negative start bit", Message{NewBitField("a", -1, 4, Unsigned, LittleEndian, nil)}, false},
+		{"bit field longer than 64 bits", Message{NewBitField("a", 0, 65, Unsigned, LittleEndian, nil)}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.message.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("Expected nil, got %v", err)
+			} else if !tc.valid && err == nil {
+				t.Errorf("Expected error, got nil")
+			}
+			if _, err := NewMessage(tc.message...); tc.valid != (err == nil) {
+				t.Errorf("Expected NewMessage to fail with Validate, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFloatMessage(t *testing.T) {
+	imuMessage := Message{
+		NewField("imu_accel_x", 4, Float, LittleEndian, nil),
+		NewField("imu_accel_y", 2, Float, BigEndian, nil),
+		NewField("gps_latitude", 8, Float, BigEndian, nil),
+	}
+	data := []byte{0x00, 0x00, 0xC0, 0x3F, 0xC0, 0x00}
+	latitude, _ := BigEndianFloatToBinary(34.4133, 8)
+	data = append(data, latitude...)
+	t.Run("Test decode", func(t *testing.T) {
+		err := imuMessage.FillFromBytes(data)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signals := imuMessage.ExportSignals()
+		expected := []float64{1.5, -2, 34.4133}
+		for i, signal := range signals {
+			if signal.Value != expected[i] {
+				t.Errorf("Expected %s %f, got %f", signal.Name, expected[i], signal.Value)
+			}
+		}
+		if signals[0].RawValue != 0x3FC00000 {
+			t.Errorf("Expected RawValue 0x3FC00000, got 0x%X", signals[0].RawValue)
+		}
+	})
+	t.Run("Test round trip ints", func(t *testing.T) {
+		ints := []int{}
+		for _, field := range imuMessage {
+			ints = append(ints, field.Value)
+		}
+		err := imuMessage.FillFromInts(ints)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if got, err := imuMessage.Bytes(); err != nil || !reflect.DeepEqual(got, data) {
+			t.Errorf("Expected %v, got %v (%v)", data, got, err)
+		}
+	})
+	t.Run("Test fill from floats", func(t *testing.T) {
+		err := imuMessage.FillFromFloats([]float64{1.5, -2, 34.4133})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if got, err := imuMessage.Bytes(); err != nil || !reflect.DeepEqual(got, data) {
+			t.Errorf("Expected %v, got %v (%v)", data, got, err)
+		}
+	})
+	t.Run("Test invalid float size", func(t *testing.T) {
+		_, err := NewField("bad", 3, Float, BigEndian, nil).EncodeFloat(1.5)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test float bit field", func(t *testing.T) {
+		message := Message{
+			NewBitField("mux", 0, 4, Unsigned, LittleEndian, nil),
+			NewBitField("half", 4, 16, Float, LittleEndian, nil),
+		}
+		err := message.FillFromFloats([]float64{3, 0.5})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x03, 0x80, 0x03}
+		if got, err := message.Bytes(); err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, got, err)
+		}
+		err = message.FillFromBytes(expected)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if message[1].FloatValue() != 0.5 {
+			t.Errorf("Expected 0.5, got %f", message[1].FloatValue())
+		}
+	})
+}
+
+func TestScaledMessage(t *testing.T) {
+	ecuMessage := Message{
+		NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC").WithRange(0, 63.75),
+		NewField("ecu_acu_state_of_charge", 1, Unsigned, BigEndian, nil).WithScale(20.0/51, 0, "%").WithRange(0, 100),
+		NewField("ecu_motor_temp", 2, Signed, LittleEndian, nil).WithScale(0.1, -40, "degC"),
+		NewField("ecu_current", 4, Float, LittleEndian, nil).WithScale(2, 1, "A"),
+	}
+	t.Run("Test export", func(t *testing.T) {
+		data := []byte{0x82, 0xFF, 0xE8, 0x03, 0x00, 0x00, 0xC0, 0x3F}
+		err := ecuMessage.FillFromBytes(data)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signals := ecuMessage.ExportSignals()
+		expected := []float64{32.5, 100, 60, 4}
+		for i, signal := range signals {
+			if math.Abs(signal.Value-expected[i]) > 1e-9 {
+				t.Errorf("Expected %s %f, got %f", signal.Name, expected[i], signal.Value)
+			}
+		}
+		if signals[2].RawValue != 1000 {
+			t.Errorf("Expected RawValue 1000, got %d", signals[2].RawValue)
+		}
+	})
+	t.Run("Test fill from values", func(t *testing.T) {
+		err := ecuMessage.FillFromValues([]float64{32.5, 100, 60, 4})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x82, 0xFF, 0xE8, 0x03, 0x00, 0x00, 0xC0, 0x3F}
+		if got, err := ecuMessage.Bytes(); err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, got, err)
+		}
+	})
+	t.Run("Test value out of range", func(t *testing.T) {
+		_, err := ecuMessage[0].EncodeValue(64)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test unscaled field", func(t *testing.T) {
+		field, err := NewField("raw", 1, Unsigned, BigEndian, nil).EncodeValue(12)
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if field.Value != 12 || field.PhysicalValue() != 12 {
+			t.Errorf("Expected 12, got %d", field.Value)
+		}
+	})
+}
+
+func TestMultiplexedMessage(t *testing.T) {
+	bmsMessage := Message{
+		NewField("bms_cell_group", 1, Unsigned, BigEndian, nil).AsMultiplexer(),
+		NewField("bms_cell_0_voltage", 2, Unsigned, BigEndian, nil).WithMuxValue(0),
+		NewField("bms_cell_1_voltage", 2, Unsigned, BigEndian, nil).WithMuxValue(0),
+		NewField("bms_cell_2_voltage", 2, Unsigned, BigEndian, nil).WithMuxValue(1),
+		NewField("bms_cell_3_voltage", 2, Unsigned, BigEndian, nil).WithMuxValue(1),
+		NewField("bms_cell_4_voltage", 2, Unsigned, BigEndian, nil).WithMuxValue(2),
+		NewField("bms_checksum", 1, Unsigned, BigEndian, nil),
+	}
+	t.Run("Test size", func(t *testing.T) {
+		if bmsMessage.Size() != 6 {
+			t.Errorf("Expected Size 6, got %d", bmsMessage.Size())
+		}
+	})
+	t.Run("Test decode", func(t *testing.T) {
+		err := bmsMessage.FillFromBytes([]byte{0x01, 0x0F, 0xA0, 0x0F, 0xA1, 0x42})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signals := bmsMessage.ExportSignals()
+		expected := []Signal{
+			{Name: "bms_cell_group", Value: 1, RawValue: 1},
+			{Name: "bms_cell_2_voltage", Value: 4000, RawValue: 4000},
+			{Name: "bms_cell_3_voltage", Value: 4001, RawValue: 4001},
+			{Name: "bms_checksum", Value: 0x42, RawValue: 0x42},
+		}
+		if !reflect.DeepEqual(signals, expected) {
+			t.Errorf("Expected %v, got %v", expected, signals)
+		}
+		if bmsMessage[1].Value != 0 || bmsMessage[1].Bytes != nil {
+			t.Errorf("Expected inactive field to be cleared, got %+v", bmsMessage[1])
+		}
+	})
+	t.Run("Test decode shorter group", func(t *testing.T) {
+		err := bmsMessage.FillFromBytes([]byte{0x02, 0x0F, 0xA2, 0x00, 0x00, 0x42})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signals := bmsMessage.ExportSignals()
+		if len(signals) != 3 || signals[1].Name != "bms_cell_4_voltage" || signals[1].Value != 4002 {
+			t.Errorf("Unexpected signals %v", signals)
+		}
+	})
+	t.Run("Test unknown mux value", func(t *testing.T) {
+		err := bmsMessage.FillFromBytes([]byte{0x07, 0x0F, 0xA2, 0x00, 0x00, 0x42})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if len(bmsMessage.ExportSignals()) != 2 {
+			t.Errorf("Expected 2 signals, got %d", len(bmsMessage.ExportSignals()))
+		}
+	})
+	t.Run("Test encode", func(t *testing.T) {
+		err := bmsMessage.FillFromInts([]int{0, 4000, 4001, 0xFFFF, 0xFFFF, 0xFFFF, 0x42})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x00, 0x0F, 0xA0, 0x0F, 0xA1, 0x42}
+		if got, err := bmsMessage.Bytes(); err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, got, err)
+		}
+	})
+	t.Run("Test bit fields", func(t *testing.T) {
+		message := Message{
+			NewBitField("mux", 0, 4, Unsigned, LittleEndian, nil).AsMultiplexer(),
+			NewBitField("a", 4, 12, Unsigned, LittleEndian, nil).WithMuxValue(0),
+			NewBitField("b", 4, 4, Unsigned, LittleEndian, nil).WithMuxValue(1),
+		}
+		err := message.FillFromBytes([]byte{0x31, 0x12})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signals := message.ExportSignals()
+		if len(signals) != 2 || signals[1].Name != "b" || signals[1].Value != 3 {
+			t.Errorf("Unexpected signals %v", signals)
+		}
+	})
+}
+
+func TestValueTable(t *testing.T) {
+	states := ValueTable{0: "GLV_OFF", 1: "GLV_ON", 2: "PRECHARGE", 3: "TS_ACTIVE"}
+	ecuMessage := Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil).WithValueTable(states),
+		NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC"),
+	}
+	t.Run("Test export label", func(t *testing.T) {
+		err := ecuMessage.FillFromBytes([]byte{0x03, 0x82})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signals := ecuMessage.ExportSignals()
+		if signals[0].Label != "TS_ACTIVE" || signals[0].RawValue != 3 {
+			t.Errorf("Expected TS_ACTIVE, got %+v", signals[0])
+		}
+		if signals[1].Label != "" {
+			t.Errorf("Expected empty label, got %s", signals[1].Label)
+		}
+	})
+	t.Run("Test unknown value", func(t *testing.T) {
+		err := ecuMessage.FillFromBytes([]byte{0x09, 0x82})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if label := ecuMessage[0].Label(); label != "" {
+			t.Errorf("Expected empty label, got %s", label)
+		}
+	})
+	t.Run("Test encode label", func(t *testing.T) {
+		field, err := ecuMessage[0].EncodeLabel("PRECHARGE")
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if field.Value != 2 || !reflect.DeepEqual(field.Bytes, []byte{0x02}) {
+			t.Errorf("Expected 2, got %d %v", field.Value, field.Bytes)
+		}
+	})
+	t.Run("Test encode unknown label", func(t *testing.T) {
+		_, err := ecuMessage[0].EncodeLabel("LAUNCH")
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test duplicate labels", func(t *testing.T) {
+		value, ok := ValueTable{5: "FAULT", 4: "FAULT"}.Value("FAULT")
+		if !ok || value != 4 {
+			t.Errorf("Expected 4, got %d", value)
+		}
+	})
+}
+
+func TestFlagsField(t *testing.T) {
+	statusFlags := []string{
+		"ecu_status_acu",
+		"ecu_status_inv_one",
+		"ecu_status_inv_two",
+		"ecu_status_inv_three",
+		"ecu_status_inv_four",
+		"ecu_status_fan_one",
+		"ecu_status_fan_two",
+		"ecu_status_fan_three",
+		"ecu_status_fan_four",
+		"ecu_status_fan_five",
+		"ecu_status_fan_six",
+		"ecu_status_fan_seven",
+		"ecu_status_fan_eight",
+		"ecu_status_dash",
+		"ecu_status_steering",
+	}
+	t.Run("Test matches CheckBit", func(t *testing.T) {
+		message := Message{NewFlagsField("ecu_status_flags", 3, MSBFirst, statusFlags)}
+		err := message.FillFromBytes([]byte{0x42, 0xFF, 0x00})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		signals := message.ExportSignals()
+		if len(signals) != len(statusFlags) {
+			t.Fatalf("Expected %d signals, got %d", len(statusFlags), len(signals))
+		}
+		for i, signal := range signals {
+			if signal.Name != statusFlags[i] || signal.RawValue != message[0].CheckBit(i) {
+				t.Errorf("Expected %s %d, got %s %d", statusFlags[i], message[0].CheckBit(i), signal.Name, signal.RawValue)
+			}
+		}
+	})
+	t.Run("Test LSB first", func(t *testing.T) {
+		field := NewFlagsField("flags", 2, LSBFirst, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"})
+		field.Bytes = []byte{0x05, 0x01}
+		signals := field.ExportSignals()
+		expected := []int{1, 0, 1, 0, 0, 0, 0, 0, 1}
+		for i, signal := range signals {
+			if signal.RawValue != expected[i] {
+				t.Errorf("Expected %s %d, got %d", signal.Name, expected[i], signal.RawValue)
+			}
+		}
+	})
+	t.Run("Test encode MSB first", func(t *testing.T) {
+		field := NewFlagsField("ecu_status_flags", 3, MSBFirst, statusFlags)
+		field, err := field.EncodeFlags("ecu_status_inv_one", "ecu_status_fan_two", "ecu_status_fan_four")
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x42, 0x80, 0x00}
+		if !reflect.DeepEqual(field.Bytes, expected) || field.Value != 0x428000 {
+			t.Errorf("Expected %v, got %v (0x%X)", expected, field.Bytes, field.Value)
+		}
+	})
+	t.Run("Test encode LSB first", func(t *testing.T) {
+		field := NewFlagsField("flags", 2, LSBFirst, []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"})
+		field, err := field.EncodeFlags("a", "c", "i")
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x05, 0x01}
+		if !reflect.DeepEqual(field.Bytes, expected) {
+			t.Errorf("Expected %v, got %v", expected, field.Bytes)
+		}
+	})
+	t.Run("Test encode wider than 64 bits", func(t *testing.T) {
+		flags := make([]string, 72)
+		for i := range flags {
+			flags[i] = fmt.Sprintf("f%d", i)
+		}
+		for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+			field, err := NewFlagsField("flags", 9, order, flags).EncodeFlags("f0", "f71")
+			if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			expected := []byte{0x80, 0, 0, 0, 0, 0, 0, 0, 0x01}
+			if order == LSBFirst {
+				expected = []byte{0x01, 0, 0, 0, 0, 0, 0, 0, 0x80}
+			}
+			if !reflect.DeepEqual(field.Bytes, expected) {
+				t.Errorf("Expected % X, got % X", expected, field.Bytes)
+			}
+			for i := range flags {
+				expected := 0
+				if i == 0 || i == 71 {
+					expected = 1
+				}
+				if field.CheckFlag(i) != expected {
+					t.Errorf("Expected flag %d to be %d, got %d", i, expected, field.CheckFlag(i))
+				}
+			}
+		}
+	})
+	t.Run("Test encode unknown flag", func(t *testing.T) {
+		_, err := NewFlagsField("flags", 1, LSBFirst, []string{"a"}).EncodeFlags("b")
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test bit field flags", func(t *testing.T) {
+		field := NewBitField("flags", 4, 3, Unsigned, LittleEndian, nil)
+		field.Flags = []string{"a", "b", "c"}
+		field.FlagOrder = MSBFirst
+		field, err := field.EncodeFlags("a")
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		expected := []byte{0x40}
+		if !reflect.DeepEqual(field.Bytes, expected) {
+			t.Errorf("Expected %v, got %v", expected, field.Bytes)
+		}
+		field = field.Decode()
+		if field.CheckFlag(0) != 1 || field.CheckFlag(1) != 0 || field.CheckFlag(2) != 0 {
+			t.Errorf("Unexpected flags %d%d%d", field.CheckFlag(0), field.CheckFlag(1), field.CheckFlag(2))
+		}
+	})
+	t.Run("Test bit field with more flags than bits", func(t *testing.T) {
+		for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+			field := NewBitField("flags", 4, 3, Unsigned, LittleEndian, nil)
+			field.Flags = []string{"a", "b", "c", "d"}
+			field.FlagOrder = order
+			if _, err := field.EncodeFlags("d"); err == nil {
+				t.Errorf("Expected error for order %d, got nil", order)
+			}
+			if _, err := field.EncodeFlags("c"); err != nil {
+				t.Errorf("Expected nil for order %d, got %v", order, err)
+			}
+		}
+	})
+}
+
+func TestUint64Field(t *testing.T) {
+	t.Run("Test decode above int64", func(t *testing.T) {
+		message := Message{NewField("odometer", 8, Unsigned, BigEndian, nil)}
+		err := message.FillFromBytes([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE})
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		if message[0].Uint64Value() != math.MaxUint64-1 {
+			t.Errorf("Expected %d, got %d", uint64(math.MaxUint64-1), message[0].Uint64Value())
+		}
+		signals := message.ExportSignals()
+		if signals[0].Value != float64(uint64(math.MaxUint64-1)) {
+			t.Errorf("Expected %f, got %f", float64(uint64(math.MaxUint64-1)), signals[0].Value)
+		}
+	})
+	t.Run("Test round trip uint64", func(t *testing.T) {
+		message := Message{NewField("serial", 8, Unsigned, LittleEndian, nil)}
+		data := []byte{0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80}
+		message.FillFromBytes(data)
+		if err := message.FillFromInts([]int{message[0].Value}); err == nil {
+			t.Errorf("Expected error for value above int64, got nil")
+		}
+		field, err := message[0].EncodeUint64(message[0].Uint64Value())
+		if err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+		message[0] = field
+		if got, err := message.Bytes(); err != nil || !reflect.DeepEqual(got, data) {
+			t.Errorf("Expected %v, got %v (%v)", data, got, err)
+		}
+	})
+	t.Run("Test encode uint64", func(t *testing.T) {
+		field, err := NewField("serial", 8, Unsigned, BigEndian, nil).EncodeUint64(1<<63 + 5)
+		expected := []byte{0x80, 0, 0, 0, 0, 0, 0, 5}
+		if err != nil || !reflect.DeepEqual(field.Bytes, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, field.Bytes, err)
+		}
+		field, err = NewBitField("serial", 0, 64, Unsigned, LittleEndian, nil).EncodeUint64(1<<63 + 5)
+		expected = []byte{5, 0, 0, 0, 0, 0, 0, 0x80}
+		if err != nil || !reflect.DeepEqual(field.Bytes, expected) {
+			t.Errorf("Expected %v, got %v (%v)", expected, field.Bytes, err)
+		}
+		if field.Decode().Uint64Value() != 1<<63+5 {
+			t.Errorf("Expected %d, got %d", uint64(1<<63+5), field.Decode().Uint64Value())
+		}
+	})
+	t.Run("Test encode negative value", func(t *testing.T) {
+		for _, endian := range []Endian{BigEndian, LittleEndian} {
+			field := NewField("serial", 8, Unsigned, endian, nil)
+			field.Value = -1
+			if _, err := field.Encode(); err == nil {
+				t.Errorf("Expected error for endian %d, got nil", endian)
+			}
+		}
+	})
+	t.Run("Test encode uint64 too large", func(t *testing.T) {
+		_, err := NewField("serial", 4, Unsigned, BigEndian, nil).EncodeUint64(1 << 32)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		_, err = NewBitField("serial", 0, 12, Unsigned, BigEndian, nil).EncodeUint64(1 << 12)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		_, err = NewField("serial", 8, Signed, BigEndian, nil).EncodeUint64(1)
+		if err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test big int value", func(t *testing.T) {
+		field := NewField("vin", 10, Unsigned, LittleEndian, nil)
+		field.Bytes = []byte{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+		expected, _ := new(big.Int).SetString("0102030405060708090A", 16)
+		if field.BigIntValue().Cmp(expected) != 0 {
+			t.Errorf("Expected %v, got %v", expected, field.BigIntValue())
+		}
+		f, _ := new(big.Float).SetInt(expected).Float64()
+		if field.FloatValue() != f {
+			t.Errorf("Expected %f, got %f", f, field.FloatValue())
+		}
+	})
+	t.Run("Test encode big int", func(t *testing.T) {
+		expected, _ := new(big.Int).SetString("0102030405060708090A", 16)
+		field, err := NewField("vin", 10, Unsigned, LittleEndian, nil).EncodeBigInt(expected)
+		if err != nil || !reflect.DeepEqual(field.Bytes, []byte{10, 9, 8, 7, 6, 5, 4, 3, 2, 1}) {
+			t.Errorf("Expected little endian bytes, got %v (%v)", field.Bytes, err)
+		}
+		field, err = NewBitField("serial", 4, 12, Unsigned, LittleEndian, nil).EncodeBigInt(big.NewInt(0xABC))
+		if err != nil || field.Decode().Value != 0xABC {
+			t.Errorf("Expected %d, got %d (%v)", 0xABC, field.Decode().Value, err)
+		}
+		for _, v := range []*big.Int{nil, big.NewInt(-1), new(big.Int).Lsh(big.NewInt(1), 80)} {
+			if _, err := NewField("vin", 10, Unsigned, LittleEndian, nil).EncodeBigInt(v); err == nil {
+				t.Errorf("Expected error for %v, got nil", v)
+			}
+		}
+		if _, err := NewField("vin", 10, Signed, LittleEndian, nil).EncodeBigInt(expected); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
@@ -0,0 +1,322 @@
+package mapache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ParquetRowGroupSize is the maximum number of rows of each Parquet row group.
+const ParquetRowGroupSize = 65536
+
+// Parquet metadata constants, see parquet.thrift in the Parquet format specification.
+const (
+	parquetInt64            = 2
+	parquetDouble           = 5
+	parquetByteArray        = 6
+	parquetRequired         = 0
+	parquetOptional         = 1
+	parquetUTF8             = 0
+	parquetTimestampMicros  = 10
+	parquetPlain            = 0
+	parquetRLE              = 3
+	parquetUncompressed     = 0
+	parquetDataPage         = 0
+	parquetLogicalString    = 1
+	parquetLogicalTimestamp = 8
+	parquetMicros           = 2
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetCreatedBy is the application that created the file, stored in its metadata.
+const parquetCreatedBy = "mapache-go"
+
+// Thrift compact protocol types.
+const (
+	thriftStop   = 0
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// WriteParquetFile writes the table to a Parquet file at the given path.
+func WriteParquetFile(path string, table SignalTable) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteParquet(file, table); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteParquet writes the table as an uncompressed Parquet file, which can be read with pandas, pyarrow, or DuckDB.
+// Rows are split into row groups of ParquetRowGroupSize, each holding a single plain encoded page per column.
+// Columns with null values are optional, and every other column is required.
+// It returns an error if the table has rows but no columns.
+func WriteParquet(w io.Writer, table SignalTable) error {
+	p := &offsetWriter{w: w}
+	p.write(parquetMagic)
+	rowGroups := [][]parquetColumnChunk{}
+	for start := 0; start < table.Rows; start += ParquetRowGroupSize {
+		end := min(start+ParquetRowGroupSize, table.Rows)
+		chunks := make([]parquetColumnChunk, len(table.Columns))
+		for i, column := range table.Columns {
+			page := parquetPage(column, start, end)
+			header := &thriftWriter{}
+			header.begin()
+			header.i32(1, parquetDataPage)
+			header.i32(2, int32(len(page)))
+			header.i32(3, int32(len(page)))
+			header.structField(5)
+			header.i32(1, int32(end-start))
+			header.i32(2, parquetPlain)
+			header.i32(3, parquetRLE)
+			header.i32(4, parquetRLE)
+			header.end()
+			header.end()
+			chunks[i] = parquetColumnChunk{offset: p.offset, size: int64(len(header.buf) + len(page)), rows: end - start}
+			p.write(header.buf)
+			p.write(page)
+		}
+		rowGroups = append(rowGroups, chunks)
+	}
+	metadata, err := parquetFileMetaData(table, rowGroups)
+	if err != nil {
+		return err
+	}
+	p.write(metadata)
+	p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(metadata))))
+	p.write(parquetMagic)
+	return p.err
+}
+
+// parquetColumnChunk is the position of a column chunk written to a Parquet file.
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+	rows   int
+}
+
+// parquetPage returns the data of a page holding the rows of a column from start to end: its definition levels
+// if the column is optional, followed by its plain encoded non-null values.
+func parquetPage(column SignalColumn, start int, end int) []byte {
+	page := []byte{}
+	if column.Valid != nil {
+		// definition levels are encoded as RLE runs with a bit width of 1, prefixed with their length
+		levels := []byte{}
+		for i := start; i < end; {
+			run := i
+			for run < end && column.Valid[run] == column.Valid[i] {
+				run++
+			}
+			levels = binary.AppendUvarint(levels, uint64(run-i)<<1)
+			if column.Valid[i] {
+				levels = append(levels, 1)
+			} else {
+				levels = append(levels, 0)
+			}
+			i = run
+		}
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels)))
+		page = append(page, levels...)
+	}
+	for i := start; i < end; i++ {
+		if !column.IsValid(i) {
+			continue
+		}
+		switch column.Type {
+		case TimestampColumn, IntColumn:
+			page = binary.LittleEndian.AppendUint64(page, uint64(column.Ints[i]))
+		case FloatColumn:
+			page = binary.LittleEndian.AppendUint64(page, math.Float64bits(column.Floats[i]))
+		case StringColumn:
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(column.Strings[i])))
+			page = append(page, column.Strings[i]...)
+		}
+	}
+	return page
+}
+
+// parquetPhysicalType returns the Parquet physical type of a column.
+func parquetPhysicalType(column SignalColumn) int32 {
+	switch column.Type {
+	case FloatColumn:
+		return parquetDouble
+	case StringColumn:
+		return parquetByteArray
+	}
+	return parquetInt64
+}
+
+// parquetFileMetaData returns the FileMetaData of the file footer.
+// It returns an error if a row group has no column chunks, since Parquet stores the rows of a table in its columns.
+func parquetFileMetaData(table SignalTable, rowGroups [][]parquetColumnChunk) ([]byte, error) {
+	for _, chunks := range rowGroups {
+		if len(chunks) == 0 {
+			return nil, fmt.Errorf("cannot write %d rows without any columns", table.Rows)
+		}
+	}
+	t := &thriftWriter{}
+	t.begin()
+	t.i32(1, 1)
+	t.listField(2, thriftStruct, len(table.Columns)+1)
+	t.begin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(table.Columns)))
+	t.end()
+	for _, column := range table.Columns {
+		t.begin()
+		t.i32(1, parquetPhysicalType(column))
+		if column.Valid != nil {
+			t.i32(3, parquetOptional)
+		} else {
+			t.i32(3, parquetRequired)
+		}
+		t.binary(4, column.Name)
+		switch column.Type {
+		case TimestampColumn:
+			t.i32(6, parquetTimestampMicros)
+			t.structField(10)
+			t.structField(parquetLogicalTimestamp)
+			t.bool(1, true)
+			t.structField(2)
+			t.structField(parquetMicros)
+			t.end()
+			t.end()
+			t.end()
+			t.end()
+		case StringColumn:
+			t.i32(6, parquetUTF8)
+			t.structField(10)
+			t.structField(parquetLogicalString)
+			t.end()
+			t.end()
+		}
+		t.end()
+	}
+	t.i64(3, int64(table.Rows))
+	t.listField(4, thriftStruct, len(rowGroups))
+	for _, chunks := range rowGroups {
+		t.begin()
+		t.listField(1, thriftStruct, len(chunks))
+		size := int64(0)
+		for i, chunk := range chunks {
+			column := table.Columns[i]
+			size += chunk.size
+			t.begin()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, parquetPhysicalType(column))
+			t.listField(2, thriftI32, 2)
+			t.listI32(parquetPlain, parquetRLE)
+			t.listField(3, thriftBinary, 1)
+			t.listBinary(column.Name)
+			t.i32(4, parquetUncompressed)
+			t.i64(5, int64(chunk.rows))
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, size)
+		t.i64(3, int64(chunks[0].rows))
+		t.end()
+	}
+	t.binary(6, parquetCreatedBy)
+	t.end()
+	return t.buf, nil
+}
+
+// thriftWriter writes structs in the Thrift compact protocol.
+type thriftWriter struct {
+	buf []byte
+	// last holds the ID of the last field written in each nested struct
+	last []int16
+}
+
+// begin starts a struct, either at the top level or as an element of a list.
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+// end ends the current struct.
+func (t *thriftWriter) end() {
+	t.buf = append(t.buf, thriftStop)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, fieldType byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|fieldType)
+	} else {
+		t.buf = append(t.buf, fieldType)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(v)))
+	t.buf = append(t.buf, v...)
+}
+
+// structField starts a struct field, which has to be ended with end.
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// listField starts a list field, whose elements have to be written next.
+func (t *thriftWriter) listField(id int16, elementType byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf = append(t.buf, byte(size)<<4|elementType)
+	} else {
+		t.buf = append(t.buf, 0xF0|elementType)
+		t.buf = binary.AppendUvarint(t.buf, uint64(size))
+	}
+}
+
+func (t *thriftWriter) listI32(values ...int32) {
+	for _, v := range values {
+		t.buf = binary.AppendVarint(t.buf, int64(v))
+	}
+}
+
+func (t *thriftWriter) listBinary(values ...string) {
+	for _, v := range values {
+		t.buf = binary.AppendUvarint(t.buf, uint64(len(v)))
+		t.buf = append(t.buf, v...)
+	}
+}
@@ -0,0 +1,118 @@
+package mapache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestThriftWriter(t *testing.T) {
+	w := &thriftWriter{}
+	w.begin()
+	w.i32(1, 5)
+	w.i64(20, -1)
+	w.bool(21, true)
+	w.structField(22)
+	w.binary(1, "ab")
+	w.end()
+	w.listField(23, thriftI32, 2)
+	w.listI32(0, 3)
+	w.end()
+	expected := []byte{
+		0x15, 0x0A, // field 1, i32 5
+		0x06, 0x28, 0x01, // field 20 in long form, i64 -1
+		0x11,                             // field 21, true
+		0x1C, 0x18, 0x02, 'a', 'b', 0x00, // field 22, struct with field 1 "ab"
+		0x19, 0x25, 0x00, 0x06, // field 23, list of 2 i32
+		0x00,
+	}
+	if !bytes.Equal(w.buf, expected) {
+		t.Errorf("Expected % X, got % X", expected, w.buf)
+	}
+}
+
+func TestParquetPage(t *testing.T) {
+	column := SignalColumn{Name: "ecu_speed", Type: FloatColumn, Floats: []float64{1, 0, 0, 2.5}, Valid: []bool{true, false, false, true}}
+	expected := []byte{6, 0, 0, 0, 0x02, 1, 0x04, 0, 0x02, 1}
+	expected = binary.LittleEndian.AppendUint64(expected, math.Float64bits(1))
+	expected = binary.LittleEndian.AppendUint64(expected, math.Float64bits(2.5))
+	if page := parquetPage(column, 0, 4); !bytes.Equal(page, expected) {
+		t.Errorf("Expected % X, got % X", expected, page)
+	}
+	column = SignalColumn{Name: "name", Type: StringColumn, Strings: []string{"a", "bc", "d"}}
+	expected = []byte{2, 0, 0, 0, 'b', 'c', 1, 0, 0, 0, 'd'}
+	if page := parquetPage(column, 1, 3); !bytes.Equal(page, expected) {
+		t.Errorf("Expected % X, got % X", expected, page)
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	table := NewSignalTable(testTableSignals(), WideLayout)
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, table); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatalf("Expected PAR1 magic, got %q and %q", data[:4], data[len(data)-4:])
+	}
+	metadataLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metadata := data[len(data)-8-metadataLength : len(data)-8]
+	if metadata[len(metadata)-1] != thriftStop || !bytes.Contains(metadata, []byte(parquetCreatedBy)) {
+		t.Errorf("Unexpected metadata % X", metadata)
+	}
+	for _, column := range table.Columns {
+		if !bytes.Contains(metadata, []byte(column.Name)) {
+			t.Errorf("Expected column %s in metadata", column.Name)
+		}
+	}
+	// the first page is the timestamp column, with a page header followed by its plain encoded values
+	page := parquetPage(table.Columns[0], 0, table.Rows)
+	if index := bytes.Index(data, page); index < 4 || index > 32 {
+		t.Errorf("Expected timestamp page after its header, got %d", index)
+	}
+
+	t.Run("Test golden file", func(t *testing.T) {
+		// testdata/signals.parquet was checked against the Parquet format specification with an independent decoder
+		expected, err := os.ReadFile(filepath.Join("testdata", "signals.parquet"))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !bytes.Equal(data, expected) {
+			t.Errorf("Expected output to match testdata/signals.parquet")
+		}
+	})
+	t.Run("Test row groups", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteParquet(&buf, NewSignalTable(make([]Signal, ParquetRowGroupSize+10), LongLayout)); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		data := buf.Bytes()
+		metadata := data[len(data)-8-int(binary.LittleEndian.Uint32(data[len(data)-8:])) : len(data)-8]
+		// num_rows, followed by the header of the list of 2 row groups
+		numRows := binary.AppendVarint([]byte{0x16}, int64(ParquetRowGroupSize+10))
+		if !bytes.Contains(metadata, append(numRows, 0x19, 0x2C)) {
+			t.Errorf("Expected 2 row groups in metadata % X", metadata)
+		}
+	})
+	t.Run("Test empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := WriteParquet(&buf, NewSignalTable(nil, LongLayout)); err != nil || !bytes.HasSuffix(buf.Bytes(), parquetMagic) {
+			t.Errorf("Expected an empty file, got %v", err)
+		}
+	})
+	t.Run("Test rows without columns", func(t *testing.T) {
+		if err := WriteParquet(&bytes.Buffer{}, SignalTable{Rows: 2}); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "signals.parquet")
+		if err := WriteParquetFile(path, table); err != nil {
+			t.Errorf("Expected nil, got %v", err)
+		}
+	})
+}
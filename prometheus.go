@@ -0,0 +1,242 @@
+package mapache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusContentType is the content type of the Prometheus text exposition format.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// prometheusVehicleLabel is the label holding the VehicleID of each sample.
+const prometheusVehicleLabel = "vehicle_id"
+
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// PrometheusExporter exposes the latest value of each signal of each vehicle in the Prometheus text format.
+// Each signal is exported as a gauge named after the signal, with a vehicle_id label.
+// It is safe for concurrent use, and can be served directly as an http.Handler.
+type PrometheusExporter struct {
+	// Namespace is prepended to every metric name, separated by an underscore, if it is not empty.
+	Namespace string
+	// Timestamps includes the Timestamp of each signal in its sample. By default samples have no timestamp,
+	// so that Prometheus records them at scrape time.
+	Timestamps bool
+
+	mu     sync.RWMutex
+	latest map[signalSeries]Signal
+}
+
+// NewPrometheusExporter returns a new PrometheusExporter without any signals.
+func NewPrometheusExporter() *PrometheusExporter {
+	return &PrometheusExporter{
+		latest: make(map[signalSeries]Signal),
+	}
+}
+
+// Update records the signals, keeping the latest value of each signal of each vehicle.
+// A signal older than the one already recorded is ignored.
+func (e *PrometheusExporter) Update(signals ...Signal) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, signal := range signals {
+		series := signalSeries{signal.VehicleID, signal.Name}
+		if latest, ok := e.latest[series]; !ok || signal.Timestamp >= latest.Timestamp {
+			e.latest[series] = signal
+		}
+	}
+}
+
+// WriteTo writes the latest signals in the Prometheus text format, sorted by metric name and vehicle.
+// It returns an error without writing anything if two signal names have the same metric name, such as
+// acu.temp and acu_temp, since their samples could not be told apart.
+func (e *PrometheusExporter) WriteTo(w io.Writer) (int64, error) {
+	e.mu.RLock()
+	signals := make([]Signal, 0, len(e.latest))
+	for _, signal := range e.latest {
+		signals = append(signals, signal)
+	}
+	e.mu.RUnlock()
+
+	names := make(map[string]string, len(signals))
+	metrics := make(map[string]string, len(signals))
+	for _, signal := range signals {
+		name := PrometheusMetricName(e.Namespace, signal.Name)
+		if other, ok := metrics[name]; ok && other != signal.Name {
+			a, b := other, signal.Name
+			if b < a {
+				a, b = b, a
+			}
+			return 0, fmt.Errorf("signals %s and %s have the same metric name %s", a, b, name)
+		}
+		names[signal.Name] = name
+		metrics[name] = signal.Name
+	}
+	sort.Slice(signals, func(i, j int) bool {
+		if a, b := names[signals[i].Name], names[signals[j].Name]; a != b {
+			return a < b
+		}
+		return signals[i].VehicleID < signals[j].VehicleID
+	})
+	var buf bytes.Buffer
+	for i, signal := range signals {
+		name := names[signal.Name]
+		if i == 0 || name != names[signals[i-1].Name] {
+			fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+		}
+		fmt.Fprintf(&buf, "%s{%s=\"%s\"} %s", name, prometheusVehicleLabel, prometheusLabelEscaper.Replace(signal.VehicleID), formatPrometheusValue(signal.Value))
+		if e.Timestamps {
+			fmt.Fprintf(&buf, " %d", signal.Timestamp/1000)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP serves the latest signals in the Prometheus text format.
+// It responds with an internal server error if the signals cannot be written (see WriteTo).
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if _, err := e.WriteTo(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", PrometheusContentType)
+	buf.WriteTo(w)
+}
+
+// PrometheusMetricName returns a valid Prometheus metric name for a signal, replacing invalid characters with
+// underscores and prepending the namespace if it is not empty.
+func PrometheusMetricName(namespace string, name string) string {
+	if namespace != "" {
+		name = namespace + "_" + name
+	}
+	b := []byte(name)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || c >= '0' && c <= '9' && i > 0) {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+func formatPrometheusValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// ParsePrometheus parses samples in the Prometheus text format into signals, named after their metric.
+// The VehicleID is taken from the vehicle_id label, and the Timestamp from the millisecond timestamp of the
+// sample if it has one. Comments and other labels are ignored.
+func ParsePrometheus(r io.Reader) ([]Signal, error) {
+	signals := []Signal{}
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		signal, err := parsePrometheusLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		signals = append(signals, signal)
+	}
+	return signals, scanner.Err()
+}
+
+func parsePrometheusLine(line string) (Signal, error) {
+	signal := Signal{}
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return signal, fmt.Errorf("invalid sample %q", line)
+	}
+	signal.Name = line[:end]
+	rest := line[end:]
+	if rest[0] == '{' {
+		labels, n, err := parsePrometheusLabels(rest)
+		if err != nil {
+			return signal, err
+		}
+		signal.VehicleID = labels[prometheusVehicleLabel]
+		rest = rest[n:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return signal, fmt.Errorf("invalid sample %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return signal, fmt.Errorf("invalid value %q", fields[0])
+	}
+	signal.Value = value
+	if len(fields) == 2 {
+		ms, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return signal, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+		signal.Timestamp = int(ms * 1000)
+		signal.ProducedAt = time.UnixMilli(ms)
+	}
+	return signal, nil
+}
+
+// parsePrometheusLabels parses a label set starting with '{' and returns the labels and the length of the set.
+func parsePrometheusLabels(s string) (map[string]string, int, error) {
+	labels := map[string]string{}
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i < len(s) && s[i] == '}' {
+			return labels, i + 1, nil
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq < 0 {
+			return nil, 0, fmt.Errorf("invalid labels %q", s)
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		for i += eq + 1; i < len(s) && s[i] == ' '; i++ {
+		}
+		if i >= len(s) || s[i] != '"' {
+			return nil, 0, fmt.Errorf("invalid labels %q", s)
+		}
+		i++
+		var value strings.Builder
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, 0, fmt.Errorf("unterminated label value in %q", s)
+		}
+		labels[name] = value.String()
+		i++
+	}
+}
@@ -0,0 +1,142 @@
+package mapache
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExporter(t *testing.T) {
+	exporter := NewPrometheusExporter()
+	exporter.Update(
+		Signal{Timestamp: 2000000, VehicleID: "gr24", Name: "ecu_speed", Value: 12.5},
+		Signal{Timestamp: 1000000, VehicleID: "gr24", Name: "ecu_speed", Value: 10},
+		Signal{Timestamp: 1000000, VehicleID: "gr23", Name: "ecu_speed", Value: 5},
+		Signal{Timestamp: 3000000, VehicleID: `gr"24`, Name: "acu.temp", Value: math.Inf(1)},
+	)
+	expected := strings.Join([]string{
+		"# TYPE acu_temp gauge",
+		`acu_temp{vehicle_id="gr\"24"} +Inf`,
+		"# TYPE ecu_speed gauge",
+		`ecu_speed{vehicle_id="gr23"} 5`,
+		`ecu_speed{vehicle_id="gr24"} 12.5`,
+	}, "\n") + "\n"
+	var buf bytes.Buffer
+	if n, err := exporter.WriteTo(&buf); err != nil || buf.String() != expected || n != int64(len(expected)) {
+		t.Errorf("Expected\n%s\ngot\n%s (%v)", expected, buf.String(), err)
+	}
+
+	t.Run("Test round trip", func(t *testing.T) {
+		exporter.Namespace = "mapache"
+		exporter.Timestamps = true
+		defer func() {
+			exporter.Namespace = ""
+			exporter.Timestamps = false
+		}()
+		var buf bytes.Buffer
+		exporter.WriteTo(&buf)
+		signals, err := ParsePrometheus(&buf)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if len(signals) != 3 {
+			t.Fatalf("Expected 3 signals, got %d", len(signals))
+		}
+		if s := signals[0]; s.Name != "mapache_acu_temp" || s.VehicleID != `gr"24` || !math.IsInf(s.Value, 1) || s.Timestamp != 3000000 {
+			t.Errorf("Unexpected signal %+v", s)
+		}
+		if s := signals[2]; s.Name != "mapache_ecu_speed" || s.VehicleID != "gr24" || s.Value != 12.5 || !s.ProducedAt.Equal(time.UnixMilli(2000)) {
+			t.Errorf("Unexpected signal %+v", s)
+		}
+	})
+	t.Run("Test HTTP", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		if recorder.Header().Get("Content-Type") != PrometheusContentType || recorder.Body.String() != expected {
+			t.Errorf("Unexpected response %s", recorder.Body.String())
+		}
+	})
+	t.Run("Test concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				exporter.Update(Signal{Timestamp: 4000000 + i, VehicleID: "gr25", Name: "ecu_speed", Value: float64(i)})
+				exporter.WriteTo(&bytes.Buffer{})
+			}(i)
+		}
+		wg.Wait()
+		signals, _ := ParsePrometheus(strings.NewReader(func() string {
+			var buf bytes.Buffer
+			exporter.WriteTo(&buf)
+			return buf.String()
+		}()))
+		if s := signals[len(signals)-1]; s.VehicleID != "gr25" || s.Value != 7 {
+			t.Errorf("Expected the latest value 7, got %+v", s)
+		}
+	})
+	t.Run("Test colliding names", func(t *testing.T) {
+		exporter := NewPrometheusExporter()
+		exporter.Update(
+			Signal{Timestamp: 1000000, VehicleID: "gr24", Name: "acu.temp", Value: 30},
+			Signal{Timestamp: 1000000, VehicleID: "gr24", Name: "acu_temp", Value: 31},
+		)
+		var buf bytes.Buffer
+		if _, err := exporter.WriteTo(&buf); err == nil || !strings.Contains(err.Error(), "acu.temp and acu_temp") || buf.Len() != 0 {
+			t.Errorf("Expected collision error without output, got %v", err)
+		}
+		recorder := httptest.NewRecorder()
+		exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, recorder.Code)
+		}
+	})
+}
+
+func TestPrometheusMetricName(t *testing.T) {
+	testCases := []struct {
+		namespace string
+		name      string
+		expected  string
+	}{
+		{"", "ecu_speed", "ecu_speed"},
+		{"mapache", "ecu_speed", "mapache_ecu_speed"},
+		{"", "acu.cell-temp 1", "acu_cell_temp_1"},
+		{"", "1st_gear", "_st_gear"},
+		{"", "", "_"},
+	}
+	for _, tc := range testCases {
+		if got := PrometheusMetricName(tc.namespace, tc.name); got != tc.expected {
+			t.Errorf("Expected %s, got %s", tc.expected, got)
+		}
+	}
+}
+
+func TestParsePrometheus(t *testing.T) {
+	input := strings.Join([]string{
+		"# HELP ecu_speed Speed of the vehicle",
+		"# TYPE ecu_speed gauge",
+		`ecu_speed{vehicle_id="gr24",track="a\\b\nc"} 12.5 1697040000123`,
+		"ecu_uptime 3",
+		`ecu_state{ vehicle_id = "gr23" , } NaN`,
+	}, "\n")
+	signals, err := ParsePrometheus(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if len(signals) != 3 || signals[0].Timestamp != 1697040000123000 || signals[0].VehicleID != "gr24" ||
+		signals[1].Name != "ecu_uptime" || signals[1].Value != 3 || signals[2].VehicleID != "gr23" || !math.IsNaN(signals[2].Value) {
+		t.Errorf("Unexpected signals %+v", signals)
+	}
+	for _, invalid := range []string{"{} 1", "speed", "speed fast", `speed{vehicle_id="gr24} 1`, `speed{vehicle_id=gr24} 1`, "speed 1 soon", "speed 1 2 3"} {
+		if _, err := ParsePrometheus(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected error for %q, got nil", invalid)
+		}
+	}
+}
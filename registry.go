@@ -0,0 +1,115 @@
+package mapache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownMessage is returned when a message is not registered for a vehicle type.
+var ErrUnknownMessage = errors.New("unknown message")
+
+// Registry maps the CANKey of each message to its Message template for each vehicle type, so that standard and
+// extended messages with the same numeric ID are kept apart, as in DBC.Messages and DecodeCandump.
+// The vehicle type matches Vehicle.Type (gr23, gr24, etc), since each type has its own controller architecture.
+// A Registry is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	messages map[string]map[CANKey]Message
+}
+
+// NewRegistry creates a new empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		messages: map[string]map[CANKey]Message{},
+	}
+}
+
+// Register adds a Message template for the given vehicle type and message key.
+// Any existing template with the same vehicle type and key is replaced.
+func (r *Registry) Register(vehicleType string, key CANKey, message Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.messages[vehicleType] == nil {
+		r.messages[vehicleType] = map[CANKey]Message{}
+	}
+	r.messages[vehicleType][key] = message.Copy()
+}
+
+// RegisterDBC adds every message of a DBC as a template for the given vehicle type.
+func (r *Registry) RegisterDBC(vehicleType string, dbc *DBC) {
+	for key, message := range dbc.Messages {
+		r.Register(vehicleType, key, message.Message)
+	}
+}
+
+// RegisterSchema adds every message of a Schema as a template for the given vehicle type.
+// It returns an error without registering anything if a message definition is invalid.
+func (r *Registry) RegisterSchema(vehicleType string, schema *Schema) error {
+	messages := make([]Message, len(schema.Messages))
+	for i, definition := range schema.Messages {
+		message, err := definition.Message()
+		if err != nil {
+			return err
+		}
+		messages[i] = message
+	}
+	for i, definition := range schema.Messages {
+		r.Register(vehicleType, definition.Key(), messages[i])
+	}
+	return nil
+}
+
+// Lookup returns a fresh copy of the Message template for the given vehicle type and message key,
+// which can be filled without affecting the template or other callers.
+// It returns an error wrapping ErrUnknownMessage if no template is registered.
+func (r *Registry) Lookup(vehicleType string, key CANKey) (Message, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	message, ok := r.messages[vehicleType][key]
+	if !ok {
+		return nil, fmt.Errorf("%w %s for vehicle type %s", ErrUnknownMessage, key, vehicleType)
+	}
+	return message.Copy(), nil
+}
+
+// Decode decodes a raw frame for the given vehicle type and message key into a list of Signals.
+// See Message.DecodeSignals for how the data length is handled.
+// It returns an error wrapping ErrUnknownMessage if no template is registered.
+func (r *Registry) Decode(vehicleType string, key CANKey, data []byte) ([]Signal, error) {
+	message, err := r.Lookup(vehicleType, key)
+	if err != nil {
+		return nil, err
+	}
+	signals, err := message.DecodeSignals(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode message %s for vehicle type %s: %v", key, vehicleType, err)
+	}
+	return signals, nil
+}
+
+// Keys returns the registered message keys for the given vehicle type in ascending order of ID,
+// with standard messages before extended ones.
+func (r *Registry) Keys(vehicleType string) []CANKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]CANKey, 0, len(r.messages[vehicleType]))
+	for key := range r.messages[vehicleType] {
+		keys = append(keys, key)
+	}
+	sortCANKeys(keys)
+	return keys
+}
+
+// VehicleTypes returns the vehicle types that have at least one registered message, in ascending order.
+func (r *Registry) VehicleTypes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.messages))
+	for vehicleType := range r.messages {
+		types = append(types, vehicleType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,130 @@
+package mapache
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("gr24", CANKey{ID: 0x10}, Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil),
+		NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC"),
+	})
+	registry.Register("gr23", CANKey{ID: 0x10}, Message{
+		NewField("ecu_state", 2, Unsigned, LittleEndian, nil),
+	})
+	t.Run("Test decode per vehicle type", func(t *testing.T) {
+		signals, err := registry.Decode("gr24", CANKey{ID: 0x10}, []byte{0x03, 0x82})
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if len(signals) != 2 || signals[0].Value != 3 || signals[1].Value != 32.5 {
+			t.Errorf("Unexpected signals %+v", signals)
+		}
+		signals, err = registry.Decode("gr23", CANKey{ID: 0x10}, []byte{0x03, 0x01})
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if len(signals) != 1 || signals[0].Value != 259 {
+			t.Errorf("Unexpected signals %+v", signals)
+		}
+	})
+	t.Run("Test padded frame", func(t *testing.T) {
+		signals, err := registry.Decode("gr24", CANKey{ID: 0x10}, []byte{0x03, 0x82, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00})
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if len(signals) != 2 {
+			t.Errorf("Expected 2 signals, got %d", len(signals))
+		}
+	})
+	t.Run("Test short frame", func(t *testing.T) {
+		_, err := registry.Decode("gr24", CANKey{ID: 0x10}, []byte{0x03})
+		if err == nil || errors.Is(err, ErrUnknownMessage) {
+			t.Errorf("Expected length error, got %v", err)
+		}
+	})
+	t.Run("Test unknown id", func(t *testing.T) {
+		_, err := registry.Decode("gr24", CANKey{ID: 0x11}, []byte{0x03})
+		if !errors.Is(err, ErrUnknownMessage) {
+			t.Errorf("Expected ErrUnknownMessage, got %v", err)
+		}
+		_, err = registry.Lookup("gr25", CANKey{ID: 0x10})
+		if !errors.Is(err, ErrUnknownMessage) {
+			t.Errorf("Expected ErrUnknownMessage, got %v", err)
+		}
+	})
+	t.Run("Test extended id", func(t *testing.T) {
+		registry.Register("gr24", CANKey{ID: 0x10, Extended: true}, Message{NewField("bms_soc", 1, Unsigned, BigEndian, nil)})
+		signals, err := registry.Decode("gr24", CANKey{ID: 0x10, Extended: true}, []byte{0x50})
+		if err != nil || len(signals) != 1 || signals[0].Name != "bms_soc" {
+			t.Errorf("Unexpected signals %+v (%v)", signals, err)
+		}
+		signals, err = registry.Decode("gr24", CANKey{ID: 0x10}, []byte{0x03, 0x82})
+		if err != nil || len(signals) != 2 || signals[0].Name != "ecu_state" {
+			t.Errorf("Unexpected signals %+v (%v)", signals, err)
+		}
+		if !reflect.DeepEqual(registry.Keys("gr24"), []CANKey{{ID: 0x10}, {ID: 0x10, Extended: true}}) {
+			t.Errorf("Unexpected keys %v", registry.Keys("gr24"))
+		}
+	})
+	t.Run("Test lookup returns copy", func(t *testing.T) {
+		message, err := registry.Lookup("gr24", CANKey{ID: 0x10})
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		message.FillFromBytes([]byte{0x05, 0x06})
+		fresh, _ := registry.Lookup("gr24", CANKey{ID: 0x10})
+		if fresh[0].Value != 0 || fresh[0].Bytes != nil {
+			t.Errorf("Expected template to be untouched, got %+v", fresh[0])
+		}
+	})
+	t.Run("Test keys", func(t *testing.T) {
+		if !reflect.DeepEqual(registry.VehicleTypes(), []string{"gr23", "gr24"}) {
+			t.Errorf("Unexpected vehicle types %v", registry.VehicleTypes())
+		}
+		if !reflect.DeepEqual(registry.Keys("gr23"), []CANKey{{ID: 0x10}}) {
+			t.Errorf("Unexpected keys %v", registry.Keys("gr23"))
+		}
+	})
+	t.Run("Test concurrent decode", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := registry.Decode("gr24", CANKey{ID: 0x10}, []byte{0x03, 0x82})
+				if err != nil {
+					t.Errorf("Expected nil, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestRegistryDBC(t *testing.T) {
+	dbc, err := ParseDBC(strings.NewReader(testDBC))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	registry := NewRegistry()
+	registry.RegisterDBC("gr24", dbc)
+	if !reflect.DeepEqual(registry.Keys("gr24"), []CANKey{{ID: 1}, {ID: 0x1000, Extended: true}}) {
+		t.Errorf("Unexpected keys %v", registry.Keys("gr24"))
+	}
+	if _, err := registry.Lookup("gr24", CANKey{ID: 0x1000}); !errors.Is(err, ErrUnknownMessage) {
+		t.Errorf("Expected ErrUnknownMessage for standard id 0x1000, got %v", err)
+	}
+	signals, err := registry.Decode("gr24", CANKey{ID: 1}, []byte{0x03, 0x00, 0x00, 0x00, 0x31, 0x82, 0xE8, 0x03})
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if len(signals) != 5 || signals[3].Value != 32.5 {
+		t.Errorf("Unexpected signals %+v", signals)
+	}
+}
@@ -0,0 +1,169 @@
+package mapache
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Interpolation selects how a resampled value is computed from the samples around its timestamp.
+type Interpolation int
+
+const (
+	// ZeroOrderHold holds the last sample at or before the timestamp.
+	ZeroOrderHold Interpolation = 0
+	// LinearInterpolation interpolates between the last sample at or before the timestamp and the first sample
+	// after it. Values are never extrapolated beyond the first or last sample.
+	LinearInterpolation Interpolation = 1
+	// NearestSample takes the sample closest to the timestamp, preferring the earlier one on ties.
+	NearestSample Interpolation = 2
+)
+
+// Resampler aligns signals of different rates onto a common grid of timestamps.
+type Resampler struct {
+	// Period between resampled timestamps, which must be at least a microsecond.
+	Period time.Duration
+	// Interpolation used to compute resampled values.
+	Interpolation Interpolation
+	// MaxGap is the maximum time a resampled value may be derived over, after which it is missing.
+	// For ZeroOrderHold and NearestSample it limits the distance to the sample used, and for LinearInterpolation
+	// the distance between the two samples interpolated. A MaxGap of 0 means no limit.
+	MaxGap time.Duration
+}
+
+// NewResampler creates a new Resampler at the given rate in Hz, without a maximum gap.
+func NewResampler(rate float64, interpolation Interpolation) Resampler {
+	return Resampler{
+		Period:        time.Duration(float64(time.Second) / rate),
+		Interpolation: interpolation,
+	}
+}
+
+// Resample aligns the signals of each vehicle onto timestamps that are multiples of the Period, from the first to
+// the last signal of the vehicle. It returns a table in the wide layout, with a row per vehicle and timestamp and a
+// column per signal name sorted by name, where missing values are null.
+func (r Resampler) Resample(signals []Signal) (SignalTable, error) {
+	period := int(r.Period.Microseconds())
+	if period <= 0 {
+		return SignalTable{}, fmt.Errorf("invalid resampling period %v, expected at least 1µs", r.Period)
+	}
+	maxGap := int(r.MaxGap.Microseconds())
+
+	series := map[signalSeries][]Signal{}
+	spans := map[string][2]int{}
+	columnIndex := map[string]int{}
+	names := []string{}
+	for _, signal := range signals {
+		key := signalSeries{signal.VehicleID, signal.Name}
+		if _, ok := columnIndex[signal.Name]; !ok {
+			columnIndex[signal.Name] = 0
+			names = append(names, signal.Name)
+		}
+		series[key] = append(series[key], signal)
+		span, ok := spans[signal.VehicleID]
+		if !ok {
+			span = [2]int{signal.Timestamp, signal.Timestamp}
+		}
+		spans[signal.VehicleID] = [2]int{min(span[0], signal.Timestamp), max(span[1], signal.Timestamp)}
+	}
+	sort.Strings(names)
+
+	rows := []wideRow{}
+	for vehicleID, span := range spans {
+		for t := floorDiv(span[0], period) * period; t <= span[1]; t += period {
+			rows = append(rows, wideRow{t, vehicleID})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].timestamp != rows[j].timestamp {
+			return rows[i].timestamp < rows[j].timestamp
+		}
+		return rows[i].vehicleID < rows[j].vehicleID
+	})
+	table := SignalTable{
+		Rows: len(rows),
+		Columns: []SignalColumn{
+			{Name: "timestamp", Type: TimestampColumn, Ints: make([]int64, len(rows))},
+			{Name: "vehicle_id", Type: StringColumn, Strings: make([]string, len(rows))},
+		},
+	}
+	// rows of each vehicle, in order of their timestamps
+	vehicleRows := map[string][]int{}
+	for i, row := range rows {
+		table.Columns[0].Ints[i] = int64(row.timestamp)
+		table.Columns[1].Strings[i] = row.vehicleID
+		vehicleRows[row.vehicleID] = append(vehicleRows[row.vehicleID], i)
+	}
+	for _, name := range names {
+		columnIndex[name] = len(table.Columns)
+		table.Columns = append(table.Columns, SignalColumn{
+			Name:   name,
+			Type:   FloatColumn,
+			Floats: make([]float64, len(rows)),
+			Valid:  make([]bool, len(rows)),
+		})
+	}
+
+	for key, samples := range series {
+		sort.SliceStable(samples, func(i, j int) bool {
+			return samples[i].Timestamp < samples[j].Timestamp
+		})
+		column := table.Columns[columnIndex[key.name]]
+		next := 0
+		for _, row := range vehicleRows[key.vehicleID] {
+			t := rows[row].timestamp
+			for next < len(samples) && samples[next].Timestamp <= t {
+				next++
+			}
+			column.Floats[row], column.Valid[row] = r.interpolate(samples, next, t, maxGap)
+		}
+	}
+	return table, nil
+}
+
+// interpolate returns the value at timestamp t of samples sorted by timestamp, where next is the index of the first
+// sample after t, and whether the value is present.
+func (r Resampler) interpolate(samples []Signal, next int, t int, maxGap int) (float64, bool) {
+	within := func(gap int) bool {
+		return maxGap == 0 || gap <= maxGap
+	}
+	var before, after *Signal
+	if next > 0 {
+		before = &samples[next-1]
+	}
+	if next < len(samples) {
+		after = &samples[next]
+	}
+	switch r.Interpolation {
+	case LinearInterpolation:
+		if before != nil && before.Timestamp == t {
+			return before.Value, true
+		} else if before == nil || after == nil || !within(after.Timestamp-before.Timestamp) {
+			return 0, false
+		}
+		fraction := float64(t-before.Timestamp) / float64(after.Timestamp-before.Timestamp)
+		return before.Value + (after.Value-before.Value)*fraction, true
+	case NearestSample:
+		nearest := before
+		if after != nil && (before == nil || after.Timestamp-t < t-before.Timestamp) {
+			nearest = after
+		}
+		if nearest == nil || !within(max(nearest.Timestamp-t, t-nearest.Timestamp)) {
+			return 0, false
+		}
+		return nearest.Value, true
+	default:
+		if before == nil || !within(t-before.Timestamp) {
+			return 0, false
+		}
+		return before.Value, true
+	}
+}
+
+// floorDiv returns a divided by b, rounded towards negative infinity.
+func floorDiv(a int, b int) int {
+	if a < 0 && a%b != 0 {
+		return a/b - 1
+	}
+	return a / b
+}
@@ -0,0 +1,105 @@
+package mapache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// resampledValues returns the values of a resampled column, with -1 marking missing values.
+func resampledValues(t *testing.T, table SignalTable, name string) []float64 {
+	t.Helper()
+	column, ok := table.Column(name)
+	if !ok {
+		t.Fatalf("Expected column %s", name)
+	}
+	values := make([]float64, table.Rows)
+	for i := range values {
+		values[i] = -1
+		if column.IsValid(i) {
+			values[i] = column.Floats[i]
+		}
+	}
+	return values
+}
+
+func TestResample(t *testing.T) {
+	// wheel speed at 100 Hz between 10 ms and 50 ms, and cell temp with only two samples
+	signals := []Signal{}
+	for ts := 10000; ts <= 50000; ts += 10000 {
+		signals = append(signals, Signal{Timestamp: ts, VehicleID: "gr24", Name: "wheel_speed", Value: float64(ts / 1000)})
+	}
+	signals = append(signals,
+		Signal{Timestamp: 2000, VehicleID: "gr24", Name: "cell_temp", Value: 20},
+		Signal{Timestamp: 34000, VehicleID: "gr24", Name: "cell_temp", Value: 52},
+	)
+	testCases := []struct {
+		interpolation Interpolation
+		maxGap        time.Duration
+		speed         []float64
+		temp          []float64
+	}{
+		{ZeroOrderHold, 0, []float64{-1, -1, 10, 10, 20, 20, 30, 30, 40, 40, 50}, []float64{-1, 20, 20, 20, 20, 20, 20, 52, 52, 52, 52}},
+		{ZeroOrderHold, 10 * time.Millisecond, []float64{-1, -1, 10, 10, 20, 20, 30, 30, 40, 40, 50}, []float64{-1, 20, 20, -1, -1, -1, -1, 52, 52, -1, -1}},
+		{LinearInterpolation, 0, []float64{-1, -1, 10, 15, 20, 25, 30, 35, 40, 45, 50}, []float64{-1, 23, 28, 33, 38, 43, 48, -1, -1, -1, -1}},
+		{LinearInterpolation, 10 * time.Millisecond, []float64{-1, -1, 10, 15, 20, 25, 30, 35, 40, 45, 50}, []float64{-1, -1, -1, -1, -1, -1, -1, -1, -1, -1, -1}},
+		{NearestSample, 0, []float64{10, 10, 10, 10, 20, 20, 30, 30, 40, 40, 50}, []float64{20, 20, 20, 20, 52, 52, 52, 52, 52, 52, 52}},
+		{NearestSample, 3 * time.Millisecond, []float64{-1, -1, 10, -1, 20, -1, 30, -1, 40, -1, 50}, []float64{20, 20, -1, -1, -1, -1, -1, 52, -1, -1, -1}},
+	}
+	for _, tc := range testCases {
+		resampler := NewResampler(200, tc.interpolation)
+		resampler.MaxGap = tc.maxGap
+		table, err := resampler.Resample(signals)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if table.Rows != 11 || table.Columns[0].Ints[0] != 0 || table.Columns[0].Ints[10] != 50000 {
+			t.Fatalf("Expected 11 rows from 0 to 50 ms, got %v", table.Columns[0].Ints)
+		}
+		if table.Columns[2].Name != "cell_temp" || table.Columns[3].Name != "wheel_speed" {
+			t.Errorf("Expected columns sorted by name, got %s and %s", table.Columns[2].Name, table.Columns[3].Name)
+		}
+		if speed := resampledValues(t, table, "wheel_speed"); !reflect.DeepEqual(speed, tc.speed) {
+			t.Errorf("Interpolation %d, max gap %v: expected wheel_speed %v, got %v", tc.interpolation, tc.maxGap, tc.speed, speed)
+		}
+		if temp := resampledValues(t, table, "cell_temp"); !reflect.DeepEqual(temp, tc.temp) {
+			t.Errorf("Interpolation %d, max gap %v: expected cell_temp %v, got %v", tc.interpolation, tc.maxGap, tc.temp, temp)
+		}
+	}
+}
+
+func TestResampleVehicles(t *testing.T) {
+	signals := []Signal{
+		{Timestamp: 1000500, VehicleID: "gr24", Name: "ecu_speed", Value: 1},
+		{Timestamp: 1000000, VehicleID: "gr24", Name: "ecu_speed", Value: 2},
+		{Timestamp: 1000000, VehicleID: "gr24", Name: "ecu_speed", Value: 3},
+		{Timestamp: 1001000, VehicleID: "gr23", Name: "ecu_speed", Value: 4},
+		{Timestamp: -1500, VehicleID: "gr22", Name: "ecu_speed", Value: 5},
+		{Timestamp: -500, VehicleID: "gr22", Name: "ecu_speed", Value: 6},
+	}
+	table, err := Resampler{Period: time.Millisecond}.Resample(signals)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	// gr22 has rows at -2 ms and -1 ms, gr24 at 1000 ms, and gr23 at 1001 ms
+	if !reflect.DeepEqual(table.Columns[0].Ints, []int64{-2000, -1000, 1000000, 1001000}) ||
+		!reflect.DeepEqual(table.Columns[1].Strings, []string{"gr22", "gr22", "gr24", "gr23"}) {
+		t.Errorf("Unexpected rows %v %v", table.Columns[0].Ints, table.Columns[1].Strings)
+	}
+	// the last of duplicate samples is held
+	if speed := resampledValues(t, table, "ecu_speed"); !reflect.DeepEqual(speed, []float64{-1, 5, 3, 4}) {
+		t.Errorf("Unexpected values %v", speed)
+	}
+
+	t.Run("Test invalid period", func(t *testing.T) {
+		if _, err := (Resampler{Period: time.Nanosecond}).Resample(signals); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test empty", func(t *testing.T) {
+		table, err := NewResampler(10, ZeroOrderHold).Resample(nil)
+		if err != nil || table.Rows != 0 || len(table.Columns) != 2 {
+			t.Errorf("Unexpected table %+v (%v)", table, err)
+		}
+	})
+}
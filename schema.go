@@ -0,0 +1,270 @@
+package mapache
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a serializable set of message definitions that can be shared with services not written in Go.
+// It can be loaded from and written to YAML or JSON, using the same field names in both formats.
+type Schema struct {
+	Messages []MessageSchema `json:"messages" yaml:"messages"`
+}
+
+// MessageSchema is the serializable definition of a single message.
+type MessageSchema struct {
+	ID int `json:"id" yaml:"id"`
+	// Extended is true if the message uses a 29-bit extended CAN ID.
+	Extended bool   `json:"extended,omitempty" yaml:"extended,omitempty"`
+	Name     string `json:"name" yaml:"name"`
+	// Version is the layout version of the message, which must be increased on every breaking change.
+	// See Schema.CheckVersions.
+	Version int           `json:"version,omitempty" yaml:"version,omitempty"`
+	Fields  []FieldSchema `json:"fields" yaml:"fields"`
+}
+
+// FieldSchema is the serializable definition of a single Field.
+// Sign is one of "signed", "unsigned", or "float", Endian is one of "big" or "little",
+// and FlagOrder is one of "msb_first" (the default) or "lsb_first".
+// Bit fields are defined with StartBit and BitLength instead of Size,
+// and multiplexed fields are defined by setting MuxValue.
+type FieldSchema struct {
+	Name        string     `json:"name" yaml:"name"`
+	Size        int        `json:"size,omitempty" yaml:"size,omitempty"`
+	Sign        string     `json:"sign" yaml:"sign"`
+	Endian      string     `json:"endian" yaml:"endian"`
+	StartBit    int        `json:"start_bit,omitempty" yaml:"start_bit,omitempty"`
+	BitLength   int        `json:"bit_length,omitempty" yaml:"bit_length,omitempty"`
+	Multiplexer bool       `json:"multiplexer,omitempty" yaml:"multiplexer,omitempty"`
+	MuxValue    *int       `json:"mux_value,omitempty" yaml:"mux_value,omitempty"`
+	Factor      float64    `json:"factor,omitempty" yaml:"factor,omitempty"`
+	Offset      float64    `json:"offset,omitempty" yaml:"offset,omitempty"`
+	Unit        string     `json:"unit,omitempty" yaml:"unit,omitempty"`
+	Min         float64    `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         float64    `json:"max,omitempty" yaml:"max,omitempty"`
+	ValueTable  ValueTable `json:"value_table,omitempty" yaml:"value_table,omitempty"`
+	Flags       []string   `json:"flags,omitempty" yaml:"flags,omitempty"`
+	FlagOrder   string     `json:"flag_order,omitempty" yaml:"flag_order,omitempty"`
+}
+
+var (
+	schemaSignModes = map[string]SignMode{"signed": Signed, "unsigned": Unsigned, "float": Float}
+	schemaEndians   = map[string]Endian{"big": BigEndian, "little": LittleEndian}
+	schemaBitOrders = map[string]BitOrder{"": MSBFirst, "msb_first": MSBFirst, "lsb_first": LSBFirst}
+)
+
+// ParseSchemaFile parses a schema from the YAML or JSON file at the given path.
+func ParseSchemaFile(path string) (*Schema, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseSchema(file)
+}
+
+// ParseSchema parses a schema from YAML, or from JSON if the document starts with an object.
+// Unknown keys are rejected, so typos in field options are not silently ignored.
+// It returns an error if a message or field definition is invalid or if two messages share the same ID.
+func ParseSchema(r io.Reader) (*Schema, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	schema := &Schema{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(schema)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(schema); err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %v", err)
+	}
+	keys := map[CANKey]string{}
+	for _, message := range schema.Messages {
+		if name, ok := keys[message.Key()]; ok {
+			return nil, fmt.Errorf("duplicate message id 0x%X for %s and %s", message.ID, name, message.Name)
+		}
+		keys[message.Key()] = message.Name
+		if _, err := message.Message(); err != nil {
+			return nil, err
+		}
+	}
+	return schema, nil
+}
+
+// WriteSchemaFile writes the schema to the file at the given path,
+// as JSON if the path has a .json extension and as YAML otherwise.
+func WriteSchemaFile(path string, schema *Schema) error {
+	write := WriteSchemaYAML
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		write = WriteSchemaJSON
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file, schema); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteSchemaYAML writes the schema as YAML.
+func WriteSchemaYAML(w io.Writer, schema *Schema) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(schema); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteSchemaJSON writes the schema as indented JSON.
+func WriteSchemaJSON(w io.Writer, schema *Schema) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(schema)
+}
+
+// NewSchema creates a Schema from the messages of a DBC, ordered by ID.
+func NewSchema(dbc *DBC) *Schema {
+	schema := &Schema{}
+	for _, key := range dbc.keys() {
+		message := dbc.Messages[key]
+		definition := NewMessageSchema(key.ID, message.Name, message.Message)
+		definition.Extended = key.Extended
+		schema.Messages = append(schema.Messages, definition)
+	}
+	return schema
+}
+
+// Key returns the CANKey of the message.
+func (m MessageSchema) Key() CANKey {
+	return CANKey{ID: m.ID, Extended: m.Extended}
+}
+
+// NewMessageSchema creates the serializable definition of a Message.
+// ExportSignalFuncs cannot be serialized, so fields using them are defined with the default behavior.
+func NewMessageSchema(id int, name string, message Message) MessageSchema {
+	schema := MessageSchema{
+		ID:     id,
+		Name:   name,
+		Fields: make([]FieldSchema, len(message)),
+	}
+	for i, field := range message {
+		schema.Fields[i] = newFieldSchema(field)
+	}
+	return schema
+}
+
+// newFieldSchema creates the serializable definition of a Field.
+func newFieldSchema(f Field) FieldSchema {
+	schema := FieldSchema{
+		Name:        f.Name,
+		Sign:        signName(f.Sign),
+		Endian:      endianName(f.Endian),
+		Multiplexer: f.Multiplexer,
+		Factor:      f.Factor,
+		Offset:      f.Offset,
+		Unit:        f.Unit,
+		Min:         f.Min,
+		Max:         f.Max,
+		ValueTable:  f.ValueTable,
+		Flags:       f.Flags,
+	}
+	if f.IsBitField() {
+		schema.StartBit = f.StartBit
+		schema.BitLength = f.BitLength
+	} else {
+		schema.Size = f.Size
+	}
+	if f.Multiplexed {
+		muxValue := f.MuxValue
+		schema.MuxValue = &muxValue
+	}
+	if len(f.Flags) > 0 && f.FlagOrder == LSBFirst {
+		schema.FlagOrder = "lsb_first"
+	}
+	return schema
+}
+
+// Message creates a new Message from the definition.
+// It returns an error if a field has an unknown sign, endian, or flag order, or an invalid size,
+// or if the fields overlap (see Message.Validate).
+func (m MessageSchema) Message() (Message, error) {
+	message := make(Message, len(m.Fields))
+	for i, schema := range m.Fields {
+		field, err := schema.Field()
+		if err != nil {
+			return nil, fmt.Errorf("invalid field in %s: %v", m.Name, err)
+		}
+		message[i] = field
+	}
+	if err := message.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid layout of %s: %v", m.Name, err)
+	}
+	return message, nil
+}
+
+// Field creates a new Field from the definition.
+func (f FieldSchema) Field() (Field, error) {
+	sign, ok := schemaSignModes[f.Sign]
+	if !ok {
+		return Field{}, fmt.Errorf("unknown sign %q for %s", f.Sign, f.Name)
+	}
+	endian, ok := schemaEndians[f.Endian]
+	if !ok {
+		return Field{}, fmt.Errorf("unknown endian %q for %s", f.Endian, f.Name)
+	}
+	order, ok := schemaBitOrders[f.FlagOrder]
+	if !ok {
+		return Field{}, fmt.Errorf("unknown flag order %q for %s", f.FlagOrder, f.Name)
+	}
+	if f.Name == "" {
+		return Field{}, fmt.Errorf("missing field name")
+	}
+	var field Field
+	if f.BitLength > 0 {
+		if f.StartBit < 0 {
+			return Field{}, fmt.Errorf("invalid start bit %d for %s", f.StartBit, f.Name)
+		}
+		field = NewBitField(f.Name, f.StartBit, f.BitLength, sign, endian, nil)
+	} else if f.Size > 0 && f.BitLength == 0 {
+		field = NewField(f.Name, f.Size, sign, endian, nil)
+	} else {
+		return Field{}, fmt.Errorf("invalid size for %s, expected a positive size or bit length", f.Name)
+	}
+	field.Multiplexer = f.Multiplexer
+	if f.MuxValue != nil {
+		field = field.WithMuxValue(*f.MuxValue)
+	}
+	field.Factor = f.Factor
+	field.Offset = f.Offset
+	field.Unit = f.Unit
+	field.Min = f.Min
+	field.Max = f.Max
+	field.ValueTable = f.ValueTable
+	field.Flags = f.Flags
+	field.FlagOrder = order
+	return field, nil
+}
@@ -0,0 +1,198 @@
+package mapache
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testSchemaYAML = `messages:
+  - id: 0x10
+    name: ECU_Status
+    fields:
+      - name: ecu_state
+        size: 1
+        sign: unsigned
+        endian: big
+        value_table:
+          0: GLV_OFF
+          3: TS_ACTIVE
+      - name: ecu_status_flags
+        size: 1
+        sign: unsigned
+        endian: big
+        flags: [ecu_status_acu, ecu_status_dash]
+        flag_order: lsb_first
+      - name: ecu_max_cell_temp
+        size: 1
+        sign: unsigned
+        endian: big
+        factor: 0.25
+        unit: degC
+        max: 60
+      - name: ecu_power_level
+        sign: unsigned
+        endian: big
+        start_bit: 31
+        bit_length: 4
+`
+
+func testSchemaMessage() Message {
+	return Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil).WithValueTable(ValueTable{0: "GLV_OFF", 3: "TS_ACTIVE"}),
+		NewFlagsField("ecu_status_flags", 1, LSBFirst, []string{"ecu_status_acu", "ecu_status_dash"}),
+		NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC").WithRange(0, 60),
+		NewBitField("ecu_power_level", 31, 4, Unsigned, BigEndian, nil),
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	t.Run("Test yaml", func(t *testing.T) {
+		schema, err := ParseSchema(strings.NewReader(testSchemaYAML))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if len(schema.Messages) != 1 || schema.Messages[0].ID != 0x10 || schema.Messages[0].Name != "ECU_Status" {
+			t.Fatalf("Unexpected messages %+v", schema.Messages)
+		}
+		message, err := schema.Messages[0].Message()
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(message, testSchemaMessage()) {
+			t.Errorf("Expected %+v, got %+v", testSchemaMessage(), message)
+		}
+	})
+	t.Run("Test json", func(t *testing.T) {
+		schema, err := ParseSchema(strings.NewReader(`{"messages": [{"id": 1, "name": "BMS", "fields": [
+			{"name": "bms_cell_group", "size": 1, "sign": "unsigned", "endian": "big", "multiplexer": true},
+			{"name": "bms_cell_0_voltage", "size": 2, "sign": "signed", "endian": "little", "mux_value": 0},
+			{"name": "bms_current", "size": 4, "sign": "float", "endian": "little"}
+		]}]}`))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		message, _ := schema.Messages[0].Message()
+		expected := Message{
+			NewField("bms_cell_group", 1, Unsigned, BigEndian, nil).AsMultiplexer(),
+			NewField("bms_cell_0_voltage", 2, Signed, LittleEndian, nil).WithMuxValue(0),
+			NewField("bms_current", 4, Float, LittleEndian, nil),
+		}
+		if !reflect.DeepEqual(message, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, message)
+		}
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		invalid := []string{
+			`messages: [{id: 1, name: A, fields: [{name: a, size: 1, sign: unsigned, endian: middle}]}]`,
+			`messages: [{id: 1, name: A, fields: [{name: a, size: 1, sign: maybe, endian: big}]}]`,
+			`messages: [{id: 1, name: A, fields: [{name: a, size: 1, sign: unsigned, endian: big, flag_order: random}]}]`,
+			`messages: [{id: 1, name: A, fields: [{name: a, sign: unsigned, endian: big}]}]`,
+			`messages: [{id: 1, name: A, fields: [{size: 1, sign: unsigned, endian: big}]}]`,
+			`messages: [{id: 1, name: A, fields: [{name: a, size: 1, sign: unsigned, endian: big, scale: 2}]}]`,
+			`messages: [{id: 1, name: A, fields: []}, {id: 1, name: B, fields: []}]`,
+			`messages: [{id: 1, name: A, fields: [{name: a, size: 1, sign: unsigned, endian: big}, {name: b, sign: unsigned, endian: little, start_bit: 4, bit_length: 8}]}]`,
+			`messages: {id: 1}`,
+		}
+		for _, s := range invalid {
+			if _, err := ParseSchema(strings.NewReader(s)); err == nil {
+				t.Errorf("Expected error for %s, got nil", s)
+			}
+		}
+	})
+	t.Run("Test empty", func(t *testing.T) {
+		schema, err := ParseSchema(strings.NewReader(""))
+		if err != nil || len(schema.Messages) != 0 {
+			t.Errorf("Unexpected schema %+v (%v)", schema, err)
+		}
+	})
+}
+
+func TestWriteSchema(t *testing.T) {
+	schema := &Schema{Messages: []MessageSchema{
+		NewMessageSchema(0x10, "ECU_Status", testSchemaMessage()),
+		NewMessageSchema(0x11, "BMS", Message{
+			NewField("bms_cell_group", 1, Unsigned, BigEndian, nil).AsMultiplexer(),
+			NewField("bms_cell_0_voltage", 2, Signed, LittleEndian, nil).WithMuxValue(0),
+			NewField("bms_current", 4, Float, LittleEndian, nil),
+		}),
+	}}
+	t.Run("Test yaml round trip", func(t *testing.T) {
+		var sb strings.Builder
+		if err := WriteSchemaYAML(&sb, schema); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		parsed, err := ParseSchema(strings.NewReader(sb.String()))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(parsed, schema) {
+			t.Errorf("Expected %+v, got %+v", schema, parsed)
+		}
+	})
+	t.Run("Test json round trip", func(t *testing.T) {
+		var sb strings.Builder
+		if err := WriteSchemaJSON(&sb, schema); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !strings.Contains(sb.String(), `"mux_value": 0`) {
+			t.Errorf("Expected mux value in %s", sb.String())
+		}
+		parsed, err := ParseSchema(strings.NewReader(sb.String()))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(parsed, schema) {
+			t.Errorf("Expected %+v, got %+v", schema, parsed)
+		}
+	})
+	t.Run("Test file", func(t *testing.T) {
+		for _, name := range []string{"gr24.yaml", "gr24.json"} {
+			path := filepath.Join(t.TempDir(), name)
+			if err := WriteSchemaFile(path, schema); err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			parsed, err := ParseSchemaFile(path)
+			if err != nil || !reflect.DeepEqual(parsed, schema) {
+				t.Errorf("Unexpected schema %+v (%v)", parsed, err)
+			}
+		}
+	})
+	t.Run("Test from dbc", func(t *testing.T) {
+		dbc, err := ParseDBC(strings.NewReader(testDBC))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		schema := NewSchema(dbc)
+		if len(schema.Messages) != 2 || schema.Messages[0].ID != 1 || schema.Messages[1].ID != 0x1000 || !schema.Messages[1].Extended {
+			t.Fatalf("Unexpected messages %+v", schema.Messages)
+		}
+		message, err := schema.Messages[0].Message()
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if !reflect.DeepEqual(message, dbc.Messages[CANKey{ID: 1}].Message) {
+			t.Errorf("Expected %+v, got %+v", dbc.Messages[CANKey{ID: 1}].Message, message)
+		}
+	})
+}
+
+func TestRegistrySchema(t *testing.T) {
+	schema, _ := ParseSchema(strings.NewReader(testSchemaYAML))
+	registry := NewRegistry()
+	if err := registry.RegisterSchema("gr24", schema); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	signals, err := registry.Decode("gr24", CANKey{ID: 0x10}, []byte{0x03, 0x02, 0x82, 0x50})
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if len(signals) != 5 || signals[0].Label != "TS_ACTIVE" || signals[2].Value != 1 || signals[3].Value != 32.5 || signals[4].Value != 5 {
+		t.Errorf("Unexpected signals %+v", signals)
+	}
+	err = registry.RegisterSchema("gr25", &Schema{Messages: []MessageSchema{{ID: 1, Name: "A", Fields: []FieldSchema{{Name: "a"}}}}})
+	if err == nil || len(registry.Keys("gr25")) != 0 {
+		t.Errorf("Expected error and no registered messages, got %v", err)
+	}
+}
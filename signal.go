@@ -5,11 +5,13 @@ import (
 )
 
 // SignMode is a type to represent whether an integer is signed or unsigned.
+// Float is used for fields that hold an IEEE-754 floating point number instead of an integer.
 type SignMode int
 
 const (
 	Signed   SignMode = 1
 	Unsigned SignMode = 0
+	Float    SignMode = 2
 )
 
 // Endian is a type to represent whether an integer is big endian or little endian.
@@ -34,6 +36,9 @@ type Signal struct {
 	Value float64 `json:"value"`
 	// RawValue is the raw value of the signal before scaling.
 	RawValue int `json:"raw_value"`
+	// Label is the named state of RawValue, if the signal has a value table (e.g. "TS_ACTIVE").
+	// It is not stored in the database, since it can always be derived from RawValue.
+	Label string `json:"label,omitempty" gorm:"-"`
 	// ProducedAt is the time at which the signal was produced by the vehicle.
 	ProducedAt time.Time `json:"produced_at" gorm:"precision:6"`
 	// CreatedAt is the time at which the signal was actually stored in the database.
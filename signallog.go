@@ -0,0 +1,562 @@
+package mapache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+	"time"
+)
+
+// signalLogMagic starts and ends every signal log file.
+const signalLogMagic = "MSLG"
+
+// signalLogVersion is the version of the signal log file format.
+const signalLogVersion = 1
+
+// signalLogTrailerSize is the size of the trailer: the offset of the index (8 bytes) and the magic.
+const signalLogTrailerSize = 8 + len(signalLogMagic)
+
+// SignalLogChunkSize is the maximum number of points in a chunk of a signal log.
+// Readers only decode the chunks that overlap the requested time window.
+const SignalLogChunkSize = 1024
+
+// SignalLogSeries describes a single signal of a vehicle stored in a signal log.
+type SignalLogSeries struct {
+	VehicleID string
+	Name      string
+	// Count is the number of points in the series.
+	Count int
+	// Start and End are the first and last Timestamp of the series, in Unix microseconds.
+	Start int
+	End   int
+}
+
+// signalLogChunk is an index entry pointing to a chunk of points of a series.
+type signalLogChunk struct {
+	series int
+	offset int64
+	length int
+	count  int
+	start  int
+	end    int
+	crc    uint32
+}
+
+// WriteSignalLogFile writes the signals to a signal log file at the given path. See WriteSignalLog.
+func WriteSignalLogFile(path string, signals []Signal) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteSignalLog(file, signals); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WriteSignalLog writes the signals in a compact columnar format, readable with OpenSignalLog.
+// Signals are grouped into one series per VehicleID and Name and sorted by Timestamp. Each series is split into
+// chunks of up to SignalLogChunkSize points, holding delta-of-delta encoded timestamps, XOR compressed values,
+// and delta encoded raw values. An index of the series and the time range of each chunk is written at the end.
+// Only Timestamp, VehicleID, Name, Value, and RawValue are stored. ProducedAt is restored from Timestamp
+// when reading, and Label and CreatedAt are dropped.
+func WriteSignalLog(w io.Writer, signals []Signal) error {
+	type seriesKey struct{ vehicleID, name string }
+	points := map[seriesKey][]Signal{}
+	for _, signal := range signals {
+		key := seriesKey{signal.VehicleID, signal.Name}
+		points[key] = append(points[key], signal)
+	}
+	keys := make([]seriesKey, 0, len(points))
+	for key := range points {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].vehicleID != keys[j].vehicleID {
+			return keys[i].vehicleID < keys[j].vehicleID
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString(signalLogMagic)
+	buf.WriteByte(signalLogVersion)
+	series := make([]SignalLogSeries, len(keys))
+	chunks := []signalLogChunk{}
+	for i, key := range keys {
+		seriesPoints := points[key]
+		sort.SliceStable(seriesPoints, func(a, b int) bool {
+			return seriesPoints[a].Timestamp < seriesPoints[b].Timestamp
+		})
+		series[i] = SignalLogSeries{
+			VehicleID: key.vehicleID,
+			Name:      key.name,
+			Count:     len(seriesPoints),
+			Start:     seriesPoints[0].Timestamp,
+			End:       seriesPoints[len(seriesPoints)-1].Timestamp,
+		}
+		for start := 0; start < len(seriesPoints); start += SignalLogChunkSize {
+			chunkPoints := seriesPoints[start:min(start+SignalLogChunkSize, len(seriesPoints))]
+			data := encodeSignalLogChunk(chunkPoints)
+			chunks = append(chunks, signalLogChunk{
+				series: i,
+				offset: int64(buf.Len()),
+				length: len(data),
+				count:  len(chunkPoints),
+				start:  chunkPoints[0].Timestamp,
+				end:    chunkPoints[len(chunkPoints)-1].Timestamp,
+				crc:    crc32.ChecksumIEEE(data),
+			})
+			buf.Write(data)
+		}
+	}
+
+	indexOffset := buf.Len()
+	index := binary.AppendUvarint(nil, uint64(len(series)))
+	for _, s := range series {
+		index = appendSignalLogString(index, s.VehicleID)
+		index = appendSignalLogString(index, s.Name)
+	}
+	index = binary.AppendUvarint(index, uint64(len(chunks)))
+	for _, chunk := range chunks {
+		index = binary.AppendUvarint(index, uint64(chunk.series))
+		index = binary.AppendUvarint(index, uint64(chunk.offset))
+		index = binary.AppendUvarint(index, uint64(chunk.length))
+		index = binary.AppendUvarint(index, uint64(chunk.count))
+		index = binary.AppendVarint(index, int64(chunk.start))
+		index = binary.AppendVarint(index, int64(chunk.end))
+		index = binary.BigEndian.AppendUint32(index, chunk.crc)
+	}
+	buf.Write(index)
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(indexOffset)))
+	buf.WriteString(signalLogMagic)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// appendSignalLogString appends a length prefixed string.
+func appendSignalLogString(data []byte, s string) []byte {
+	return append(binary.AppendUvarint(data, uint64(len(s))), s...)
+}
+
+// encodeSignalLogChunk encodes the timestamps, values, and raw values of the points as three columns.
+// Timestamps use the delta-of-delta encoding and values the XOR encoding of Facebook's Gorilla paper.
+func encodeSignalLogChunk(points []Signal) []byte {
+	var timestamps, values bitWriter
+	previousTimestamp, previousDelta := int64(points[0].Timestamp), int64(0)
+	timestamps.writeBits(uint64(previousTimestamp), 64)
+	for _, point := range points[1:] {
+		delta := int64(point.Timestamp) - previousTimestamp
+		dod := delta - previousDelta
+		switch {
+		case dod == 0:
+			timestamps.writeBits(0b0, 1)
+		case dod >= -64 && dod <= 63:
+			timestamps.writeBits(0b10, 2)
+			timestamps.writeBits(uint64(dod), 7)
+		case dod >= -256 && dod <= 255:
+			timestamps.writeBits(0b110, 3)
+			timestamps.writeBits(uint64(dod), 9)
+		case dod >= -2048 && dod <= 2047:
+			timestamps.writeBits(0b1110, 4)
+			timestamps.writeBits(uint64(dod), 12)
+		default:
+			timestamps.writeBits(0b1111, 4)
+			timestamps.writeBits(uint64(dod), 64)
+		}
+		previousTimestamp, previousDelta = int64(point.Timestamp), delta
+	}
+
+	previousValue := math.Float64bits(points[0].Value)
+	values.writeBits(previousValue, 64)
+	leading, trailing := 65, 0
+	for _, point := range points[1:] {
+		value := math.Float64bits(point.Value)
+		xor := value ^ previousValue
+		previousValue = value
+		if xor == 0 {
+			values.writeBits(0b0, 1)
+			continue
+		}
+		l, t := min(bits.LeadingZeros64(xor), 31), bits.TrailingZeros64(xor)
+		if leading <= 64 && l >= leading && t >= trailing {
+			values.writeBits(0b10, 2)
+			values.writeBits(xor>>uint(trailing), 64-leading-trailing)
+			continue
+		}
+		leading, trailing = l, t
+		values.writeBits(0b11, 2)
+		values.writeBits(uint64(leading), 5)
+		values.writeBits(uint64(64-leading-trailing)&0x3F, 6)
+		values.writeBits(xor>>uint(trailing), 64-leading-trailing)
+	}
+
+	data := binary.AppendUvarint(nil, uint64(len(timestamps.data)))
+	data = append(data, timestamps.data...)
+	data = binary.AppendUvarint(data, uint64(len(values.data)))
+	data = append(data, values.data...)
+	previousRaw := int64(0)
+	for _, point := range points {
+		data = binary.AppendVarint(data, int64(point.RawValue)-previousRaw)
+		previousRaw = int64(point.RawValue)
+	}
+	return data
+}
+
+// decodeSignalLogChunk decodes the points of a chunk encoded by encodeSignalLogChunk.
+func decodeSignalLogChunk(data []byte, count int, vehicleID string, name string) ([]Signal, error) {
+	invalid := fmt.Errorf("invalid signal log chunk for %s", name)
+	column := func() ([]byte, error) {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, invalid
+		}
+		c := data[n : n+int(length)]
+		data = data[n+int(length):]
+		return c, nil
+	}
+	timestampData, err := column()
+	if err != nil {
+		return nil, err
+	}
+	valueData, err := column()
+	if err != nil {
+		return nil, err
+	}
+	points := make([]Signal, count)
+
+	timestamps := bitReader{data: timestampData}
+	first, err := timestamps.readBits(64)
+	if err != nil {
+		return nil, invalid
+	}
+	previousTimestamp, previousDelta := int64(first), int64(0)
+	points[0].Timestamp = int(previousTimestamp)
+	for i := 1; i < count; i++ {
+		prefix := 0
+		for prefix < 4 {
+			bit, err := timestamps.readBits(1)
+			if err != nil {
+				return nil, invalid
+			} else if bit == 0 {
+				break
+			}
+			prefix++
+		}
+		dod := int64(0)
+		if size := [5]int{0, 7, 9, 12, 64}[prefix]; size > 0 {
+			v, err := timestamps.readBits(size)
+			if err != nil {
+				return nil, invalid
+			}
+			dod = int64(v<<uint(64-size)) >> uint(64-size)
+		}
+		previousDelta += dod
+		previousTimestamp += previousDelta
+		points[i].Timestamp = int(previousTimestamp)
+	}
+
+	values := bitReader{data: valueData}
+	previousValue, err := values.readBits(64)
+	if err != nil {
+		return nil, invalid
+	}
+	points[0].Value = math.Float64frombits(previousValue)
+	leading, trailing := 0, 0
+	for i := 1; i < count; i++ {
+		control, err := values.readBits(1)
+		if err != nil {
+			return nil, invalid
+		}
+		if control == 1 {
+			if control, err = values.readBits(1); err != nil {
+				return nil, invalid
+			}
+			if control == 1 {
+				l, err1 := values.readBits(5)
+				size, err2 := values.readBits(6)
+				if err1 != nil || err2 != nil {
+					return nil, invalid
+				}
+				if size == 0 {
+					size = 64
+				}
+				leading, trailing = int(l), 64-int(l)-int(size)
+				if trailing < 0 {
+					return nil, invalid
+				}
+			}
+			xor, err := values.readBits(64 - leading - trailing)
+			if err != nil {
+				return nil, invalid
+			}
+			previousValue ^= xor << uint(trailing)
+		}
+		points[i].Value = math.Float64frombits(previousValue)
+	}
+
+	previousRaw := int64(0)
+	for i := range points {
+		delta, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, invalid
+		}
+		data = data[n:]
+		previousRaw += delta
+		points[i].RawValue = int(previousRaw)
+		points[i].VehicleID = vehicleID
+		points[i].Name = name
+		points[i].ProducedAt = time.UnixMicro(int64(points[i].Timestamp))
+	}
+	return points, nil
+}
+
+// bitWriter appends bits to a byte slice, most significant bit first.
+type bitWriter struct {
+	data  []byte
+	count int
+}
+
+// writeBits writes the lowest n bits of v.
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.count%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			w.data[len(w.data)-1] |= 0x80 >> uint(w.count%8)
+		}
+		w.count++
+	}
+}
+
+// bitReader reads bits written by a bitWriter.
+type bitReader struct {
+	data  []byte
+	count int
+}
+
+// readBits reads n bits, or returns an error if there are not enough bits left.
+func (r *bitReader) readBits(n int) (uint64, error) {
+	if r.count+n > len(r.data)*8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	v := uint64(0)
+	for i := 0; i < n; i++ {
+		v = v<<1 | uint64(r.data[r.count/8]>>uint(7-r.count%8)&1)
+		r.count++
+	}
+	return v, nil
+}
+
+// SignalLogReader reads signals from a signal log without loading the whole file.
+// Only the index is read when the log is opened, and each read only fetches the chunks it needs.
+type SignalLogReader struct {
+	r      io.ReaderAt
+	series []SignalLogSeries
+	chunks []signalLogChunk
+}
+
+// OpenSignalLogFile opens the signal log file at the given path. The reader must be closed after use.
+func OpenSignalLogFile(path string) (*SignalLogReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	reader, err := OpenSignalLog(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// OpenSignalLog reads the index of a signal log of the given size.
+// It returns an error if the data is not a signal log or the index is corrupted.
+func OpenSignalLog(r io.ReaderAt, size int64) (*SignalLogReader, error) {
+	headerSize := int64(len(signalLogMagic) + 1)
+	if size < headerSize+int64(signalLogTrailerSize) {
+		return nil, fmt.Errorf("invalid signal log, expected at least %d bytes, got %d", headerSize+int64(signalLogTrailerSize), size)
+	}
+	header := make([]byte, headerSize)
+	trailer := make([]byte, signalLogTrailerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	} else if _, err := r.ReadAt(trailer, size-int64(signalLogTrailerSize)); err != nil {
+		return nil, err
+	}
+	if string(header[:len(signalLogMagic)]) != signalLogMagic || string(trailer[8:]) != signalLogMagic {
+		return nil, fmt.Errorf("invalid signal log, missing magic")
+	} else if header[len(signalLogMagic)] != signalLogVersion {
+		return nil, fmt.Errorf("unsupported signal log version %d", header[len(signalLogMagic)])
+	}
+	indexOffset := int64(binary.BigEndian.Uint64(trailer))
+	if indexOffset < headerSize || indexOffset > size-int64(signalLogTrailerSize) {
+		return nil, fmt.Errorf("invalid signal log index offset %d", indexOffset)
+	}
+	index := make([]byte, size-int64(signalLogTrailerSize)-indexOffset)
+	if _, err := r.ReadAt(index, indexOffset); err != nil {
+		return nil, err
+	}
+
+	reader := &SignalLogReader{r: r}
+	if err := reader.parseIndex(index, indexOffset); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// parseIndex parses the series and chunks of the index.
+func (l *SignalLogReader) parseIndex(index []byte, indexOffset int64) error {
+	invalid := fmt.Errorf("invalid signal log index")
+	uvarint := func() (uint64, bool) {
+		v, n := binary.Uvarint(index)
+		if n <= 0 {
+			return 0, false
+		}
+		index = index[n:]
+		return v, true
+	}
+	varint := func() (int64, bool) {
+		v, n := binary.Varint(index)
+		if n <= 0 {
+			return 0, false
+		}
+		index = index[n:]
+		return v, true
+	}
+	str := func() (string, bool) {
+		length, ok := uvarint()
+		if !ok || uint64(len(index)) < length {
+			return "", false
+		}
+		s := string(index[:length])
+		index = index[length:]
+		return s, true
+	}
+
+	seriesCount, ok := uvarint()
+	if !ok || seriesCount > uint64(len(index)) {
+		return invalid
+	}
+	l.series = make([]SignalLogSeries, seriesCount)
+	for i := range l.series {
+		vehicleID, ok1 := str()
+		name, ok2 := str()
+		if !ok1 || !ok2 {
+			return invalid
+		}
+		l.series[i] = SignalLogSeries{VehicleID: vehicleID, Name: name}
+	}
+	chunkCount, ok := uvarint()
+	if !ok || chunkCount > uint64(len(index)) {
+		return invalid
+	}
+	l.chunks = make([]signalLogChunk, chunkCount)
+	for i := range l.chunks {
+		series, ok1 := uvarint()
+		offset, ok2 := uvarint()
+		length, ok3 := uvarint()
+		count, ok4 := uvarint()
+		start, ok5 := varint()
+		end, ok6 := varint()
+		if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || !ok6 || len(index) < 4 {
+			return invalid
+		} else if series >= seriesCount || count == 0 || offset+length > uint64(indexOffset) || count > length*8 {
+			return invalid
+		}
+		chunk := signalLogChunk{
+			series: int(series),
+			offset: int64(offset),
+			length: int(length),
+			count:  int(count),
+			start:  int(start),
+			end:    int(end),
+			crc:    binary.BigEndian.Uint32(index),
+		}
+		index = index[4:]
+		l.chunks[i] = chunk
+		s := &l.series[chunk.series]
+		if s.Count == 0 || chunk.start < s.Start {
+			s.Start = chunk.start
+		}
+		if s.Count == 0 || chunk.end > s.End {
+			s.End = chunk.end
+		}
+		s.Count += chunk.count
+	}
+	return nil
+}
+
+// Series returns the series stored in the log, ordered by VehicleID and Name.
+func (l *SignalLogReader) Series() []SignalLogSeries {
+	return append([]SignalLogSeries{}, l.series...)
+}
+
+// Read returns the points of a signal with a Timestamp between start and end (inclusive), in Unix microseconds.
+// Only the chunks overlapping the time window are read and decoded.
+// It returns an empty list if the log has no such signal, or an error if a chunk is corrupted.
+func (l *SignalLogReader) Read(vehicleID string, name string, start int, end int) ([]Signal, error) {
+	signals := []Signal{}
+	for _, chunk := range l.chunks {
+		series := l.series[chunk.series]
+		if series.VehicleID != vehicleID || series.Name != name || chunk.end < start || chunk.start > end {
+			continue
+		}
+		points, err := l.readChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, point := range points {
+			if point.Timestamp >= start && point.Timestamp <= end {
+				signals = append(signals, point)
+			}
+		}
+	}
+	return signals, nil
+}
+
+// ReadAll returns every point of the log, ordered by VehicleID, Name, and Timestamp.
+func (l *SignalLogReader) ReadAll() ([]Signal, error) {
+	signals := []Signal{}
+	for _, chunk := range l.chunks {
+		points, err := l.readChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		signals = append(signals, points...)
+	}
+	return signals, nil
+}
+
+// readChunk reads, checks, and decodes a chunk.
+func (l *SignalLogReader) readChunk(chunk signalLogChunk) ([]Signal, error) {
+	series := l.series[chunk.series]
+	data := make([]byte, chunk.length)
+	if _, err := l.r.ReadAt(data, chunk.offset); err != nil {
+		return nil, err
+	} else if crc32.ChecksumIEEE(data) != chunk.crc {
+		return nil, fmt.Errorf("invalid signal log chunk for %s, checksum mismatch", series.Name)
+	}
+	return decodeSignalLogChunk(data, chunk.count, series.VehicleID, series.Name)
+}
+
+// Close closes the underlying reader if it is an io.Closer, such as the file opened by OpenSignalLogFile.
+func (l *SignalLogReader) Close() error {
+	if closer, ok := l.r.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
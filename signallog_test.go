@@ -0,0 +1,215 @@
+package mapache
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testSignalLogSignals returns signals of two vehicles, sampled at 100 Hz with some jitter.
+func testSignalLogSignals(count int) []Signal {
+	signals := []Signal{}
+	start := 1697040000000000
+	for i := 0; i < count; i++ {
+		timestamp := start + i*10000 + (i%7)*13
+		raw := int(1000 * math.Sin(float64(i)/50))
+		signals = append(signals,
+			Signal{Timestamp: timestamp, VehicleID: "gr24", Name: "ecu_motor_temp", Value: float64(raw)*0.1 - 40, RawValue: raw},
+			Signal{Timestamp: timestamp, VehicleID: "gr24", Name: "ecu_state", Value: float64(i / 500), RawValue: i / 500},
+			Signal{Timestamp: timestamp + 5, VehicleID: "gr23", Name: "ecu_state", Value: 3, RawValue: 3},
+		)
+	}
+	for i := range signals {
+		signals[i].ProducedAt = time.UnixMicro(int64(signals[i].Timestamp))
+	}
+	return signals
+}
+
+// filterSignals returns the signals of a series within a time window.
+func filterSignals(signals []Signal, vehicleID string, name string, start int, end int) []Signal {
+	filtered := []Signal{}
+	for _, signal := range signals {
+		if signal.VehicleID == vehicleID && signal.Name == name && signal.Timestamp >= start && signal.Timestamp <= end {
+			filtered = append(filtered, signal)
+		}
+	}
+	return filtered
+}
+
+// countingReaderAt counts the bytes read from a ReaderAt.
+type countingReaderAt struct {
+	r     *bytes.Reader
+	bytes int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.bytes += len(p)
+	return c.r.ReadAt(p, off)
+}
+
+func TestSignalLog(t *testing.T) {
+	signals := testSignalLogSignals(3000)
+	var buf bytes.Buffer
+	if err := WriteSignalLog(&buf, signals); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	reader, err := OpenSignalLog(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	t.Run("Test series", func(t *testing.T) {
+		expected := []SignalLogSeries{
+			{VehicleID: "gr23", Name: "ecu_state", Count: 3000, Start: 1697040000000005, End: 1697040029990000 + 3*13 + 5},
+			{VehicleID: "gr24", Name: "ecu_motor_temp", Count: 3000, Start: 1697040000000000, End: 1697040029990000 + 3*13},
+			{VehicleID: "gr24", Name: "ecu_state", Count: 3000, Start: 1697040000000000, End: 1697040029990000 + 3*13},
+		}
+		if !reflect.DeepEqual(reader.Series(), expected) {
+			t.Errorf("Expected %+v, got %+v", expected, reader.Series())
+		}
+	})
+	t.Run("Test read all", func(t *testing.T) {
+		all, err := reader.ReadAll()
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		expected := append(append(filterSignals(signals, "gr23", "ecu_state", 0, math.MaxInt),
+			filterSignals(signals, "gr24", "ecu_motor_temp", 0, math.MaxInt)...),
+			filterSignals(signals, "gr24", "ecu_state", 0, math.MaxInt)...)
+		if !reflect.DeepEqual(all, expected) {
+			t.Errorf("Expected %d signals to round trip, got %d", len(expected), len(all))
+		}
+	})
+	t.Run("Test read window", func(t *testing.T) {
+		start, end := 1697040012000000, 1697040013000000
+		window, err := reader.Read("gr24", "ecu_motor_temp", start, end)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		expected := filterSignals(signals, "gr24", "ecu_motor_temp", start, end)
+		if len(expected) != 100 || !reflect.DeepEqual(window, expected) {
+			t.Errorf("Expected %+v, got %+v", expected, window)
+		}
+	})
+	t.Run("Test read only needed chunks", func(t *testing.T) {
+		counter := &countingReaderAt{r: bytes.NewReader(buf.Bytes())}
+		reader, _ := OpenSignalLog(counter, int64(buf.Len()))
+		counter.bytes = 0
+		reader.Read("gr24", "ecu_motor_temp", 0, math.MaxInt)
+		seriesBytes := counter.bytes
+		counter.bytes = 0
+		reader.Read("gr24", "ecu_motor_temp", 1697040000000000, 1697040001000000)
+		if counter.bytes == 0 || counter.bytes*2 > seriesBytes {
+			t.Errorf("Expected a single chunk to be read, read %d of %d bytes", counter.bytes, seriesBytes)
+		}
+	})
+	t.Run("Test unknown signal", func(t *testing.T) {
+		window, err := reader.Read("gr24", "ecu_speed", 0, math.MaxInt)
+		if err != nil || len(window) != 0 {
+			t.Errorf("Expected no signals, got %+v (%v)", window, err)
+		}
+	})
+	t.Run("Test compression", func(t *testing.T) {
+		encoded, _ := json.Marshal(signals)
+		if buf.Len()*20 > len(encoded) {
+			t.Errorf("Expected at least 20x smaller than json, got %d vs %d bytes", buf.Len(), len(encoded))
+		}
+	})
+}
+
+func TestSignalLogValues(t *testing.T) {
+	values := []float64{0, 1, -1, 0.1, math.Pi, math.Inf(1), math.Inf(-1), math.MaxFloat64, math.SmallestNonzeroFloat64, 1e-300, -0.0, 12345.678}
+	timestamps := []int{-5, 0, 1, 1, 2, 100, 1 << 40, 1<<40 + 1, 1 << 41, 1<<41 + 3, 1<<62 + 7, 1<<62 + 7}
+	raws := []int{0, math.MaxInt64, math.MinInt64, -1, 1, 0, 42, -42, 1 << 50, 0, 7, 7}
+	signals := []Signal{}
+	for i := range values {
+		signals = append(signals, Signal{
+			Timestamp:  timestamps[i],
+			Name:       "edge",
+			Value:      values[i],
+			RawValue:   raws[i],
+			ProducedAt: time.UnixMicro(int64(timestamps[i])),
+		})
+	}
+	var buf bytes.Buffer
+	WriteSignalLog(&buf, signals)
+	reader, err := OpenSignalLog(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	all, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if !reflect.DeepEqual(all, signals) {
+		t.Errorf("Expected %+v, got %+v", signals, all)
+	}
+	t.Run("Test nan", func(t *testing.T) {
+		var buf bytes.Buffer
+		WriteSignalLog(&buf, []Signal{{Name: "nan", Value: math.NaN()}, {Name: "nan", Timestamp: 1, Value: 1}})
+		reader, _ := OpenSignalLog(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		all, err := reader.ReadAll()
+		if err != nil || !math.IsNaN(all[0].Value) || all[1].Value != 1 {
+			t.Errorf("Unexpected signals %+v (%v)", all, err)
+		}
+	})
+}
+
+func TestSignalLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "endurance.mslg")
+	signals := testSignalLogSignals(10)
+	if err := WriteSignalLogFile(path, signals); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	reader, err := OpenSignalLogFile(path)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	defer reader.Close()
+	window, err := reader.Read("gr23", "ecu_state", 0, math.MaxInt)
+	if err != nil || !reflect.DeepEqual(window, filterSignals(signals, "gr23", "ecu_state", 0, math.MaxInt)) {
+		t.Errorf("Unexpected signals %+v (%v)", window, err)
+	}
+}
+
+func TestSignalLogInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	WriteSignalLog(&buf, testSignalLogSignals(10))
+	data := buf.Bytes()
+	t.Run("Test empty log", func(t *testing.T) {
+		var buf bytes.Buffer
+		WriteSignalLog(&buf, nil)
+		reader, err := OpenSignalLog(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil || len(reader.Series()) != 0 {
+			t.Errorf("Unexpected reader %+v (%v)", reader, err)
+		}
+	})
+	t.Run("Test not a log", func(t *testing.T) {
+		for _, invalid := range [][]byte{[]byte("MSLG"), bytes.Repeat([]byte{0x01}, 64), append([]byte("MSLG\x02"), data[5:]...)} {
+			if _, err := OpenSignalLog(bytes.NewReader(invalid), int64(len(invalid))); err == nil {
+				t.Errorf("Expected error for % X, got nil", invalid)
+			}
+		}
+	})
+	t.Run("Test corrupted chunk", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[10] ^= 0xFF
+		reader, err := OpenSignalLog(bytes.NewReader(corrupted), int64(len(corrupted)))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if _, err := reader.ReadAll(); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+	t.Run("Test corrupted index", func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		copy(corrupted[len(corrupted)-12:], []byte{0, 0, 0, 0, 0, 0, 0xFF, 0xFF})
+		if _, err := OpenSignalLog(bytes.NewReader(corrupted), int64(len(corrupted))); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package mapache
+
+import (
+	"sort"
+	"time"
+)
+
+// SignalLayout selects how signals are arranged into the rows and columns of a SignalTable.
+type SignalLayout int
+
+const (
+	// LongLayout has one row per signal, with timestamp, vehicle_id, name, value, and raw_value columns.
+	LongLayout SignalLayout = 0
+	// WideLayout has one row per vehicle and timestamp, with timestamp and vehicle_id columns followed by
+	// one column per signal name holding its value. Signals missing at a timestamp are null.
+	WideLayout SignalLayout = 1
+)
+
+// ColumnType is the type of the values of a SignalColumn.
+type ColumnType int
+
+const (
+	// TimestampColumn holds Unix microseconds in Ints.
+	TimestampColumn ColumnType = 0
+	// IntColumn holds 64-bit integers in Ints.
+	IntColumn ColumnType = 1
+	// FloatColumn holds 64-bit floating point numbers in Floats.
+	FloatColumn ColumnType = 2
+	// StringColumn holds strings in Strings.
+	StringColumn ColumnType = 3
+)
+
+// SignalColumn is a single column of a SignalTable.
+// Only the values slice matching Type is set, and it holds a value for every row, even null ones.
+type SignalColumn struct {
+	Name    string
+	Type    ColumnType
+	Ints    []int64
+	Floats  []float64
+	Strings []string
+	// Valid marks which rows are not null. It is nil if the column has no null values.
+	Valid []bool
+}
+
+// IsValid returns whether the value of the column at the given row is not null.
+func (c SignalColumn) IsValid(row int) bool {
+	return c.Valid == nil || c.Valid[row]
+}
+
+// NullCount returns the number of null values in the column.
+func (c SignalColumn) NullCount() int {
+	count := 0
+	for _, valid := range c.Valid {
+		if !valid {
+			count++
+		}
+	}
+	return count
+}
+
+// SignalTable is a columnar table of signals, used to export them to columnar formats such as Arrow and Parquet.
+type SignalTable struct {
+	Rows    int
+	Columns []SignalColumn
+}
+
+// Column returns the column with the given name.
+func (t SignalTable) Column(name string) (SignalColumn, bool) {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return SignalColumn{}, false
+}
+
+// NewSignalTable arranges the signals into a table with the given layout.
+// Rows of the long layout keep the order of the signals, while rows of the wide layout are sorted by timestamp
+// and vehicle, and its signal columns are sorted by name. If the same signal appears more than once at a
+// timestamp in the wide layout, the last one is kept.
+func NewSignalTable(signals []Signal, layout SignalLayout) SignalTable {
+	if layout == WideLayout {
+		return newWideSignalTable(signals)
+	}
+	table := SignalTable{
+		Rows: len(signals),
+		Columns: []SignalColumn{
+			{Name: "timestamp", Type: TimestampColumn, Ints: make([]int64, len(signals))},
+			{Name: "vehicle_id", Type: StringColumn, Strings: make([]string, len(signals))},
+			{Name: "name", Type: StringColumn, Strings: make([]string, len(signals))},
+			{Name: "value", Type: FloatColumn, Floats: make([]float64, len(signals))},
+			{Name: "raw_value", Type: IntColumn, Ints: make([]int64, len(signals))},
+		},
+	}
+	for i, signal := range signals {
+		table.Columns[0].Ints[i] = int64(signal.Timestamp)
+		table.Columns[1].Strings[i] = signal.VehicleID
+		table.Columns[2].Strings[i] = signal.Name
+		table.Columns[3].Floats[i] = signal.Value
+		table.Columns[4].Ints[i] = int64(signal.RawValue)
+	}
+	return table
+}
+
+// wideRow identifies a row of the wide layout.
+type wideRow struct {
+	timestamp int
+	vehicleID string
+}
+
+// signalSeries identifies a signal of a vehicle.
+type signalSeries struct {
+	vehicleID string
+	name      string
+}
+
+func newWideSignalTable(signals []Signal) SignalTable {
+	rowIndex := map[wideRow]int{}
+	rows := []wideRow{}
+	columnIndex := map[string]int{}
+	names := []string{}
+	for _, signal := range signals {
+		row := wideRow{signal.Timestamp, signal.VehicleID}
+		if _, ok := rowIndex[row]; !ok {
+			rowIndex[row] = len(rows)
+			rows = append(rows, row)
+		}
+		if _, ok := columnIndex[signal.Name]; !ok {
+			columnIndex[signal.Name] = len(names)
+			names = append(names, signal.Name)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].timestamp != rows[j].timestamp {
+			return rows[i].timestamp < rows[j].timestamp
+		}
+		return rows[i].vehicleID < rows[j].vehicleID
+	})
+	for i, row := range rows {
+		rowIndex[row] = i
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		columnIndex[name] = i + 2
+	}
+
+	table := SignalTable{
+		Rows: len(rows),
+		Columns: []SignalColumn{
+			{Name: "timestamp", Type: TimestampColumn, Ints: make([]int64, len(rows))},
+			{Name: "vehicle_id", Type: StringColumn, Strings: make([]string, len(rows))},
+		},
+	}
+	for i, row := range rows {
+		table.Columns[0].Ints[i] = int64(row.timestamp)
+		table.Columns[1].Strings[i] = row.vehicleID
+	}
+	for _, name := range names {
+		table.Columns = append(table.Columns, SignalColumn{
+			Name:   name,
+			Type:   FloatColumn,
+			Floats: make([]float64, len(rows)),
+			Valid:  make([]bool, len(rows)),
+		})
+	}
+	for _, signal := range signals {
+		row := rowIndex[wideRow{signal.Timestamp, signal.VehicleID}]
+		column := table.Columns[columnIndex[signal.Name]]
+		column.Floats[row] = signal.Value
+		column.Valid[row] = true
+	}
+	return table
+}
+
+// MessageRecord is a decoded Message along with the vehicle and time it was produced at.
+type MessageRecord struct {
+	VehicleID  string
+	ProducedAt time.Time
+	Message    Message
+}
+
+// Signals returns the exported Signals of the record's Message, stamped with its vehicle and time.
+func (r MessageRecord) Signals() []Signal {
+	signals := r.Message.ExportSignals()
+	for i := range signals {
+		signals[i].Timestamp = int(r.ProducedAt.UnixMicro())
+		signals[i].VehicleID = r.VehicleID
+		signals[i].ProducedAt = r.ProducedAt
+	}
+	return signals
+}
+
+// NewMessageTable arranges the signals of a batch of decoded messages into a table with the given layout.
+func NewMessageTable(records []MessageRecord, layout SignalLayout) SignalTable {
+	signals := []Signal{}
+	for _, record := range records {
+		signals = append(signals, record.Signals()...)
+	}
+	return NewSignalTable(signals, layout)
+}
@@ -0,0 +1,90 @@
+package mapache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testTableSignals() []Signal {
+	return []Signal{
+		{Timestamp: 2000, VehicleID: "gr24", Name: "ecu_speed", Value: 12.5, RawValue: 125},
+		{Timestamp: 1000, VehicleID: "gr24", Name: "ecu_speed", Value: 10, RawValue: 100},
+		{Timestamp: 1000, VehicleID: "gr24", Name: "acu_temp", Value: 30, RawValue: 30},
+		{Timestamp: 1000, VehicleID: "gr23", Name: "ecu_speed", Value: 5, RawValue: 50},
+		{Timestamp: 2000, VehicleID: "gr24", Name: "ecu_speed", Value: 13, RawValue: 130},
+	}
+}
+
+func TestNewSignalTable(t *testing.T) {
+	t.Run("Test long", func(t *testing.T) {
+		table := NewSignalTable(testTableSignals(), LongLayout)
+		if table.Rows != 5 || len(table.Columns) != 5 {
+			t.Fatalf("Expected 5 rows and 5 columns, got %d and %d", table.Rows, len(table.Columns))
+		}
+		names := []string{}
+		for _, column := range table.Columns {
+			names = append(names, column.Name)
+			if column.Valid != nil || column.NullCount() != 0 {
+				t.Errorf("Expected column %s without nulls", column.Name)
+			}
+		}
+		if !reflect.DeepEqual(names, []string{"timestamp", "vehicle_id", "name", "value", "raw_value"}) {
+			t.Errorf("Unexpected columns %v", names)
+		}
+		if !reflect.DeepEqual(table.Columns[0].Ints, []int64{2000, 1000, 1000, 1000, 2000}) ||
+			!reflect.DeepEqual(table.Columns[2].Strings, []string{"ecu_speed", "ecu_speed", "acu_temp", "ecu_speed", "ecu_speed"}) ||
+			!reflect.DeepEqual(table.Columns[3].Floats, []float64{12.5, 10, 30, 5, 13}) ||
+			!reflect.DeepEqual(table.Columns[4].Ints, []int64{125, 100, 30, 50, 130}) {
+			t.Errorf("Unexpected table %+v", table)
+		}
+	})
+	t.Run("Test wide", func(t *testing.T) {
+		table := NewSignalTable(testTableSignals(), WideLayout)
+		if table.Rows != 3 || len(table.Columns) != 4 {
+			t.Fatalf("Expected 3 rows and 4 columns, got %d and %d", table.Rows, len(table.Columns))
+		}
+		if !reflect.DeepEqual(table.Columns[0].Ints, []int64{1000, 1000, 2000}) ||
+			!reflect.DeepEqual(table.Columns[1].Strings, []string{"gr23", "gr24", "gr24"}) {
+			t.Errorf("Unexpected rows %+v", table.Columns[:2])
+		}
+		temp, speed := table.Columns[2], table.Columns[3]
+		if temp.Name != "acu_temp" || speed.Name != "ecu_speed" {
+			t.Errorf("Expected columns sorted by name, got %s and %s", temp.Name, speed.Name)
+		}
+		if !reflect.DeepEqual(speed.Floats, []float64{5, 10, 13}) || speed.NullCount() != 0 {
+			t.Errorf("Unexpected column %+v", speed)
+		}
+		if !reflect.DeepEqual(temp.Valid, []bool{false, true, false}) || temp.Floats[1] != 30 || temp.NullCount() != 2 {
+			t.Errorf("Unexpected column %+v", temp)
+		}
+	})
+	t.Run("Test empty", func(t *testing.T) {
+		for _, layout := range []SignalLayout{LongLayout, WideLayout} {
+			if table := NewSignalTable(nil, layout); table.Rows != 0 || len(table.Columns) < 2 {
+				t.Errorf("Unexpected table %+v", table)
+			}
+		}
+	})
+}
+
+func TestNewMessageTable(t *testing.T) {
+	message := Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil),
+		NewField("ecu_speed", 2, Unsigned, BigEndian, nil).WithScale(0.1, 0, "m/s"),
+	}
+	records := []MessageRecord{}
+	for i := 0; i < 3; i++ {
+		copied := message.Copy()
+		copied.FillFromInts([]int{i, 100 * i})
+		records = append(records, MessageRecord{VehicleID: "gr24", ProducedAt: time.UnixMicro(int64(1000 * i)), Message: copied})
+	}
+	signals := records[2].Signals()
+	if len(signals) != 2 || signals[1].Timestamp != 2000 || signals[1].VehicleID != "gr24" || !signals[1].ProducedAt.Equal(time.UnixMicro(2000)) {
+		t.Errorf("Unexpected signals %+v", signals)
+	}
+	table := NewMessageTable(records, WideLayout)
+	if table.Rows != 3 || table.Columns[2].Name != "ecu_speed" || !reflect.DeepEqual(table.Columns[2].Floats, []float64{0, 10, 20}) {
+		t.Errorf("Unexpected table %+v", table)
+	}
+}
@@ -0,0 +1,341 @@
+package mapache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+// telemetryVersion is the version of the telemetry frame header.
+const telemetryVersion = 1
+
+// telemetryHeaderSize is the size of the fixed part of the telemetry frame header:
+// version, checksum, sequence (2 bytes), message ID (4 bytes), timestamp (8 bytes), and vehicle ID length.
+const telemetryHeaderSize = 17
+
+// MaxTelemetryFrameSize is the largest encoded telemetry frame accepted by a TelemetryDecoder.
+// Longer runs of bytes without a delimiter are discarded as corrupted.
+const MaxTelemetryFrameSize = 4096
+
+// MaxTelemetrySequenceGap is the largest jump in sequence numbers counted as dropped frames by a TelemetryDecoder.
+// Larger jumps, including any backward jump such as an encoder restarting at 0, are counted as resyncs instead.
+const MaxTelemetrySequenceGap = 1024
+
+// Checksum is a type to represent the integrity check appended to each telemetry frame.
+type Checksum int
+
+const (
+	// CRC16 is CRC-16/CCITT-FALSE (polynomial 0x1021, initial value 0xFFFF), stored in 2 bytes.
+	CRC16 Checksum = 0
+	// CRC32 is the IEEE CRC-32 used by Ethernet and zlib, stored in 4 bytes.
+	CRC32 Checksum = 1
+)
+
+// size returns the number of bytes of the checksum.
+func (c Checksum) size() int {
+	if c == CRC32 {
+		return 4
+	}
+	return 2
+}
+
+// sum returns the checksum of data.
+func (c Checksum) sum(data []byte) uint32 {
+	if c == CRC32 {
+		return crc32.ChecksumIEEE(data)
+	}
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return uint32(crc)
+}
+
+// TelemetryFrame is a raw message sent over the car-to-pit telemetry link.
+type TelemetryFrame struct {
+	// Sequence is incremented by the encoder for every frame, so the decoder can count dropped frames.
+	Sequence int
+	// ID is the ID of the message.
+	ID int
+	// VehicleID hints which vehicle sent the frame (see Vehicle.ID), and may be empty to save bandwidth.
+	VehicleID string
+	// Timestamp is the Unix microseconds at which the message was produced.
+	Timestamp int
+	Data      []byte
+}
+
+// NewTelemetryFrame creates a new TelemetryFrame holding the encoded bytes of the message.
+// It returns an error if the message cannot be encoded (see Message.Bytes).
+func NewTelemetryFrame(id int, vehicleID string, producedAt time.Time, message Message) (TelemetryFrame, error) {
+	data, err := message.Bytes()
+	if err != nil {
+		return TelemetryFrame{}, err
+	}
+	return TelemetryFrame{
+		ID:        id,
+		VehicleID: vehicleID,
+		Timestamp: int(producedAt.UnixMicro()),
+		Data:      data,
+	}, nil
+}
+
+// Signals decodes the frame into Signals using the given Message,
+// with Timestamp, ProducedAt, and VehicleID set from the frame header.
+// See Message.DecodeSignals for how the data length is handled.
+func (f TelemetryFrame) Signals(message Message) ([]Signal, error) {
+	signals, err := message.DecodeSignals(f.Data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range signals {
+		signals[i].Timestamp = f.Timestamp
+		signals[i].VehicleID = f.VehicleID
+		signals[i].ProducedAt = time.UnixMicro(int64(f.Timestamp))
+	}
+	return signals, nil
+}
+
+// MarshalTelemetryFrame encodes a frame into its header, data, and checksum, stuffed with COBS and
+// terminated by a zero byte, ready to be written to a serial or radio link.
+// It returns an error if the ID does not fit in 32 bits or the vehicle ID is longer than 255 bytes.
+func MarshalTelemetryFrame(f TelemetryFrame, checksum Checksum) ([]byte, error) {
+	if f.ID < 0 || int64(f.ID) > 0xFFFFFFFF {
+		return nil, fmt.Errorf("invalid telemetry message id %d", f.ID)
+	} else if len(f.VehicleID) > 0xFF {
+		return nil, fmt.Errorf("invalid telemetry vehicle id %q, expected at most 255 bytes", f.VehicleID)
+	} else if checksum != CRC16 && checksum != CRC32 {
+		return nil, fmt.Errorf("invalid telemetry checksum %d", checksum)
+	}
+	raw := make([]byte, telemetryHeaderSize, telemetryHeaderSize+len(f.VehicleID)+len(f.Data)+checksum.size())
+	raw[0] = telemetryVersion
+	raw[1] = byte(checksum)
+	binary.BigEndian.PutUint16(raw[2:4], uint16(f.Sequence))
+	binary.BigEndian.PutUint32(raw[4:8], uint32(f.ID))
+	binary.BigEndian.PutUint64(raw[8:16], uint64(f.Timestamp))
+	raw[16] = byte(len(f.VehicleID))
+	raw = append(raw, f.VehicleID...)
+	raw = append(raw, f.Data...)
+	if checksum == CRC32 {
+		raw = binary.BigEndian.AppendUint32(raw, checksum.sum(raw))
+	} else {
+		raw = binary.BigEndian.AppendUint16(raw, uint16(checksum.sum(raw)))
+	}
+	return append(EncodeCOBS(raw), 0x00), nil
+}
+
+// UnmarshalTelemetryFrame decodes a frame encoded by MarshalTelemetryFrame, with or without its zero terminator.
+// It returns an error if the frame is not valid COBS, the header is invalid, or the checksum does not match.
+func UnmarshalTelemetryFrame(data []byte) (TelemetryFrame, error) {
+	if len(data) > 0 && data[len(data)-1] == 0x00 {
+		data = data[:len(data)-1]
+	}
+	raw, err := DecodeCOBS(data)
+	if err != nil {
+		return TelemetryFrame{}, err
+	} else if len(raw) < telemetryHeaderSize {
+		return TelemetryFrame{}, fmt.Errorf("invalid telemetry frame, expected at least %d bytes, got %d", telemetryHeaderSize, len(raw))
+	} else if raw[0] != telemetryVersion {
+		return TelemetryFrame{}, fmt.Errorf("invalid telemetry frame version %d", raw[0])
+	}
+	checksum := Checksum(raw[1])
+	if checksum != CRC16 && checksum != CRC32 {
+		return TelemetryFrame{}, fmt.Errorf("invalid telemetry checksum %d", raw[1])
+	}
+	vehicleEnd := telemetryHeaderSize + int(raw[16])
+	if len(raw) < vehicleEnd+checksum.size() {
+		return TelemetryFrame{}, fmt.Errorf("invalid telemetry frame, expected at least %d bytes, got %d", vehicleEnd+checksum.size(), len(raw))
+	}
+	body := raw[:len(raw)-checksum.size()]
+	sum := uint32(binary.BigEndian.Uint16(raw[len(body):]))
+	if checksum == CRC32 {
+		sum = binary.BigEndian.Uint32(raw[len(body):])
+	}
+	if expected := checksum.sum(body); sum != expected {
+		return TelemetryFrame{}, fmt.Errorf("invalid telemetry checksum 0x%X, expected 0x%X", sum, expected)
+	}
+	return TelemetryFrame{
+		Sequence:  int(binary.BigEndian.Uint16(raw[2:4])),
+		ID:        int(binary.BigEndian.Uint32(raw[4:8])),
+		Timestamp: int(int64(binary.BigEndian.Uint64(raw[8:16]))),
+		VehicleID: string(raw[telemetryHeaderSize:vehicleEnd]),
+		Data:      append([]byte{}, body[vehicleEnd:]...),
+	}, nil
+}
+
+// EncodeCOBS stuffs data with Consistent Overhead Byte Stuffing, so that the result contains no zero bytes
+// and a zero byte can be used to delimit frames. The delimiter is not appended.
+func EncodeCOBS(data []byte) []byte {
+	encoded := make([]byte, 1, len(data)+len(data)/254+2)
+	code := 0
+	for _, b := range data {
+		if b == 0x00 {
+			encoded[code] = byte(len(encoded) - code)
+			code = len(encoded)
+			encoded = append(encoded, 0)
+			continue
+		}
+		encoded = append(encoded, b)
+		if len(encoded)-code == 0xFF {
+			encoded[code] = 0xFF
+			code = len(encoded)
+			encoded = append(encoded, 0)
+		}
+	}
+	encoded[code] = byte(len(encoded) - code)
+	return encoded
+}
+
+// DecodeCOBS reverses EncodeCOBS. The data must not include the zero delimiter.
+// It returns an error if the data contains a zero byte or a code points past the end of the data.
+func DecodeCOBS(data []byte) ([]byte, error) {
+	decoded := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		code := int(data[i])
+		if code == 0 {
+			return nil, fmt.Errorf("invalid cobs data, unexpected zero byte at %d", i)
+		} else if i+code > len(data) {
+			return nil, fmt.Errorf("invalid cobs data, code %d at %d exceeds %d bytes", code, i, len(data))
+		}
+		for _, b := range data[i+1 : i+code] {
+			if b == 0x00 {
+				return nil, fmt.Errorf("invalid cobs data, unexpected zero byte")
+			}
+			decoded = append(decoded, b)
+		}
+		i += code
+		if code < 0xFF && i < len(data) {
+			decoded = append(decoded, 0x00)
+		}
+	}
+	return decoded, nil
+}
+
+// TelemetryEncoder writes telemetry frames to a stream, numbering them in sequence.
+type TelemetryEncoder struct {
+	w        io.Writer
+	checksum Checksum
+	sequence int
+}
+
+// NewTelemetryEncoder creates a new TelemetryEncoder that writes to w with the given checksum.
+func NewTelemetryEncoder(w io.Writer, checksum Checksum) *TelemetryEncoder {
+	return &TelemetryEncoder{w: w, checksum: checksum}
+}
+
+// Encode writes a frame to the stream, overwriting its Sequence with the next sequence number.
+func (e *TelemetryEncoder) Encode(f TelemetryFrame) error {
+	f.Sequence = e.sequence
+	data, err := MarshalTelemetryFrame(f, e.checksum)
+	if err != nil {
+		return err
+	}
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	e.sequence = (e.sequence + 1) & 0xFFFF
+	return nil
+}
+
+// TelemetryStats counts the frames seen by a TelemetryDecoder.
+type TelemetryStats struct {
+	// Frames is the number of valid frames decoded.
+	Frames int
+	// Corrupted is the number of invalid frames discarded, such as frames with a checksum mismatch.
+	Corrupted int
+	// Dropped is the number of frames missing from the sequence, whether they were lost or corrupted.
+	Dropped int
+	// Resyncs is the number of jumps in the sequence larger than MaxTelemetrySequenceGap, which are not
+	// counted as Dropped since the encoder was most likely restarted.
+	Resyncs int
+}
+
+// TelemetryDecoder reads telemetry frames from a lossy stream.
+// Corrupted frames are skipped by resynchronizing on the next zero delimiter, so a single bad byte
+// only loses the frame it belongs to.
+type TelemetryDecoder struct {
+	r        *bufio.Reader
+	stats    TelemetryStats
+	sequence int
+	started  bool
+}
+
+// NewTelemetryDecoder creates a new TelemetryDecoder that reads from r.
+func NewTelemetryDecoder(r io.Reader) *TelemetryDecoder {
+	return &TelemetryDecoder{r: bufio.NewReader(r)}
+}
+
+// errTelemetryFrameTooLong is returned by readFrame when no delimiter is found within MaxTelemetryFrameSize bytes.
+var errTelemetryFrameTooLong = errors.New("telemetry frame too long")
+
+// Next returns the next valid frame of the stream, skipping and counting corrupted frames.
+// It returns io.EOF at the end of the stream. A trailing frame without a delimiter is counted as corrupted.
+func (d *TelemetryDecoder) Next() (TelemetryFrame, error) {
+	for {
+		data, err := d.readFrame()
+		if err == errTelemetryFrameTooLong {
+			d.stats.Corrupted++
+			continue
+		} else if err == io.EOF {
+			if len(data) > 0 {
+				d.stats.Corrupted++
+			}
+			return TelemetryFrame{}, io.EOF
+		} else if err != nil {
+			return TelemetryFrame{}, err
+		} else if len(data) == 0 {
+			continue
+		}
+		frame, err := UnmarshalTelemetryFrame(data)
+		if err != nil {
+			d.stats.Corrupted++
+			continue
+		}
+		if gap := (frame.Sequence - d.sequence) & 0xFFFF; d.started && gap > MaxTelemetrySequenceGap {
+			d.stats.Resyncs++
+		} else if d.started {
+			d.stats.Dropped += gap
+		}
+		d.started = true
+		d.sequence = (frame.Sequence + 1) & 0xFFFF
+		d.stats.Frames++
+		return frame, nil
+	}
+}
+
+// readFrame reads the bytes up to the next zero delimiter, excluding it.
+// Frames longer than MaxTelemetryFrameSize are discarded up to their delimiter.
+func (d *TelemetryDecoder) readFrame() ([]byte, error) {
+	data := []byte{}
+	tooLong := false
+	for {
+		chunk, err := d.r.ReadSlice(0x00)
+		if !tooLong {
+			data = append(data, chunk...)
+			tooLong = len(data) > MaxTelemetryFrameSize+1
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			return data, err
+		} else if tooLong {
+			return nil, errTelemetryFrameTooLong
+		}
+		return data[:len(data)-1], nil
+	}
+}
+
+// Stats returns the number of valid, corrupted, and dropped frames seen so far.
+func (d *TelemetryDecoder) Stats() TelemetryStats {
+	return d.stats
+}
@@ -0,0 +1,233 @@
+package mapache
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCOBS(t *testing.T) {
+	long := bytes.Repeat([]byte{0x11}, 254)
+	testCases := []struct {
+		data    []byte
+		encoded []byte
+	}{
+		{[]byte{}, []byte{0x01}},
+		{[]byte{0x00}, []byte{0x01, 0x01}},
+		{[]byte{0x00, 0x00}, []byte{0x01, 0x01, 0x01}},
+		{[]byte{0x11, 0x22, 0x00, 0x33}, []byte{0x03, 0x11, 0x22, 0x02, 0x33}},
+		{[]byte{0x11, 0x22, 0x33, 0x44}, []byte{0x05, 0x11, 0x22, 0x33, 0x44}},
+		{[]byte{0x11, 0x00, 0x00, 0x00}, []byte{0x02, 0x11, 0x01, 0x01, 0x01}},
+		{long, append(append([]byte{0xFF}, long...), 0x01)},
+		{append(long, 0x00), append(append([]byte{0xFF}, long...), 0x01, 0x01)},
+		{append(append([]byte{0x00}, long...), 0x22), append(append([]byte{0x01, 0xFF}, long...), 0x02, 0x22)},
+	}
+	for _, tc := range testCases {
+		encoded := EncodeCOBS(tc.data)
+		if !bytes.Equal(encoded, tc.encoded) {
+			t.Errorf("Expected % X, got % X", tc.encoded, encoded)
+		}
+		decoded, err := DecodeCOBS(encoded)
+		if err != nil || !bytes.Equal(decoded, tc.data) {
+			t.Errorf("Expected % X, got % X (%v)", tc.data, decoded, err)
+		}
+	}
+	for _, invalid := range [][]byte{{0x00}, {0x03, 0x11}, {0x03, 0x00, 0x11}} {
+		if _, err := DecodeCOBS(invalid); err == nil {
+			t.Errorf("Expected error for % X, got nil", invalid)
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	data := []byte("123456789")
+	if sum := CRC16.sum(data); sum != 0x29B1 {
+		t.Errorf("Expected 0x29B1, got 0x%X", sum)
+	}
+	if sum := CRC32.sum(data); sum != 0xCBF43926 {
+		t.Errorf("Expected 0xCBF43926, got 0x%X", sum)
+	}
+}
+
+func testTelemetryFrame(id int) TelemetryFrame {
+	return TelemetryFrame{
+		ID:        id,
+		VehicleID: "gr24",
+		Timestamp: 1697040000123456,
+		Data:      []byte{0x03, 0x00, 0x82, 0x00},
+	}
+}
+
+func TestMarshalTelemetryFrame(t *testing.T) {
+	for _, checksum := range []Checksum{CRC16, CRC32} {
+		frame := testTelemetryFrame(0x1000)
+		frame.Sequence = 0x1234
+		data, err := MarshalTelemetryFrame(frame, checksum)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if bytes.IndexByte(data, 0x00) != len(data)-1 {
+			t.Errorf("Expected a single trailing delimiter in % X", data)
+		}
+		decoded, err := UnmarshalTelemetryFrame(data)
+		if err != nil || !reflect.DeepEqual(decoded, frame) {
+			t.Errorf("Expected %+v, got %+v (%v)", frame, decoded, err)
+		}
+	}
+	t.Run("Test empty", func(t *testing.T) {
+		data, _ := MarshalTelemetryFrame(TelemetryFrame{Data: []byte{}}, CRC16)
+		decoded, err := UnmarshalTelemetryFrame(data)
+		if err != nil || decoded.VehicleID != "" || len(decoded.Data) != 0 {
+			t.Errorf("Unexpected frame %+v (%v)", decoded, err)
+		}
+	})
+	t.Run("Test corrupted", func(t *testing.T) {
+		data, _ := MarshalTelemetryFrame(testTelemetryFrame(1), CRC32)
+		for i := 0; i < len(data)-1; i++ {
+			corrupted := append([]byte{}, data...)
+			corrupted[i] ^= 0x40
+			if _, err := UnmarshalTelemetryFrame(corrupted); err == nil {
+				t.Errorf("Expected error for corrupted byte %d, got nil", i)
+			}
+		}
+	})
+	t.Run("Test invalid", func(t *testing.T) {
+		if _, err := MarshalTelemetryFrame(TelemetryFrame{ID: -1}, CRC16); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		if _, err := MarshalTelemetryFrame(TelemetryFrame{VehicleID: string(make([]byte, 256))}, CRC16); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		if _, err := MarshalTelemetryFrame(TelemetryFrame{}, Checksum(2)); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+		if _, err := UnmarshalTelemetryFrame(EncodeCOBS([]byte{telemetryVersion, 0x00})); err == nil {
+			t.Errorf("Expected error, got nil")
+		}
+	})
+}
+
+func TestTelemetryStream(t *testing.T) {
+	var stream bytes.Buffer
+	encoder := NewTelemetryEncoder(&stream, CRC16)
+	frames := [][]byte{}
+	for i := 0; i < 6; i++ {
+		start := stream.Len()
+		if err := encoder.Encode(testTelemetryFrame(i)); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		frames = append(frames, append([]byte{}, stream.Bytes()[start:]...))
+	}
+	t.Run("Test clean", func(t *testing.T) {
+		decoder := NewTelemetryDecoder(bytes.NewReader(stream.Bytes()))
+		for i := 0; i < 6; i++ {
+			frame, err := decoder.Next()
+			if err != nil || frame.ID != i || frame.Sequence != i {
+				t.Errorf("Unexpected frame %+v (%v)", frame, err)
+			}
+		}
+		if _, err := decoder.Next(); err != io.EOF {
+			t.Errorf("Expected io.EOF, got %v", err)
+		}
+		if decoder.Stats() != (TelemetryStats{Frames: 6}) {
+			t.Errorf("Unexpected stats %+v", decoder.Stats())
+		}
+	})
+	t.Run("Test lossy", func(t *testing.T) {
+		var lossy bytes.Buffer
+		lossy.Write(frames[0][5:])                  // joined mid-frame
+		lossy.Write(frames[1])                      // intact
+		corrupted := append([]byte{}, frames[2]...) // corrupted
+		corrupted[10] ^= 0xFF
+		lossy.Write(corrupted)
+		lossy.Write(frames[3][:len(frames[3])/2]) // truncated, runs into the next frame
+		lossy.Write(frames[4])                    // intact, but glued to the truncated frame
+		lossy.Write(frames[5])                    // intact
+		decoder := NewTelemetryDecoder(&lossy)
+		ids := []int{}
+		for {
+			frame, err := decoder.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			ids = append(ids, frame.ID)
+		}
+		if !reflect.DeepEqual(ids, []int{1, 5}) {
+			t.Errorf("Expected frames [1 5], got %v", ids)
+		}
+		if decoder.Stats() != (TelemetryStats{Frames: 2, Corrupted: 3, Dropped: 3}) {
+			t.Errorf("Unexpected stats %+v", decoder.Stats())
+		}
+	})
+	t.Run("Test resync", func(t *testing.T) {
+		var restarted bytes.Buffer
+		for _, sequence := range []int{10, 11, 13, 0, 1, 5000, 5001, 0xFFFF, 0} {
+			frame := testTelemetryFrame(sequence)
+			frame.Sequence = sequence
+			data, err := MarshalTelemetryFrame(frame, CRC16)
+			if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			restarted.Write(data)
+		}
+		decoder := NewTelemetryDecoder(&restarted)
+		for {
+			if _, err := decoder.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+		}
+		// 12 is dropped, the jumps back to 0 and forward to 5000 and 0xFFFF are resyncs, and 0xFFFF wraps to 0
+		if decoder.Stats() != (TelemetryStats{Frames: 9, Dropped: 1, Resyncs: 3}) {
+			t.Errorf("Unexpected stats %+v", decoder.Stats())
+		}
+	})
+	t.Run("Test too long", func(t *testing.T) {
+		var noisy bytes.Buffer
+		noisy.Write(bytes.Repeat([]byte{0x55}, MaxTelemetryFrameSize*3))
+		noisy.WriteByte(0x00)
+		noisy.Write(frames[1])
+		decoder := NewTelemetryDecoder(&noisy)
+		frame, err := decoder.Next()
+		if err != nil || frame.ID != 1 || decoder.Stats().Corrupted != 1 {
+			t.Errorf("Unexpected frame %+v (%v), stats %+v", frame, err, decoder.Stats())
+		}
+	})
+	t.Run("Test trailing partial frame", func(t *testing.T) {
+		decoder := NewTelemetryDecoder(bytes.NewReader(frames[0][:8]))
+		if _, err := decoder.Next(); err != io.EOF || decoder.Stats().Corrupted != 1 {
+			t.Errorf("Expected io.EOF with 1 corrupted frame, got %v %+v", err, decoder.Stats())
+		}
+	})
+}
+
+func TestTelemetryFrameSignals(t *testing.T) {
+	message := Message{
+		NewField("ecu_state", 1, Unsigned, BigEndian, nil),
+		NewField("ecu_max_cell_temp", 1, Unsigned, BigEndian, nil).WithScale(0.25, 0, "degC"),
+	}
+	message.FillFromInts([]int{3, 130})
+	producedAt := time.UnixMicro(1697040000123456)
+	frame, err := NewTelemetryFrame(0x10, "gr24", producedAt, message)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	data, _ := MarshalTelemetryFrame(frame, CRC32)
+	frame, err = UnmarshalTelemetryFrame(data)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	signals, err := frame.Signals(message)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if len(signals) != 2 || signals[1].Value != 32.5 || signals[0].VehicleID != "gr24" ||
+		signals[0].Timestamp != 1697040000123456 || !signals[0].ProducedAt.Equal(producedAt) {
+		t.Errorf("Unexpected signals %+v", signals)
+	}
+}